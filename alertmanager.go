@@ -0,0 +1,93 @@
+package timeseriesui
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// ── Alertmanager ────────────────────────────────────────────────────────────
+//
+// Alertmanager is not a connection type of its own: it hangs off Prometheus
+// and VictoriaMetrics connections via alertmanagerUrl. Its credentials and
+// TLS settings are resolved server-side from the connection that owns the
+// target URL, never from the browser's Prometheus credentials.
+
+func init() { registerBackend(&alertmanagerBackend{}) }
+
+type alertmanagerBackend struct {
+	baseBackend
+	url      string
+	user     string
+	password string
+	caCert   string
+	insecure bool
+}
+
+func (*alertmanagerBackend) Type() string        { return "alertmanager" }
+func (*alertmanagerBackend) DisplayName() string { return "Alertmanager" }
+func (*alertmanagerBackend) HealthPath() string  { return "/-/healthy" }
+
+func (b *alertmanagerBackend) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&b.url, "alertmanager-url", "", "Default Alertmanager URL")
+	fs.StringVar(&b.user, "alertmanager-user", "", "Default Alertmanager basic-auth username")
+	fs.StringVar(&b.password, "alertmanager-password", "", "Default Alertmanager basic-auth password")
+	fs.StringVar(&b.caCert, "alertmanager-ca-cert", "", "Path to a CA certificate for verifying Alertmanager TLS")
+	fs.BoolVar(&b.insecure, "alertmanager-insecure-skip-verify", false, "Skip TLS verification for Alertmanager")
+}
+
+func (*alertmanagerBackend) FlagConnections() []CLIConnection { return nil }
+
+// FinalizeConnections attaches --alertmanager-url to the first flag-defined
+// Prometheus connection, or the first VictoriaMetrics one if there is none.
+func (b *alertmanagerBackend) FinalizeConnections(conns []CLIConnection) []CLIConnection {
+	if b.url == "" {
+		if b.user != "" || b.password != "" || b.caCert != "" || b.insecure {
+			log.Println("Warning: Alertmanager credentials or TLS flags specified without --alertmanager-url; they won't be used.")
+		}
+		return conns
+	}
+	for _, typ := range []string{"prometheus", "victoriametrics"} {
+		for i := range conns {
+			if conns[i].Type == typ {
+				c := &conns[i]
+				c.AlertmanagerURL = b.url
+				c.AlertmanagerUsername = b.user
+				c.AlertmanagerPassword = b.password
+				c.AlertmanagerCACert = b.caCert
+				c.AlertmanagerInsecure = b.insecure
+				return conns
+			}
+		}
+	}
+	log.Println("Warning: --alertmanager-url specified without --prometheus-url or --vm-url; it won't be used.")
+	return conns
+}
+
+func (*alertmanagerBackend) Validate(*CLIConnection) error {
+	return errors.New(`"alertmanager" is not a connection type; set alertmanagerUrl on a Prometheus or VictoriaMetrics connection`)
+}
+
+// Upstreams resolves targets against the connections' Alertmanager URLs.
+func (*alertmanagerBackend) Upstreams(conns []CLIConnection, cc clientConfig) (upstreamSet, error) {
+	return buildAlertmanagerUpstreams(conns, cc)
+}
+
+// Classify treats silence changes and lifecycle endpoints (/-/reload) as
+// admin; Alertmanager has no data writes beyond alerts posted by Prometheus
+// itself.
+func (*alertmanagerBackend) Classify(r *http.Request, t *proxyTarget) apiKind {
+	p := classifyPath(t.Path)
+	switch {
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		return apiRead
+	case strings.HasPrefix(p, "/api/v2/silence"),
+		strings.HasPrefix(p, "/-/") && p != "/-/healthy" && p != "/-/ready":
+		return apiAdmin
+	case p == "/api/v2/alerts", p == "/api/v1/alerts":
+		return apiWrite
+	}
+	return apiRead
+}
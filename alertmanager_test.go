@@ -0,0 +1,56 @@
+package timeseriesui
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAlertmanagerCredentials(t *testing.T) {
+	var user, pass string
+	am := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ = r.BasicAuth()
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, "[]")
+	}))
+	defer am.Close()
+	h := newTestHandler(t, Options{
+		Connections: []CLIConnection{{
+			Name: "prom", Type: "prometheus", URL: "http://127.0.0.1:1", Username: "prom", Password: "prom-secret",
+			AlertmanagerURL: am.URL, AlertmanagerUsername: "am", AlertmanagerPassword: "am-secret",
+		}},
+	})
+
+	// The browser's Prometheus credentials are never sent to Alertmanager.
+	header := map[string]string{"X-Proxy-Username": "prom", "X-Proxy-Password": "prom-secret"}
+	rec := serve(h, http.MethodGet, proxyPath("alertmanager", am.URL, "/api/v2/alerts"), nil, header)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if user != "am" || pass != "am-secret" {
+		t.Errorf("Alertmanager got basic auth %s:%s", user, pass)
+	}
+}
+
+func TestAlertmanagerClassify(t *testing.T) {
+	cases := []struct {
+		method, path string
+		want         apiKind
+	}{
+		{http.MethodGet, "/api/v2/silences", apiRead},
+		{http.MethodPost, "/api/v2/silences", apiAdmin},
+		{http.MethodDelete, "/api/v2/silence/1", apiAdmin},
+		{http.MethodPost, "/-/reload", apiAdmin},
+		{http.MethodPost, "//-/reload", apiAdmin},
+		{http.MethodPost, "/api/v2/alerts", apiWrite},
+		{http.MethodGet, "/api/v2/alerts", apiRead},
+	}
+	b := backends["alertmanager"]
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, "/", nil)
+		if got := b.Classify(r, &proxyTarget{Path: c.path}); got != c.want {
+			t.Errorf("%s %s: %v, want %v", c.method, c.path, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package timeseriesui
+
+import (
+	"context"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ── Backend registry ────────────────────────────────────────────────────────
+//
+// Each database type is a Backend registered from its own file's init(). The
+// server builds CLI flags, connections, proxy routes, auth injection, the
+// write/admin policy and health probes from the registry, so adding a type
+// touches nothing outside its file.
+
+// Backend describes one connection type.
+type Backend interface {
+	// Type is the connection type and the proxy route: /proxy/<Type>/.
+	Type() string
+	// DisplayName is used for default connection names, e.g. "Loki (host)".
+	DisplayName() string
+	// RegisterFlags adds the backend's CLI flags.
+	RegisterFlags(fs *flag.FlagSet)
+	// FlagConnections returns the connections described by the parsed flags.
+	FlagConnections() []CLIConnection
+	// Validate checks a connection of this type, from flags or a file.
+	Validate(c *CLIConnection) error
+	// HealthPath is the API path probed to check that a connection is up.
+	HealthPath() string
+	// InjectAuth sets upstream auth headers from the resolved credentials.
+	InjectAuth(h http.Header, cred credentials)
+	// Classify reports whether a proxied call reads, writes or administers.
+	Classify(r *http.Request, t *proxyTarget) apiKind
+	// Route rewrites a proxied request (base URL, path, params, headers).
+	Route(r *http.Request, t *proxyTarget) error
+}
+
+// customHandler is implemented by backends that need more than the generic
+// proxy, e.g. to reshape responses or stream.
+type customHandler interface {
+	Handler(env *proxyEnv) http.HandlerFunc
+}
+
+// upstreamBuilder is implemented by backends whose targets are not the
+// connection URLs themselves (Alertmanager).
+type upstreamBuilder interface {
+	Upstreams(conns []CLIConnection, cc clientConfig) (upstreamSet, error)
+}
+
+// connectionFinalizer is implemented by backends whose flags decorate the
+// connections built by other backends. It runs after all FlagConnections.
+type connectionFinalizer interface {
+	FinalizeConnections(conns []CLIConnection) []CLIConnection
+}
+
+// healthFallback is implemented by backends whose HealthPath some servers of
+// the type lack. The fallback path is probed when HealthPath answers 404.
+type healthFallback interface {
+	HealthFallbackPath() string
+}
+
+// credentials are the upstream auth settings of a connection.
+type credentials struct {
+	Username string
+	Password string
+	Token    string
+}
+
+// proxyEnv is what a backend's proxy handler needs at runtime.
+type proxyEnv struct {
+	backend Backend
+	client  *http.Client
+	conns   *connectionStore
+	policy  accessPolicy
+	hooks   hooks
+}
+
+var backends = map[string]Backend{}
+
+// registerBackend adds b to the registry. It is called from init().
+func registerBackend(b Backend) {
+	if _, dup := backends[b.Type()]; dup {
+		panic("duplicate backend type " + b.Type())
+	}
+	backends[b.Type()] = b
+}
+
+// lookupBackend returns the registered backend for a connection type.
+func lookupBackend(typ string) (Backend, bool) {
+	b, ok := backends[typ]
+	return b, ok
+}
+
+// sortedBackends returns the registry in a stable order.
+func sortedBackends() []Backend {
+	list := make([]Backend, 0, len(backends))
+	for _, b := range backends {
+		list = append(list, b)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Type() < list[j].Type() })
+	return list
+}
+
+// newProxyEnv returns the runtime environment of b's proxy.
+func newProxyEnv(b Backend, conns *connectionStore, cc clientConfig, policy accessPolicy, h hooks) (*proxyEnv, error) {
+	client, err := cc.newClient("", false)
+	if err != nil {
+		return nil, err
+	}
+	return &proxyEnv{backend: b, client: client, conns: conns, policy: policy, hooks: h}, nil
+}
+
+// lookup resolves target against the current server-side connections of
+// env's backend.
+func (env *proxyEnv) lookup(target *url.URL) (*upstream, bool) {
+	return env.conns.snapshot().upstreams[env.backend.Type()].lookup(target)
+}
+
+// handler returns the proxy handler for env's backend.
+func (env *proxyEnv) handler() http.HandlerFunc {
+	h := makeGenericProxy(env)
+	if ch, ok := env.backend.(customHandler); ok {
+		h = ch.Handler(env)
+	}
+	apiPath := func(r *http.Request) string { return "/" + strings.TrimPrefix(r.URL.Query().Get("path"), "/") }
+	return env.observe(lineProtocolCheck(env.backend.Type(), apiPath, h))
+}
+
+// ── Defaults shared by backends ─────────────────────────────────────────────
+
+// baseBackend provides the common behaviour: basic or token auth, every call
+// a read, no routing and no validation. Backends embed it and override.
+type baseBackend struct{}
+
+func (baseBackend) Validate(*CLIConnection) error { return nil }
+
+func (baseBackend) InjectAuth(h http.Header, cred credentials) {
+	if cred.Token != "" {
+		h.Set("Authorization", "Token "+cred.Token)
+	} else if cred.Username != "" || cred.Password != "" {
+		h.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(cred.Username+":"+cred.Password)))
+	}
+}
+
+func (baseBackend) Classify(*http.Request, *proxyTarget) apiKind { return apiRead }
+
+func (baseBackend) Route(*http.Request, *proxyTarget) error { return nil }
+
+// connFlags holds the --<prefix>-url/-user/-password/-name flags that most
+// backends share.
+type connFlags struct {
+	urls     stringSlice
+	user     string
+	password string
+	name     string
+}
+
+// register adds --<prefix>-url and --<prefix>-name.
+func (f *connFlags) register(fs *flag.FlagSet, prefix, label string) {
+	fs.Var(&f.urls, prefix+"-url", "Add a default "+label+" connection (repeatable)")
+	fs.StringVar(&f.name, prefix+"-name", "", "Display name for "+label+" connection")
+}
+
+// registerAuth adds --<prefix>-user and --<prefix>-password.
+func (f *connFlags) registerAuth(fs *flag.FlagSet, prefix, label, kind string) {
+	fs.StringVar(&f.user, prefix+"-user", "", "Default "+label+" "+kind+"username")
+	fs.StringVar(&f.password, prefix+"-password", "", "Default "+label+" "+kind+"password")
+}
+
+// build returns one connection per URL flag. Names default to nameFromURL
+// and are numbered when the flag is repeated. fill sets type-specific fields.
+func (f *connFlags) build(b Backend, fill func(i int, c *CLIConnection)) []CLIConnection {
+	var conns []CLIConnection
+	for i, u := range f.urls {
+		name := f.name
+		if name == "" {
+			name = nameFromURL(u, b.DisplayName())
+		}
+		if len(f.urls) > 1 {
+			name += " " + strconv.Itoa(i+1)
+		}
+		c := CLIConnection{
+			Name:     name,
+			Type:     b.Type(),
+			URL:      u,
+			Username: f.user,
+			Password: f.password,
+			Source:   "cli",
+		}
+		if fill != nil {
+			fill(i, &c)
+		}
+		conns = append(conns, c)
+	}
+	return conns
+}
+
+// ── Connection health ───────────────────────────────────────────────────────
+
+// connectionHealth is one entry of /api/v1/connections/health.
+type connectionHealth struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Status    string `json:"status"` // "up" or "down"
+	LatencyMs int64  `json:"latencyMs"`
+	Error     string `json:"error,omitempty"`
+}
+
+// probeHealth requests the backend's health path for a CLI connection with
+// the same auth and routing as proxied calls, and its fallback path if the
+// health path is not found.
+func probeHealth(ctx context.Context, env *proxyEnv, c *CLIConnection) connectionHealth {
+	h := connectionHealth{Name: c.Name, Type: c.Type, Status: "down"}
+	start := time.Now()
+	err := func() error {
+		target, err := url.Parse(c.URL)
+		if err != nil {
+			return err
+		}
+		t := &proxyTarget{
+			Conn:   c,
+			Base:   strings.TrimRight(c.URL, "/"),
+			Path:   env.backend.HealthPath(),
+			Params: make(url.Values),
+			Header: make(http.Header),
+		}
+		client := env.client
+		if up, ok := env.lookup(target); ok {
+			client = up.client
+			env.backend.InjectAuth(t.Header, up.creds)
+		}
+		probe, _ := http.NewRequestWithContext(ctx, http.MethodGet, c.URL, nil)
+		if err := env.backend.Route(probe, t); err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.URL(), nil)
+		if err != nil {
+			return err
+		}
+		req.Header = t.Header
+		resp, err := client.Do(req)
+		if fb, ok := env.backend.(healthFallback); ok && err == nil && resp.StatusCode == http.StatusNotFound {
+			resp.Body.Close()
+			req.URL.Path = strings.TrimSuffix(req.URL.Path, t.Path) + fb.HealthFallbackPath()
+			t.Path = fb.HealthFallbackPath()
+			resp, err = client.Do(req)
+		}
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("%s returned %s", t.Path, resp.Status)
+		}
+		return nil
+	}()
+	h.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		h.Error = err.Error()
+	} else {
+		h.Status = "up"
+	}
+	return h
+}
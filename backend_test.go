@@ -0,0 +1,48 @@
+package timeseriesui
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestBackendRoutes(t *testing.T) {
+	h := newTestHandler(t, Options{})
+	for typ := range backends {
+		rec := serve(h, http.MethodGet, "/proxy/"+typ+"/", nil, nil)
+		if rec.Code == http.StatusNotFound || rec.Code < 400 {
+			t.Errorf("%s without a target: status %d: %s", typ, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestBackendWritePolicy(t *testing.T) {
+	readOnly := newTestHandler(t, Options{DisableWrite: true, DisableAdmin: true})
+	cases := []struct{ typ, path string }{
+		{"influxdb", "/write"},
+		{"influxdb2", "/api/v2/write"},
+		{"influxdb3", "/api/v3/write_lp"},
+		{"prometheus", "/api/v1/write"},
+		{"victoriametrics", "/api/v1/import"},
+		{"loki", "/loki/api/v1/push"},
+		{"graphite", "/tags/tagSeries"},
+		{"opentsdb", "/api/put"},
+		{"victoriametrics", "/api/v1/admin/tsdb/delete_series"},
+		{"influxdb2", "/api/v2/buckets"},
+	}
+	for _, c := range cases {
+		rec := serve(readOnly, http.MethodPost, proxyPath(c.typ, "http://127.0.0.1:1", c.path), strings.NewReader("x"), nil)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s %s: status %d: %s", c.typ, c.path, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestRegisterBackendDuplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a type twice did not panic")
+		}
+	}()
+	registerBackend(&lokiBackend{})
+}
@@ -0,0 +1,229 @@
+// Command timeseriesui is a unified web UI for time-series databases.
+//
+// It serves the TimeseriesUI web interface and proxies API requests to
+// InfluxDB, Prometheus, VictoriaMetrics, and Alertmanager backends.
+// Connections are managed in the browser UI or, by admins, on the server.
+//
+// Usage:
+//
+//	timeseriesui
+//	timeseriesui --port 3000
+//	timeseriesui --influxdb-url http://myinflux:8086
+//	timeseriesui --prometheus-url http://myprom:9090
+//	timeseriesui --vm-url http://myvm:8428
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // CSV import timezones on hosts without zoneinfo
+
+	"github.com/timeseriesui/timeseriesui"
+)
+
+// Version is set at build time via -ldflags.
+var Version = "dev"
+
+// ── Config ──────────────────────────────────────────────────────────────────
+
+type Config struct {
+	Port            int
+	Host            string
+	BasePath        string
+	TLSCert         string
+	TLSKey          string
+	LogLevel        string
+	LogFormat       string
+	ProxyTimeout    time.Duration
+	MaxRequestSize  string
+	MaxResponseSize string
+	AllowedOrigins  []string
+	UpgradeIdle     time.Duration
+	DataDir         string
+	UserHeader      string
+	DisableHistory  bool
+	HistoryMax      int
+	HistoryKeep     time.Duration
+	JobWorkers      int
+	MaxUploadSize   string
+	RemoteWrite     string
+	DisableWrite    bool
+	DisableAdmin    bool
+	ReadOnly        bool
+	ShowVersion     bool
+	ConnectionsFile string
+	Connections     []timeseriesui.CLIConnection
+}
+
+func main() {
+	cfg := parseFlags()
+
+	if cfg.ShowVersion {
+		fmt.Printf("timeseriesui %s\n", Version)
+		os.Exit(0)
+	}
+
+	maxRequest, err := parseSize(cfg.MaxRequestSize)
+	if err != nil {
+		log.Fatalf("Invalid --max-request-size: %v", err)
+	}
+	maxResponse, err := parseSize(cfg.MaxResponseSize)
+	if err != nil {
+		log.Fatalf("Invalid --max-response-size: %v", err)
+	}
+	maxUpload, err := parseSize(cfg.MaxUploadSize)
+	if err != nil {
+		log.Fatalf("Invalid --max-upload-size: %v", err)
+	}
+
+	opts := timeseriesui.Options{
+		Connections:        cfg.Connections,
+		ConnectionsFile:    cfg.ConnectionsFile,
+		BasePath:           cfg.BasePath,
+		DisableWrite:       cfg.DisableWrite || cfg.ReadOnly,
+		DisableAdmin:       cfg.DisableAdmin || cfg.ReadOnly,
+		ProxyTimeout:       cfg.ProxyTimeout,
+		MaxRequestSize:     maxRequest,
+		MaxResponseSize:    maxResponse,
+		AllowedOrigins:     cfg.AllowedOrigins,
+		UpgradeIdleTimeout: cfg.UpgradeIdle,
+		DataDir:            cfg.DataDir,
+		DisableHistory:     cfg.DisableHistory,
+		HistoryMaxEntries:  cfg.HistoryMax,
+		HistoryRetention:   cfg.HistoryKeep,
+		JobWorkers:         cfg.JobWorkers,
+		MaxUploadSize:      maxUpload,
+		Version:            Version,
+	}
+	if cfg.RemoteWrite != "" {
+		targets, err := timeseriesui.LoadRemoteWriteFile(cfg.RemoteWrite)
+		if err != nil {
+			log.Fatalf("Invalid --remote-write-config: %v", err)
+		}
+		opts.RemoteWriteTargets = targets
+	}
+	if cfg.UserHeader != "" {
+		opts.User = func(r *http.Request) string { return r.Header.Get(cfg.UserHeader) }
+	}
+	handler, err := timeseriesui.New(opts)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	basePath := strings.TrimRight(cfg.BasePath, "/")
+	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+	scheme := "http"
+	if cfg.TLSCert != "" {
+		scheme = "https"
+	}
+	displayHost := cfg.Host
+	if displayHost == "0.0.0.0" || displayHost == "" {
+		displayHost = "localhost"
+	}
+	fmt.Printf("TimeseriesUI %s starting on %s://%s:%d%s/ui/\n", Version, scheme, displayHost, cfg.Port, basePath)
+	fmt.Printf("Playground available at %s://%s:%d%s/playground/\n", scheme, displayHost, cfg.Port, basePath)
+	for _, c := range cfg.Connections {
+		fmt.Printf("  [%s] %s → %s\n", c.Type, c.Name, c.URL)
+	}
+	if cfg.ConnectionsFile != "" {
+		fmt.Printf("Managed connections in %s\n", cfg.ConnectionsFile)
+	} else if len(cfg.Connections) == 0 {
+		fmt.Println("No default connections — add them in the UI.")
+	}
+	for _, t := range opts.RemoteWriteTargets {
+		fmt.Printf("  remote write → %s\n", t.Connection)
+	}
+	fmt.Println("Press Ctrl+C to stop.")
+
+	if cfg.TLSCert != "" && cfg.TLSKey != "" {
+		if err := http.ListenAndServeTLS(addr, cfg.TLSCert, cfg.TLSKey, handler); err != nil {
+			log.Fatalf("Server failed: %v", err)
+		}
+	} else {
+		if err := http.ListenAndServe(addr, handler); err != nil {
+			log.Fatalf("Server failed: %v", err)
+		}
+	}
+}
+
+// ── Flag parsing ────────────────────────────────────────────────────────────
+
+func parseFlags() Config {
+	var (
+		cfg          Config
+		proxyTimeout string
+	)
+
+	flag.IntVar(&cfg.Port, "port", 8080, "Port to listen on")
+	flag.StringVar(&cfg.Host, "host", "0.0.0.0", "Host/IP to bind to")
+	flag.StringVar(&cfg.BasePath, "base-path", "", "Base URL path prefix, e.g. /tsui")
+	flag.StringVar(&cfg.TLSCert, "tls-cert", "", "Path to TLS certificate file")
+	flag.StringVar(&cfg.TLSKey, "tls-key", "", "Path to TLS private key file")
+
+	timeseriesui.RegisterFlags(flag.CommandLine)
+
+	flag.StringVar(&cfg.ConnectionsFile, "connections", "", "Path to a JSON connections file, updated when connections are managed through the API")
+	flag.StringVar(&cfg.LogLevel, "log-level", "info", "Log verbosity: debug, info, warn, error")
+	flag.StringVar(&cfg.LogFormat, "log-format", "text", "Log format: text, json")
+	flag.StringVar(&proxyTimeout, "proxy-timeout", "30s", "Timeout for proxied API requests")
+	flag.StringVar(&cfg.MaxRequestSize, "max-request-size", "0", "Max request body forwarded upstream, e.g. 100MB (0: no limit)")
+	flag.StringVar(&cfg.MaxResponseSize, "max-response-size", "50MB", "Max proxied response size")
+	flag.Func("allowed-origin", "Browser origin allowed to open WebSocket connections through the proxy (repeatable, * for any)", func(v string) error {
+		cfg.AllowedOrigins = append(cfg.AllowedOrigins, v)
+		return nil
+	})
+	flag.DurationVar(&cfg.UpgradeIdle, "upgrade-idle-timeout", 5*time.Minute, "Close proxied WebSocket connections idle for this long")
+
+	flag.StringVar(&cfg.DataDir, "data-dir", "", "Directory for server-side state such as query history (in memory if unset)")
+	flag.StringVar(&cfg.UserHeader, "user-header", "", "Request header naming the user, set by an authenticating proxy (e.g. X-Forwarded-User)")
+	flag.BoolVar(&cfg.DisableHistory, "disable-history", false, "Do not record queries in the server-side history")
+	flag.IntVar(&cfg.HistoryMax, "history-max-entries", 10000, "Max query history entries kept")
+	flag.DurationVar(&cfg.HistoryKeep, "history-retention", 30*24*time.Hour, "Max age of query history entries")
+	flag.IntVar(&cfg.JobWorkers, "job-workers", 2, "Background jobs (exports, migrations) run at once")
+	flag.StringVar(&cfg.MaxUploadSize, "max-upload-size", "1GB", "Max size of a file sent to the upload and CSV import APIs")
+	flag.StringVar(&cfg.RemoteWrite, "remote-write-config", "", "Path to a JSON file of targets for the remote write receiver at /api/v1/write")
+
+	flag.BoolVar(&cfg.DisableWrite, "disable-write", false, "Disable the Write Data feature")
+	flag.BoolVar(&cfg.DisableAdmin, "disable-admin", false, "Disable admin/destructive operations")
+	flag.BoolVar(&cfg.ReadOnly, "readonly", false, "Shorthand for --disable-write --disable-admin")
+	flag.BoolVar(&cfg.ShowVersion, "version", false, "Print version and exit")
+
+	flag.Parse()
+
+	if d, err := time.ParseDuration(proxyTimeout); err == nil {
+		cfg.ProxyTimeout = d
+	} else {
+		cfg.ProxyTimeout = 30 * time.Second
+	}
+
+	cfg.Connections = timeseriesui.FlagConnections()
+
+	return cfg
+}
+
+// parseSize parses a byte size such as "50MB", "512KB" or "1GB". "0" means
+// no limit.
+func parseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	mult := int64(1)
+	for _, u := range []struct {
+		suffix string
+		mult   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(s, u.suffix) {
+			s, mult = strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), u.mult
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * mult, nil
+}
@@ -0,0 +1,485 @@
+package timeseriesui
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ── Server-managed connections ──────────────────────────────────────────────
+//
+// Server-side connections are either fixed (Options.Connections, i.e. the
+// CLI flags) or managed: loaded from the connections file, or from
+// connections.json in the data directory, and editable through the API.
+// Changes are written back atomically and swapped in as a new snapshot, so
+// in-flight proxy requests keep the connections they started with. The API
+// has no authentication of its own; changes are admin operations, gated
+// only by DisableAdmin and the Authorize hook.
+//
+//	GET    /api/v1/connections
+//	POST   /api/v1/connections            admin
+//	PUT    /api/v1/connections/<id>       admin
+//	DELETE /api/v1/connections/<id>       admin
+//	POST   /api/v1/connections/import     admin; ?mode=merge|replace
+
+// connSnapshot is an immutable view of the connections and their resolved
+// upstreams, by backend type.
+type connSnapshot struct {
+	conns     []CLIConnection
+	upstreams map[string]upstreamSet
+}
+
+// firstURL returns the URL of the first connection of typ, or "".
+func (s *connSnapshot) firstURL(typ string) string {
+	for _, c := range s.conns {
+		if c.Type == typ {
+			return c.URL
+		}
+	}
+	return ""
+}
+
+// connectionStore owns the current snapshot and the managed connections.
+type connectionStore struct {
+	mu      sync.Mutex // serializes updates
+	snap    atomic.Pointer[connSnapshot]
+	fixed   []CLIConnection
+	managed []CLIConnection
+	path    string // where managed connections are persisted, or ""
+	cc      clientConfig
+}
+
+// newConnectionStore loads the managed connections from file, or else from
+// dir/connections.json, and builds the first snapshot.
+func newConnectionStore(fixed []CLIConnection, file, dir string, cc clientConfig) (*connectionStore, error) {
+	s := &connectionStore{cc: cc}
+	for _, c := range fixed {
+		c.Source, c.Managed, c.ID = "cli", false, ""
+		s.fixed = append(s.fixed, c)
+	}
+	switch {
+	case file != "":
+		s.path = file
+	case dir != "":
+		s.path = filepath.Join(dir, "connections.json")
+	}
+	var managed []CLIConnection
+	if s.path != "" {
+		var cf ConnectionsFile
+		if err := loadJSONFile(s.path, &cf); err != nil {
+			return nil, fmt.Errorf("loading connections: %w", err)
+		}
+		managed = cf.Connections
+	}
+	for i := range managed {
+		c := &managed[i]
+		c.Source, c.Managed = "cli", true
+		if c.ID == "" {
+			c.ID = nameID(c.Name)
+		}
+	}
+	for _, c := range append(append([]CLIConnection{}, s.fixed...), managed...) {
+		b, ok := lookupBackend(c.Type)
+		if !ok {
+			log.Printf("Warning: connection %q has unknown type %q; it won't be proxied.", c.Name, c.Type)
+			continue
+		}
+		if err := b.Validate(&c); err != nil {
+			return nil, fmt.Errorf("connection %q: %w", c.Name, err)
+		}
+	}
+	if err := s.swap(managed); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// nameID derives a stable ID for a hand-written connection without one, so
+// it keeps its ID across restarts until the file is next saved.
+func nameID(name string) string {
+	sum := sha1.Sum([]byte(name))
+	return hex.EncodeToString(sum[:8])
+}
+
+// snapshot returns the current connections.
+func (s *connectionStore) snapshot() *connSnapshot { return s.snap.Load() }
+
+// swap resolves managed together with the fixed connections and makes the
+// result current. The caller holds mu (or is the constructor).
+func (s *connectionStore) swap(managed []CLIConnection) error {
+	snap := &connSnapshot{upstreams: map[string]upstreamSet{}}
+	snap.conns = append(append(snap.conns, s.fixed...), managed...)
+	for _, b := range sortedBackends() {
+		var (
+			ups upstreamSet
+			err error
+		)
+		if ub, ok := b.(upstreamBuilder); ok {
+			ups, err = ub.Upstreams(snap.conns, s.cc)
+		} else {
+			ups, err = buildConnectionUpstreams(snap.conns, b.Type(), s.cc)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid %s configuration: %w", b.DisplayName(), err)
+		}
+		snap.upstreams[b.Type()] = ups
+	}
+	s.managed = managed
+	s.snap.Store(snap)
+	return nil
+}
+
+// save writes the managed connections in the connections-file format. The
+// caller holds mu.
+func (s *connectionStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	cf := ConnectionsFile{Connections: make([]CLIConnection, len(s.managed))}
+	for i, c := range s.managed {
+		c.Source, c.Managed = "", false
+		cf.Connections[i] = c
+	}
+	return saveJSONFile(s.path, cf)
+}
+
+// update applies change to a copy of the managed connections, then swaps
+// and persists the result. Nothing changes if either step fails.
+func (s *connectionStore) update(change func(managed []CLIConnection) ([]CLIConnection, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev := s.managed
+	next, err := change(append([]CLIConnection(nil), prev...))
+	if err != nil {
+		return err
+	}
+	if err := s.swap(next); err != nil {
+		return &httpError{http.StatusBadRequest, err.Error()}
+	}
+	if err := s.save(); err != nil {
+		s.swap(prev)
+		return fmt.Errorf("saving connections: %w", err)
+	}
+	return nil
+}
+
+// validateConnection checks a connection submitted through the API.
+func validateConnection(c *CLIConnection) error {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	b, ok := lookupBackend(c.Type)
+	if !ok {
+		return fmt.Errorf("unknown type %q", c.Type)
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid url %q: must use http:// or https://", c.URL)
+	}
+	return b.Validate(c)
+}
+
+// nameTaken reports whether another connection than id is called name.
+func (s *connectionStore) nameTaken(managed []CLIConnection, name, id string) bool {
+	for _, c := range s.fixed {
+		if strings.EqualFold(c.Name, name) {
+			return true
+		}
+	}
+	for _, c := range managed {
+		if c.ID != id && strings.EqualFold(c.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// fixedURL returns the name of a fixed connection that c shares an upstream
+// URL with. The proxy keys credentials by URL, so such a managed connection
+// would replace the fixed connection's credentials.
+func (s *connectionStore) fixedURL(c *CLIConnection) (string, bool) {
+	taken := map[string]string{}
+	for i := range s.fixed {
+		for _, u := range connectionURLs(&s.fixed[i]) {
+			taken[u] = s.fixed[i].Name
+		}
+	}
+	for _, u := range connectionURLs(c) {
+		if name, ok := taken[u]; ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// connectionURLs returns the normalized upstream URLs of c.
+func connectionURLs(c *CLIConnection) []string {
+	var out []string
+	for _, raw := range []string{c.URL, c.AlertmanagerURL, c.VminsertURL, c.VmstorageURL} {
+		if u, err := url.Parse(raw); raw != "" && err == nil {
+			out = append(out, normalizeBaseURL(u))
+		}
+	}
+	return out
+}
+
+// connectionView is a connection as the API shows it: secrets are blanked
+// and only flagged as set.
+type connectionView struct {
+	CLIConnection
+	HasPassword             bool `json:"hasPassword,omitempty"`
+	HasToken                bool `json:"hasToken,omitempty"`
+	HasAlertmanagerPassword bool `json:"hasAlertmanagerPassword,omitempty"`
+}
+
+func redactConnection(c CLIConnection) connectionView {
+	v := connectionView{CLIConnection: c, HasPassword: c.Password != "", HasToken: c.Token != "", HasAlertmanagerPassword: c.AlertmanagerPassword != ""}
+	v.Password, v.Token, v.AlertmanagerPassword = "", "", ""
+	return v
+}
+
+// keepSecrets fills the secrets c leaves empty from prev, so a connection
+// read from the API can be sent back unchanged.
+func keepSecrets(c *CLIConnection, prev CLIConnection) {
+	if c.Password == "" {
+		c.Password = prev.Password
+	}
+	if c.Token == "" {
+		c.Token = prev.Token
+	}
+	if c.AlertmanagerPassword == "" {
+		c.AlertmanagerPassword = prev.AlertmanagerPassword
+	}
+}
+
+// importResult reports what /api/v1/connections/import did.
+type importResult struct {
+	Created int             `json:"created"`
+	Updated int             `json:"updated"`
+	Removed int             `json:"removed"`
+	Skipped []importSkipped `json:"skipped"`
+}
+
+type importSkipped struct {
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+// decodeImport accepts a browser export (a JSON array of connections, as
+// stored in localStorage) or a connections file ({"connections": […]}).
+// Browser copies of server connections (source "cli") are dropped.
+func decodeImport(data []byte) ([]CLIConnection, error) {
+	var list []CLIConnection
+	if err := json.Unmarshal(data, &list); err != nil {
+		var cf ConnectionsFile
+		if err2 := json.Unmarshal(data, &cf); err2 != nil {
+			return nil, err
+		}
+		list = cf.Connections
+	}
+	out := list[:0]
+	for _, c := range list {
+		if c.Source == "cli" {
+			continue
+		}
+		if c.Type == "" {
+			c.Type = "influxdb" // browser connections predating connection types
+		}
+		c.ID, c.Source, c.Managed = "", "cli", true
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// handler serves the connections API. mgmt applies the admin policy, the
+// Authorize and Audit hooks to changes.
+func (s *connectionStore) handler(prefix string, mgmt *proxyEnv) http.HandlerFunc {
+	change := mgmt.observe(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+		op := Operation{Backend: "connections", Method: r.Method, Path: r.URL.Path}
+		if err := mgmt.authorize(r, op, apiAdmin); err != nil {
+			jsonError(w, http.StatusForbidden, err.Error())
+			return
+		}
+
+		var (
+			status = http.StatusOK
+			result interface{}
+			err    error
+		)
+		switch {
+		case id == "" && r.Method == http.MethodPost:
+			var c CLIConnection
+			if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+				jsonError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+				return
+			}
+			err = s.update(func(managed []CLIConnection) ([]CLIConnection, error) {
+				if err := validateConnection(&c); err != nil {
+					return nil, &httpError{http.StatusBadRequest, err.Error()}
+				}
+				if s.nameTaken(managed, c.Name, "") {
+					return nil, &httpError{http.StatusConflict, fmt.Sprintf("A connection named %q already exists", c.Name)}
+				}
+				if name, ok := s.fixedURL(&c); ok {
+					return nil, &httpError{http.StatusConflict, fmt.Sprintf("The CLI connection %q already uses this URL", name)}
+				}
+				c.ID, c.Source, c.Managed = newID(), "cli", true
+				return append(managed, c), nil
+			})
+			status, result = http.StatusCreated, redactConnection(c)
+
+		case id == "import" && r.Method == http.MethodPost:
+			result, err = s.importConnections(r)
+
+		case id != "" && (r.Method == http.MethodPut || r.Method == http.MethodDelete):
+			var c CLIConnection
+			if r.Method == http.MethodPut {
+				if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+					jsonError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+					return
+				}
+			}
+			err = s.update(func(managed []CLIConnection) ([]CLIConnection, error) {
+				i := indexOfConnection(managed, id)
+				if i < 0 {
+					return nil, &httpError{http.StatusNotFound, "Connection not found or not editable"}
+				}
+				if r.Method == http.MethodDelete {
+					return append(managed[:i], managed[i+1:]...), nil
+				}
+				keepSecrets(&c, managed[i])
+				if err := validateConnection(&c); err != nil {
+					return nil, &httpError{http.StatusBadRequest, err.Error()}
+				}
+				if s.nameTaken(managed, c.Name, id) {
+					return nil, &httpError{http.StatusConflict, fmt.Sprintf("A connection named %q already exists", c.Name)}
+				}
+				if name, ok := s.fixedURL(&c); ok {
+					return nil, &httpError{http.StatusConflict, fmt.Sprintf("The CLI connection %q already uses this URL", name)}
+				}
+				c.ID, c.Source, c.Managed = id, "cli", true
+				managed[i] = c
+				return managed, nil
+			})
+			result = redactConnection(c)
+			if r.Method == http.MethodDelete {
+				status, result = http.StatusNoContent, nil
+			}
+
+		default:
+			jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		if err != nil {
+			jsonError(w, httpErrorStatus(err, http.StatusInternalServerError), err.Error())
+			return
+		}
+		if result == nil {
+			w.WriteHeader(status)
+			return
+		}
+		writeJSON(w, status, result)
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		setCORS(w)
+		switch r.Method {
+		case http.MethodOptions:
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodGet, http.MethodHead:
+			if strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/") != "" {
+				jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+				return
+			}
+			conns := []connectionView{}
+			for _, c := range s.snapshot().conns {
+				conns = append(conns, redactConnection(c))
+			}
+			writeJSON(w, http.StatusOK, conns)
+		default:
+			change(w, r)
+		}
+	}
+}
+
+// importConnections merges (default) or replaces the managed connections
+// with those in the request body, matching existing ones by name.
+func (s *connectionStore) importConnections(r *http.Request) (*importResult, error) {
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = "merge"
+	}
+	if mode != "merge" && mode != "replace" {
+		return nil, &httpError{http.StatusBadRequest, "mode must be merge or replace"}
+	}
+	var raw json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		return nil, &httpError{http.StatusBadRequest, "Invalid JSON: " + err.Error()}
+	}
+	incoming, err := decodeImport(raw)
+	if err != nil {
+		return nil, &httpError{http.StatusBadRequest, "Invalid import: " + err.Error()}
+	}
+
+	res := &importResult{Skipped: []importSkipped{}}
+	err = s.update(func(managed []CLIConnection) ([]CLIConnection, error) {
+		if mode == "replace" {
+			res.Removed = len(managed)
+			managed = nil
+		}
+		for _, c := range incoming {
+			if err := validateConnection(&c); err != nil {
+				res.Skipped = append(res.Skipped, importSkipped{Name: c.Name, Error: err.Error()})
+				continue
+			}
+			if name, ok := s.fixedURL(&c); ok {
+				res.Skipped = append(res.Skipped, importSkipped{Name: c.Name, Error: fmt.Sprintf("url is used by the CLI connection %q", name)})
+				continue
+			}
+			if i := indexOfConnectionName(managed, c.Name); i >= 0 {
+				c.ID = managed[i].ID
+				managed[i] = c
+				res.Updated++
+				continue
+			}
+			if s.nameTaken(managed, c.Name, "") {
+				res.Skipped = append(res.Skipped, importSkipped{Name: c.Name, Error: "name is used by a CLI connection"})
+				continue
+			}
+			c.ID = newID()
+			managed = append(managed, c)
+			res.Created++
+		}
+		return managed, nil
+	})
+	return res, err
+}
+
+func indexOfConnection(conns []CLIConnection, id string) int {
+	for i, c := range conns {
+		if c.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func indexOfConnectionName(conns []CLIConnection, name string) int {
+	for i, c := range conns {
+		if strings.EqualFold(c.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
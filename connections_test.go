@@ -0,0 +1,38 @@
+package timeseriesui
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestManagedConnectionFixedURL(t *testing.T) {
+	h := newTestHandler(t, Options{
+		Connections: []CLIConnection{{Name: "prod", Type: "prometheus", URL: "http://prom:9090/", Username: "admin", Password: "secret"}},
+	})
+	for _, body := range []string{
+		`{"name": "mine", "type": "prometheus", "url": "http://prom:9090"}`,
+		`{"name": "mine", "type": "prometheus", "url": "HTTP://PROM:9090/"}`,
+	} {
+		if rec := serve(h, http.MethodPost, "/api/v1/connections", strings.NewReader(body), nil); rec.Code != http.StatusConflict {
+			t.Errorf("create %s: status %d: %s", body, rec.Code, rec.Body)
+		}
+	}
+
+	rec := serve(h, http.MethodPost, "/api/v1/connections", strings.NewReader(`{"name": "mine", "type": "prometheus", "url": "http://other:9090"}`), nil)
+	var c struct{ ID string }
+	if err := json.Unmarshal(rec.Body.Bytes(), &c); err != nil || rec.Code != http.StatusCreated {
+		t.Fatalf("create: status %d: %s", rec.Code, rec.Body)
+	}
+	rec = serve(h, http.MethodPut, "/api/v1/connections/"+c.ID, strings.NewReader(`{"name": "mine", "type": "prometheus", "url": "http://prom:9090"}`), nil)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("update: status %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = serve(h, http.MethodPost, "/api/v1/connections/import", strings.NewReader(`[{"name": "copy", "type": "prometheus", "url": "http://prom:9090"}]`), nil)
+	var res importResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil || res.Created != 0 || len(res.Skipped) != 1 {
+		t.Errorf("import: status %d: %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,792 @@
+package timeseriesui
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ── CSV imports ─────────────────────────────────────────────────────────────
+//
+// A CSV file and a mapping spec convert to line protocol for InfluxDB
+// connections, or to the CSV import format of VictoriaMetrics
+// (/api/v1/import/csv):
+//
+//	POST <BasePath>/api/v1/csv/preview?rows=…   the first rows, converted
+//	POST <BasePath>/api/v1/csv/import?…         convert and write as an upload job
+//
+// Both take either a JSON body {"mapping": {…}, "csv": "…"} or a multipart
+// form with a "mapping" part followed by a "file" part (plain or gzipped).
+// Import takes the query parameters of /api/v1/uploads; the converted file
+// is written like an upload, in batches and resumable.
+
+const (
+	defaultCSVPreviewRows = 10
+	maxCSVPreviewRows     = 1000
+	maxCSVMappingSize     = 1 << 20
+)
+
+// csvMapping says how the columns of a CSV file map to points. Columns are
+// named by header, or by 1-based position.
+type csvMapping struct {
+	Delimiter         string        `json:"delimiter,omitempty"` // default ","
+	Header            *bool         `json:"header,omitempty"`    // default true
+	SkipRows          int           `json:"skipRows,omitempty"`  // before the header
+	Timestamp         *csvTimestamp `json:"timestamp,omitempty"`
+	Measurement       string        `json:"measurement,omitempty"`
+	MeasurementColumn string        `json:"measurementColumn,omitempty"`
+	Tags              []csvColumn   `json:"tags,omitempty"`
+	Fields            []csvColumn   `json:"fields"`
+	Precision         string        `json:"precision,omitempty"` // of line protocol timestamps, default ns
+}
+
+// csvTimestamp is the timestamp column. Format is rfc3339 (the default),
+// unix_s, unix_ms, unix_us, unix_ns or a Go time layout; layouts without a
+// zone are read in Timezone (default UTC).
+type csvTimestamp struct {
+	Column   string `json:"column"`
+	Format   string `json:"format,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// csvColumn is a tag or field column. Name defaults to the column; Type is
+// for fields: float (the default), integer, unsigned, boolean or string.
+type csvColumn struct {
+	Column string `json:"column"`
+	Name   string `json:"name,omitempty"`
+	Type   string `json:"type,omitempty"`
+}
+
+// csvRowError is a row that could not be converted. Row is the line of the
+// file it starts on.
+type csvRowError struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *csvRowError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("row %d: %s", e.Row, e.Message)
+	}
+	return fmt.Sprintf("row %d, column %q: %s", e.Row, e.Column, e.Message)
+}
+
+// csvPreviewRow is a converted row.
+type csvPreviewRow struct {
+	Row    int    `json:"row"`
+	Output string `json:"output"`
+}
+
+// csvPreview is the response of /api/v1/csv/preview.
+type csvPreview struct {
+	Format     string          `json:"format"` // line or csv
+	CSVFormat  string          `json:"csvFormat,omitempty"`
+	Precision  string          `json:"precision,omitempty"`
+	Columns    []string        `json:"columns"`
+	Rows       []csvPreviewRow `json:"rows"`
+	Errors     []csvRowError   `json:"errors"`
+	ErrorCount int             `json:"errorCount"`
+}
+
+// csvConverter converts the rows of one file.
+type csvConverter struct {
+	m      *csvMapping
+	format string // line or csv
+	loc    *time.Location
+	unit   time.Duration
+	comma  rune
+
+	columns   []string
+	ts, meas  int          // column indexes, or -1
+	tags      []csvIndexed // by name
+	fields    []csvIndexed
+	precision string // line
+
+	vmFormat string // csv: the /api/v1/import/csv column spec
+	csvOut   *csv.Writer
+	csvBuf   strings.Builder
+	vmRecord []string
+}
+
+type csvIndexed struct {
+	csvColumn
+	idx int
+}
+
+var csvFieldTypes = map[string]bool{"float": true, "integer": true, "unsigned": true, "boolean": true, "string": true}
+
+// newCSVConverter validates m for the output format ("line" or "csv").
+func newCSVConverter(m *csvMapping, format string) (*csvConverter, error) {
+	cv := &csvConverter{m: m, format: format, loc: time.UTC, comma: ',', ts: -1, meas: -1}
+	bad := func(msg string) error { return &httpError{http.StatusBadRequest, "mapping: " + msg} }
+	switch m.Delimiter {
+	case "":
+	case "tab", "\\t":
+		cv.comma = '\t'
+	default:
+		r := []rune(m.Delimiter)
+		if len(r) != 1 || r[0] == '"' || r[0] == '\n' || r[0] == '\r' {
+			return nil, bad("delimiter must be a single character")
+		}
+		cv.comma = r[0]
+	}
+	if m.SkipRows < 0 {
+		return nil, bad("skipRows must not be negative")
+	}
+	if t := m.Timestamp; t != nil {
+		if t.Column == "" {
+			return nil, bad("timestamp.column is required")
+		}
+		if t.Timezone != "" {
+			loc, err := time.LoadLocation(t.Timezone)
+			if err != nil {
+				return nil, bad(fmt.Sprintf("unknown timezone %q", t.Timezone))
+			}
+			cv.loc = loc
+		}
+	}
+	if len(m.Fields) == 0 {
+		return nil, bad("at least one field is required")
+	}
+	names := map[string]bool{}
+	for i := range m.Fields {
+		f := &m.Fields[i]
+		if f.Type == "" {
+			f.Type = "float"
+		}
+		if !csvFieldTypes[f.Type] {
+			return nil, bad(fmt.Sprintf("invalid type %q for field %q", f.Type, f.Column))
+		}
+		if format == "csv" && f.Type == "string" {
+			return nil, bad(fmt.Sprintf("string field %q cannot be imported into victoriametrics", f.Column))
+		}
+	}
+	for _, list := range [][]csvColumn{m.Tags, m.Fields} {
+		for i := range list {
+			c := &list[i]
+			if c.Column == "" {
+				return nil, bad("every tag and field needs a column")
+			}
+			if c.Name == "" {
+				c.Name = c.Column
+			}
+			if strings.ContainsAny(c.Name, "\r\n") {
+				return nil, bad(fmt.Sprintf("name %q cannot contain line breaks", c.Name))
+			}
+			if format == "csv" && strings.ContainsAny(c.Name, ":,") {
+				return nil, bad(fmt.Sprintf("name %q cannot contain ':' or ','", c.Name))
+			}
+		}
+	}
+	sort.SliceStable(m.Tags, func(i, j int) bool { return m.Tags[i].Name < m.Tags[j].Name })
+	for _, f := range m.Fields {
+		if names[f.Name] {
+			return nil, bad(fmt.Sprintf("duplicate field %q", f.Name))
+		}
+		names[f.Name] = true
+	}
+
+	if strings.ContainsAny(m.Measurement, "\r\n") {
+		return nil, bad("measurement cannot contain line breaks")
+	}
+	switch format {
+	case "line":
+		if m.Measurement == "" && m.MeasurementColumn == "" {
+			return nil, bad("measurement or measurementColumn is required")
+		}
+		cv.precision = m.Precision
+		if cv.precision == "" {
+			cv.precision = "ns"
+		}
+		cv.unit = linePrecisions[cv.precision]
+		if cv.unit == 0 || cv.unit > time.Second {
+			return nil, bad("precision must be one of ns, us, ms, s")
+		}
+	case "csv":
+		if m.MeasurementColumn != "" {
+			return nil, bad("measurementColumn is not supported for victoriametrics; use measurement")
+		}
+		if m.Precision != "" {
+			return nil, bad("precision does not apply to victoriametrics, which takes milliseconds")
+		}
+		var spec []string
+		if m.Timestamp != nil {
+			spec = append(spec, "time:unix_ms")
+		}
+		for _, t := range m.Tags {
+			spec = append(spec, "label:"+t.Name)
+		}
+		for _, f := range m.Fields {
+			name := f.Name
+			if m.Measurement != "" {
+				name = m.Measurement + "_" + f.Name
+			}
+			spec = append(spec, "metric:"+name)
+		}
+		for i := range spec {
+			spec[i] = strconv.Itoa(i+1) + ":" + spec[i]
+		}
+		cv.vmFormat = strings.Join(spec, ",")
+		cv.csvOut = csv.NewWriter(&cv.csvBuf)
+		cv.vmRecord = make([]string, len(spec))
+	}
+	return cv, nil
+}
+
+// header resolves the mapping's columns against the header row, or against
+// the number of columns of the first row of a file without one.
+func (cv *csvConverter) header(row []string, named bool) error {
+	index := map[string]int{}
+	cv.columns = make([]string, len(row))
+	for i, name := range row {
+		if named {
+			name = strings.TrimSpace(name)
+			if i == 0 {
+				name = strings.TrimPrefix(name, "\uFEFF")
+			}
+			if _, dup := index[name]; !dup {
+				index[name] = i
+			}
+		} else {
+			name = strconv.Itoa(i + 1)
+		}
+		cv.columns[i] = name
+	}
+	find := func(col string) (int, error) {
+		if i, ok := index[col]; ok {
+			return i, nil
+		}
+		if n, err := strconv.Atoi(col); err == nil && n >= 1 && n <= len(row) {
+			return n - 1, nil
+		}
+		if named {
+			return 0, &httpError{http.StatusBadRequest, fmt.Sprintf("mapping: no column %q in the header", col)}
+		}
+		return 0, &httpError{http.StatusBadRequest, fmt.Sprintf("mapping: column %q must be a position from 1 to %d", col, len(row))}
+	}
+	var err error
+	if t := cv.m.Timestamp; t != nil {
+		if cv.ts, err = find(t.Column); err != nil {
+			return err
+		}
+	}
+	if cv.m.MeasurementColumn != "" {
+		if cv.meas, err = find(cv.m.MeasurementColumn); err != nil {
+			return err
+		}
+	}
+	cv.tags, cv.fields = nil, nil
+	for _, list := range []struct {
+		cols []csvColumn
+		dst  *[]csvIndexed
+	}{{cv.m.Tags, &cv.tags}, {cv.m.Fields, &cv.fields}} {
+		for _, c := range list.cols {
+			i, err := find(c.Column)
+			if err != nil {
+				return err
+			}
+			*list.dst = append(*list.dst, csvIndexed{c, i})
+		}
+	}
+	return nil
+}
+
+// convert converts one row, appending the output line to b.
+func (cv *csvConverter) convert(b []byte, n int, row []string) ([]byte, *csvRowError) {
+	cell := func(i int) string {
+		if i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+	fail := func(i int, msg string) *csvRowError {
+		e := &csvRowError{Row: n, Message: msg}
+		if i >= 0 && i < len(cv.columns) {
+			e.Column = cv.columns[i]
+		}
+		return e
+	}
+	var ts time.Time
+	if cv.ts >= 0 {
+		v := cell(cv.ts)
+		if v == "" {
+			return b, fail(cv.ts, "no timestamp")
+		}
+		var err error
+		if ts, err = parseCSVTime(v, cv.m.Timestamp.Format, cv.loc); err != nil {
+			return b, fail(cv.ts, err.Error())
+		}
+	}
+
+	var fields []lineField
+	for _, f := range cv.fields {
+		v := cell(f.idx)
+		if v == "" {
+			fields = append(fields, lineField{Key: f.Name})
+			continue
+		}
+		raw, err := csvFieldValue(v, f.Type, cv.format)
+		if err != nil {
+			return b, fail(f.idx, err.Error())
+		}
+		fields = append(fields, lineField{Key: f.Name, Type: f.Type, Raw: raw})
+	}
+
+	if cv.format == "csv" {
+		i := 0
+		if cv.ts >= 0 {
+			cv.vmRecord[i] = strconv.FormatInt(ts.UnixMilli(), 10)
+			i++
+		}
+		for _, t := range cv.tags {
+			cv.vmRecord[i] = cell(t.idx)
+			i++
+		}
+		values := 0
+		for _, f := range fields {
+			cv.vmRecord[i] = f.Raw
+			if f.Raw != "" {
+				values++
+			}
+			i++
+		}
+		if values == 0 {
+			return b, fail(-1, "no field values")
+		}
+		cv.csvBuf.Reset()
+		cv.csvOut.Write(cv.vmRecord)
+		cv.csvOut.Flush()
+		return append(b, cv.csvBuf.String()...), nil
+	}
+
+	p := &linePoint{Measurement: cv.m.Measurement}
+	if cv.meas >= 0 {
+		if p.Measurement = cell(cv.meas); p.Measurement == "" {
+			return b, fail(cv.meas, "empty measurement")
+		}
+		if strings.ContainsAny(p.Measurement, "\r\n") {
+			return b, fail(cv.meas, "measurement contains a line break")
+		}
+	}
+	for _, t := range cv.tags {
+		p.Tags = append(p.Tags, lineTag{t.Name, cell(t.idx)})
+	}
+	for _, f := range fields {
+		if f.Raw != "" {
+			p.Fields = append(p.Fields, f)
+		}
+	}
+	if len(p.Fields) == 0 {
+		return b, fail(-1, "no field values")
+	}
+	if cv.ts >= 0 {
+		p.Time, p.HasTime = ts.UnixNano()/int64(cv.unit), true
+	}
+	return appendPoint(b, p), nil
+}
+
+// parseCSVTime parses a timestamp cell in format.
+func parseCSVTime(v, format string, loc *time.Location) (time.Time, error) {
+	unix := map[string]time.Duration{"unix_s": time.Second, "unix_ms": time.Millisecond, "unix_us": time.Microsecond, "unix_ns": time.Nanosecond}
+	switch format {
+	case "", "rfc3339":
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return t, fmt.Errorf("invalid RFC 3339 timestamp %q", v)
+		}
+		return t, nil
+	case "unix_s", "unix_ms", "unix_us", "unix_ns":
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			if unit := unix[format]; n > math.MaxInt64/int64(unit) || n < math.MinInt64/int64(unit) {
+				return time.Time{}, fmt.Errorf("timestamp %q out of range", v)
+			}
+			return time.Unix(0, n*int64(unix[format])), nil
+		}
+		// Decimals are read exactly; other forms, such as 1.7e9, as floats.
+		if whole, frac, ok := strings.Cut(v, "."); ok && len(frac) <= 9 && strings.Trim(frac, "0123456789") == "" {
+			if n, err := strconv.ParseInt(whole, 10, 64); err == nil && frac != "" {
+				unit := int64(unix[format])
+				f, _ := strconv.ParseInt((frac + "000000000")[:9], 10, 64)
+				f = f * unit / int64(time.Second)
+				if strings.HasPrefix(whole, "-") {
+					f = -f
+				}
+				if n >= math.MaxInt64/unit || n <= math.MinInt64/unit {
+					return time.Time{}, fmt.Errorf("timestamp %q out of range", v)
+				}
+				return time.Unix(0, n*unit+f), nil
+			}
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return time.Time{}, fmt.Errorf("invalid %s timestamp %q", format, v)
+		}
+		ns := f * float64(unix[format])
+		if ns > math.MaxInt64 || ns < math.MinInt64 {
+			return time.Time{}, fmt.Errorf("timestamp %q out of range", v)
+		}
+		return time.Unix(0, int64(math.Round(ns))), nil
+	default:
+		t, err := time.ParseInLocation(format, v, loc)
+		if err != nil {
+			return t, fmt.Errorf("timestamp %q does not match %q", v, format)
+		}
+		return t, nil
+	}
+}
+
+// csvFieldValue returns a field cell as a line protocol literal, or as a
+// VictoriaMetrics CSV number.
+func csvFieldValue(v, typ, format string) (string, error) {
+	switch typ {
+	case "float":
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || (format == "line" && (math.IsNaN(f) || math.IsInf(f, 0))) {
+			return "", fmt.Errorf("invalid float %q", v)
+		}
+		return strconv.FormatFloat(f, 'g', -1, 64), nil
+	case "integer":
+		if _, err := strconv.ParseInt(v, 10, 64); err != nil {
+			return "", fmt.Errorf("invalid integer %q", v)
+		}
+		if format == "csv" {
+			return v, nil
+		}
+		return v + "i", nil
+	case "unsigned":
+		if _, err := strconv.ParseUint(v, 10, 64); err != nil {
+			return "", fmt.Errorf("invalid unsigned integer %q", v)
+		}
+		if format == "csv" {
+			return v, nil
+		}
+		return v + "u", nil
+	case "boolean":
+		var b bool
+		switch strings.ToLower(v) {
+		case "true", "t", "yes", "y", "1":
+			b = true
+		case "false", "f", "no", "n", "0":
+		default:
+			return "", fmt.Errorf("invalid boolean %q", v)
+		}
+		switch {
+		case format == "csv" && b:
+			return "1", nil
+		case format == "csv":
+			return "0", nil
+		}
+		return strconv.FormatBool(b), nil
+	default:
+		v = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`).Replace(v)
+		return `"` + v + `"`, nil
+	}
+}
+
+// run reads src and converts up to maxRows data rows (all of them if
+// maxRows is 0), calling emit with each converted row and fail with each
+// row that could not be converted; fail returning false stops the run.
+func (cv *csvConverter) run(src io.Reader, maxRows int, emit func(row int, out []byte) error, fail func(*csvRowError) bool) error {
+	br := bufio.NewReaderSize(src, 64<<10)
+	var in io.Reader = br
+	if head, _ := br.Peek(2); len(head) == 2 && head[0] == 0x1f && head[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return &httpError{http.StatusBadRequest, "invalid gzip file: " + err.Error()}
+		}
+		in = zr
+	}
+	rd := csv.NewReader(in)
+	rd.Comma = cv.comma
+	rd.FieldsPerRecord = -1
+	rd.ReuseRecord = true
+
+	named := cv.m.Header == nil || *cv.m.Header
+	skip := cv.m.SkipRows
+	rows := 0
+	var out []byte
+	for maxRows == 0 || rows < maxRows {
+		rec, err := rd.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := rd.FieldPos(0)
+		if err != nil {
+			var pe *csv.ParseError
+			if !errors.As(err, &pe) {
+				return &httpError{http.StatusBadRequest, "reading the file: " + err.Error()}
+			}
+			// The reader cannot recover from a broken quote.
+			fail(&csvRowError{Row: pe.StartLine, Message: pe.Err.Error()})
+			return nil
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		if cv.columns == nil {
+			if err := cv.header(rec, named); err != nil {
+				return err
+			}
+			if named {
+				continue
+			}
+		}
+		rows++
+		var rerr *csvRowError
+		if out, rerr = cv.convert(out[:0], line, rec); rerr != nil {
+			if !fail(rerr) {
+				return nil
+			}
+			continue
+		}
+		if err := emit(line, out); err != nil {
+			return err
+		}
+	}
+	if cv.columns == nil {
+		return &httpError{http.StatusBadRequest, "The file has no rows"}
+	}
+	return nil
+}
+
+// csvInput reads the mapping and returns the CSV data of a request, with
+// the file name of a multipart upload.
+func csvInput(r *http.Request) (*csvMapping, io.Reader, string, error) {
+	bad := func(msg string) error { return &httpError{http.StatusBadRequest, msg} }
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch ct {
+	case "application/json":
+		var body struct {
+			Mapping *csvMapping `json:"mapping"`
+			CSV     string      `json:"csv"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return nil, nil, "", bad("Invalid JSON: " + err.Error())
+		}
+		if body.Mapping == nil {
+			return nil, nil, "", bad("mapping is required")
+		}
+		return body.Mapping, strings.NewReader(body.CSV), "", nil
+	case "multipart/form-data":
+		mr, err := r.MultipartReader()
+		if err != nil {
+			return nil, nil, "", bad(err.Error())
+		}
+		var m *csvMapping
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return nil, nil, "", bad("a file part is required")
+			}
+			if err != nil {
+				return nil, nil, "", bad("Invalid form: " + err.Error())
+			}
+			switch part.FormName() {
+			case "mapping":
+				m = &csvMapping{}
+				if err := json.NewDecoder(io.LimitReader(part, maxCSVMappingSize)).Decode(m); err != nil {
+					return nil, nil, "", bad("Invalid mapping: " + err.Error())
+				}
+			case "file":
+				if m == nil {
+					return nil, nil, "", bad("the mapping part must come before the file")
+				}
+				return m, part, part.FileName(), nil
+			}
+		}
+	default:
+		return nil, nil, "", &httpError{http.StatusUnsupportedMediaType, "Send application/json or multipart/form-data"}
+	}
+}
+
+// csvOutputFormat is the output format for a connection type.
+func csvOutputFormat(typ string) (string, error) {
+	switch {
+	case typ == "victoriametrics":
+		return "csv", nil
+	case strings.HasPrefix(typ, "influxdb"):
+		return "line", nil
+	}
+	return "", &httpError{http.StatusBadRequest, fmt.Sprintf("%s connections do not take CSV imports", typ)}
+}
+
+// csvPreviewHandler serves POST /api/v1/csv/preview. The output format
+// follows the connection parameter's type, or the format parameter (line or
+// csv, default line).
+func csvPreviewHandler(qr *queryRunner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setCORS(w)
+		switch r.Method {
+		case http.MethodOptions:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case http.MethodPost:
+		default:
+			jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		err := func() error {
+			q := r.URL.Query()
+			format := q.Get("format")
+			if name := q.Get("connection"); name != "" {
+				c := qr.conns.snapshot().find(name, "")
+				if c == nil {
+					return &httpError{http.StatusNotFound, fmt.Sprintf("unknown connection %q", name)}
+				}
+				var err error
+				if format, err = csvOutputFormat(c.Type); err != nil {
+					return err
+				}
+			}
+			switch format {
+			case "":
+				format = "line"
+			case "line", "csv":
+			default:
+				return &httpError{http.StatusBadRequest, fmt.Sprintf("invalid format %q", format)}
+			}
+			rows := defaultCSVPreviewRows
+			if s := q.Get("rows"); s != "" {
+				n, err := strconv.Atoi(s)
+				if err != nil || n < 1 || n > maxCSVPreviewRows {
+					return &httpError{http.StatusBadRequest, fmt.Sprintf("rows must be from 1 to %d", maxCSVPreviewRows)}
+				}
+				rows = n
+			}
+
+			m, data, _, err := csvInput(r)
+			if err != nil {
+				return err
+			}
+			cv, err := newCSVConverter(m, format)
+			if err != nil {
+				return err
+			}
+			res := csvPreview{Format: format, Precision: cv.precision, Rows: []csvPreviewRow{}, Errors: []csvRowError{}}
+			err = cv.run(data, rows, func(row int, out []byte) error {
+				res.Rows = append(res.Rows, csvPreviewRow{row, strings.TrimSuffix(string(out), "\n")})
+				return nil
+			}, func(e *csvRowError) bool {
+				res.Errors = append(res.Errors, *e)
+				res.ErrorCount++
+				return true
+			})
+			if err != nil {
+				return err
+			}
+			res.Columns, res.CSVFormat = cv.columns, cv.vmFormat
+			writeJSON(w, http.StatusOK, res)
+			return nil
+		}()
+		if err != nil {
+			jsonError(w, httpErrorStatus(err, http.StatusBadRequest), err.Error())
+		}
+	}
+}
+
+// csvImportHandler serves POST /api/v1/csv/import: it converts the whole
+// file into the job's staged file and queues an upload job to write it. A
+// file with rows that do not convert is refused as a whole.
+func csvImportHandler(jr *jobRunner, qr *queryRunner, envs map[string]*proxyEnv, jobsPath string, maxSize int64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setCORS(w)
+		switch r.Method {
+		case http.MethodOptions:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case http.MethodPost:
+		default:
+			jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		body := limitUpload(r, maxSize)
+		p, err := uploadParamsFromQuery(r.URL.Query())
+		var c *CLIConnection
+		var format string
+		if err == nil {
+			if p.Format != "" || p.CSVFormat != "" {
+				err = &httpError{http.StatusBadRequest, "format and csvFormat follow from the connection and mapping"}
+			} else if c = qr.conns.snapshot().find(p.Connection, ""); c != nil {
+				format, err = csvOutputFormat(c.Type)
+			}
+		}
+		var m *csvMapping
+		var data io.Reader
+		var cv *csvConverter
+		if err == nil {
+			m, data, p.Name, err = csvInput(r)
+		}
+		if err == nil && c != nil {
+			if cv, err = newCSVConverter(m, format); err == nil && format == "line" {
+				if p.Precision != "" && p.Precision != cv.precision {
+					err = &httpError{http.StatusBadRequest, "precision is set by the mapping"}
+				}
+				p.Precision = cv.precision
+			}
+		}
+		if err == nil {
+			if format == "csv" {
+				p.Format, p.CSVFormat = "csv", cv.vmFormat
+			}
+			c, err = p.check(qr)
+		}
+		if err == nil {
+			err = envs[c.Type].authorizeWrite(r, c, p.Path)
+		}
+		if err != nil {
+			err = body.err(err)
+			jsonError(w, httpErrorStatus(err, http.StatusBadRequest), err.Error())
+			return
+		}
+		params, _ := json.Marshal(p)
+		jr.start(w, r, jobsPath, "upload", params, func(path string) error {
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			bw := bufio.NewWriterSize(f, 64<<10)
+			var first *csvRowError
+			errs, rows := 0, 0
+			err = cv.run(data, 0, func(_ int, out []byte) error {
+				rows++
+				_, err := bw.Write(out)
+				return err
+			}, func(e *csvRowError) bool {
+				if first == nil {
+					first = e
+				}
+				errs++
+				return true
+			})
+			switch {
+			case err != nil:
+				return body.err(err)
+			case errs == 1:
+				return &httpError{http.StatusBadRequest, "1 row could not be converted: " + first.Error()}
+			case errs > 1:
+				return &httpError{http.StatusBadRequest, fmt.Sprintf("%d rows could not be converted; the first: %s", errs, first.Error())}
+			case rows == 0:
+				return &httpError{http.StatusBadRequest, "The file has no data rows"}
+			}
+			if err := bw.Flush(); err != nil {
+				return err
+			}
+			return f.Close()
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package timeseriesui
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCSVPreview(t *testing.T) {
+	h := newTestHandler(t, Options{})
+	mapping := `{
+		"timestamp": {"column": "time", "format": "unix_s"},
+		"measurement": "weather",
+		"tags": [{"column": "city"}],
+		"fields": [{"column": "temp", "name": "temperature"}, {"column": "count", "type": "integer"}]
+	}`
+	data := "time,city,temp,count\n1700000000,Oslo,-1.5,3\n1700000060,New York,x,4\n1700000120,Rome,20,5\n"
+	body, _ := json.Marshal(map[string]interface{}{"mapping": json.RawMessage(mapping), "csv": data})
+
+	cases := []struct {
+		format, csvFormat string
+		rows              []string
+	}{
+		{"line", "", []string{
+			"weather,city=Oslo temperature=-1.5,count=3i 1700000000000000000",
+			"weather,city=Rome temperature=20,count=5i 1700000120000000000",
+		}},
+		{"csv", "1:time:unix_ms,2:label:city,3:metric:weather_temperature,4:metric:weather_count", []string{
+			"1700000000000,Oslo,-1.5,3",
+			"1700000120000,Rome,20,5",
+		}},
+	}
+	for _, c := range cases {
+		rec := serve(h, http.MethodPost, "/api/v1/csv/preview?format="+c.format, strings.NewReader(string(body)), map[string]string{"Content-Type": "application/json"})
+		var res csvPreview
+		if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", c.format, rec.Code, rec.Body)
+		}
+		var got []string
+		for _, r := range res.Rows {
+			got = append(got, r.Output)
+		}
+		if strings.Join(got, "\n") != strings.Join(c.rows, "\n") {
+			t.Errorf("%s: rows\n%s\nwant\n%s", c.format, strings.Join(got, "\n"), strings.Join(c.rows, "\n"))
+		}
+		if res.CSVFormat != c.csvFormat {
+			t.Errorf("%s: csvFormat %q, want %q", c.format, res.CSVFormat, c.csvFormat)
+		}
+		if res.ErrorCount != 1 || res.Errors[0].Row != 3 || res.Errors[0].Column != "temp" {
+			t.Errorf("%s: errors %+v", c.format, res.Errors)
+		}
+	}
+}
+
+func TestCSVMappingErrors(t *testing.T) {
+	h := newTestHandler(t, Options{})
+	cases := []struct{ format, mapping, message string }{
+		{"line", `{"measurement": "m", "fields": [{"column": "missing"}]}`, `no column "missing" in the header`},
+		{"line", `{"measurement": "m", "fields": [{"column": "9"}], "header": false}`, "must be a position from 1 to 2"},
+		{"line", `{"measurement": "m", "fields": [{"column": "v", "type": "decimal"}]}`, `invalid type "decimal"`},
+		{"line", `{"measurement": "m", "fields": [{"column": "v"}, {"column": "2", "name": "v"}]}`, `duplicate field "v"`},
+		{"csv", `{"measurement": "m", "fields": [{"column": "v", "type": "string"}]}`, "cannot be imported into victoriametrics"},
+	}
+	for _, c := range cases {
+		body, _ := json.Marshal(map[string]interface{}{"mapping": json.RawMessage(c.mapping), "csv": "k,v\na,1\n"})
+		rec := serve(h, http.MethodPost, "/api/v1/csv/preview?format="+c.format, strings.NewReader(string(body)), map[string]string{"Content-Type": "application/json"})
+		var res struct{ Error string }
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(res.Error, c.message) {
+			t.Errorf("%s %s: status %d: %s", c.format, c.mapping, rec.Code, rec.Body)
+		}
+	}
+	if rec := serve(h, http.MethodPost, "/api/v1/csv/preview", strings.NewReader("k,v"), map[string]string{"Content-Type": "text/csv"}); rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("text/csv body: status %d", rec.Code)
+	}
+}
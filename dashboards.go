@@ -0,0 +1,503 @@
+package timeseriesui
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ── Dashboards ──────────────────────────────────────────────────────────────
+//
+// Dashboards are shared by every user of the instance and persisted, with
+// their version history, to dashboards.json in the data directory:
+//
+//	GET    /api/v1/dashboards?q=&tag=&team=
+//	POST   /api/v1/dashboards
+//	GET    /api/v1/dashboards/<id>
+//	PUT    /api/v1/dashboards/<id>                   (If-Match: "<version>" or "version")
+//	DELETE /api/v1/dashboards/<id>
+//	GET    /api/v1/dashboards/<id>/versions
+//	GET    /api/v1/dashboards/<id>/versions/<n>
+//	POST   /api/v1/dashboards/<id>/versions/<n>/restore
+//	POST   /api/v1/dashboards/import/grafana?prometheus=&influxdb=&dryRun=
+//
+// Queries may reference variables as $name or ${name}; they are expanded by
+// the UI.
+
+// dashboardMaxVersions bounds the history kept per dashboard.
+const dashboardMaxVersions = 50
+
+// dashboard is a set of panels laid out on a 24-column grid.
+type dashboard struct {
+	ID          string              `json:"id"`
+	Title       string              `json:"title"`
+	Description string              `json:"description,omitempty"`
+	Tags        []string            `json:"tags,omitempty"`
+	Team        string              `json:"team,omitempty"`
+	From        string              `json:"from,omitempty"` // default time range, e.g. "now-6h"
+	To          string              `json:"to,omitempty"`
+	Refresh     string              `json:"refresh,omitempty"`
+	Variables   []dashboardVariable `json:"variables,omitempty"`
+	Panels      []dashboardPanel    `json:"panels"`
+	Version     int                 `json:"version"`
+	Message     string              `json:"message,omitempty"` // describes the change, kept in the history
+	CreatedBy   string              `json:"createdBy,omitempty"`
+	CreatedAt   time.Time           `json:"createdAt"`
+	UpdatedBy   string              `json:"updatedBy,omitempty"`
+	UpdatedAt   time.Time           `json:"updatedAt"`
+}
+
+// dashboardPanel shows the results of its queries against one connection.
+type dashboardPanel struct {
+	ID            int                    `json:"id"`
+	Title         string                 `json:"title,omitempty"`
+	Visualization string                 `json:"visualization"`
+	Connection    string                 `json:"connection,omitempty"` // name or URL
+	Type          string                 `json:"type,omitempty"`       // backend type, when no connection is set
+	Queries       []panelQuery           `json:"queries,omitempty"`
+	Layout        panelLayout            `json:"layout"`
+	Unit          string                 `json:"unit,omitempty"`
+	Options       map[string]interface{} `json:"options,omitempty"`
+}
+
+// panelQuery is one query of a panel.
+type panelQuery struct {
+	RefID    string `json:"refId,omitempty"`
+	Language string `json:"language,omitempty"` // "promql", "metricsql", "influxql", …
+	Query    string `json:"query"`
+	Legend   string `json:"legend,omitempty"`
+}
+
+// panelLayout is a panel's position on the grid.
+type panelLayout struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// dashboardVariable is substituted into panel queries.
+type dashboardVariable struct {
+	Name       string   `json:"name"`
+	Label      string   `json:"label,omitempty"`
+	Kind       string   `json:"kind"`                 // query, custom, constant, interval or textbox
+	Connection string   `json:"connection,omitempty"` // for query variables
+	Query      string   `json:"query,omitempty"`      // for query variables
+	Options    []string `json:"options,omitempty"`    // for custom and interval variables
+	Default    string   `json:"default,omitempty"`
+	Multi      bool     `json:"multi,omitempty"`
+	IncludeAll bool     `json:"includeAll,omitempty"`
+}
+
+const dashboardGridColumns = 24
+
+var (
+	panelVisualizations = map[string]bool{
+		"timeseries": true, "stat": true, "gauge": true, "bar": true, "table": true, "text": true,
+	}
+	variableKinds = map[string]bool{
+		"query": true, "custom": true, "constant": true, "interval": true, "textbox": true,
+	}
+	variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// validate checks the fields a client may set and numbers panels without
+// a unique ID.
+func (d *dashboard) validate() error {
+	d.Title = strings.TrimSpace(d.Title)
+	if d.Title == "" {
+		return fmt.Errorf("title is required")
+	}
+	seen := map[string]bool{}
+	for i := range d.Variables {
+		v := &d.Variables[i]
+		switch {
+		case !variableName.MatchString(v.Name):
+			return fmt.Errorf("variable %d: invalid name %q", i+1, v.Name)
+		case seen[v.Name]:
+			return fmt.Errorf("variable %q is defined twice", v.Name)
+		case !variableKinds[v.Kind]:
+			return fmt.Errorf("variable %q: unknown kind %q", v.Name, v.Kind)
+		case v.Kind == "query" && (v.Connection == "" || v.Query == ""):
+			return fmt.Errorf("variable %q: query variables need a connection and a query", v.Name)
+		}
+		seen[v.Name] = true
+	}
+	ids := map[int]bool{}
+	for _, p := range d.Panels {
+		ids[p.ID] = true
+	}
+	next := 1
+	taken := map[int]bool{}
+	for i := range d.Panels {
+		p := &d.Panels[i]
+		if p.ID <= 0 || taken[p.ID] {
+			for ids[next] {
+				next++
+			}
+			p.ID = next
+			ids[next] = true
+		}
+		taken[p.ID] = true
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("panel %d: %w", p.ID, err)
+		}
+	}
+	if d.Panels == nil {
+		d.Panels = []dashboardPanel{}
+	}
+	return nil
+}
+
+func (p *dashboardPanel) validate() error {
+	l := p.Layout
+	switch {
+	case !panelVisualizations[p.Visualization]:
+		return fmt.Errorf("unknown visualization %q", p.Visualization)
+	case p.Visualization != "text" && len(p.Queries) == 0:
+		return fmt.Errorf("at least one query is required")
+	case p.Visualization != "text" && p.Connection == "" && p.Type == "":
+		return fmt.Errorf("connection or type is required")
+	case l.X < 0 || l.Y < 0 || l.W < 1 || l.H < 1 || l.X+l.W > dashboardGridColumns:
+		return fmt.Errorf("layout must fit a %d-column grid", dashboardGridColumns)
+	}
+	if p.Type != "" {
+		if _, ok := lookupBackend(p.Type); !ok {
+			return fmt.Errorf("unknown type %q", p.Type)
+		}
+	}
+	for i, q := range p.Queries {
+		if strings.TrimSpace(q.Query) == "" {
+			return fmt.Errorf("query %d is empty", i+1)
+		}
+	}
+	return nil
+}
+
+// dashboardRecord is a dashboard with its history, oldest version first.
+// The last version is the current dashboard.
+type dashboardRecord struct {
+	Versions []*dashboard `json:"versions"`
+}
+
+func (rec *dashboardRecord) current() *dashboard { return rec.Versions[len(rec.Versions)-1] }
+
+// version returns the dashboard at version n.
+func (rec *dashboardRecord) version(n int) (*dashboard, bool) {
+	for _, d := range rec.Versions {
+		if d.Version == n {
+			return d, true
+		}
+	}
+	return nil, false
+}
+
+// dashboardStore holds the dashboards in memory and, with a path, on disk.
+type dashboardStore struct {
+	mu      sync.Mutex
+	records map[string]*dashboardRecord
+	path    string
+}
+
+func newDashboardStore(dir string) (*dashboardStore, error) {
+	s := &dashboardStore{records: map[string]*dashboardRecord{}}
+	if dir == "" {
+		return s, nil
+	}
+	s.path = filepath.Join(dir, "dashboards.json")
+	var list []*dashboardRecord
+	if err := loadJSONFile(s.path, &list); err != nil {
+		return nil, err
+	}
+	for _, rec := range list {
+		if len(rec.Versions) > 0 {
+			s.records[rec.current().ID] = rec
+		}
+	}
+	return s, nil
+}
+
+// save persists the dashboards; the caller holds mu.
+func (s *dashboardStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	list := make([]*dashboardRecord, 0, len(s.records))
+	for _, rec := range s.records {
+		list = append(list, rec)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].current().ID < list[j].current().ID })
+	return saveJSONFile(s.path, list)
+}
+
+// create stores d as a new dashboard at version 1; the caller holds mu.
+func (s *dashboardStore) create(d *dashboard, user string) error {
+	now := time.Now().UTC()
+	d.ID, d.Version = newID(), 1
+	d.CreatedBy, d.CreatedAt = user, now
+	d.UpdatedBy, d.UpdatedAt = user, now
+	s.records[d.ID] = &dashboardRecord{Versions: []*dashboard{d}}
+	if err := s.save(); err != nil {
+		delete(s.records, d.ID)
+		return err
+	}
+	return nil
+}
+
+// update stores d as the next version of rec; the caller holds mu.
+func (s *dashboardStore) update(rec *dashboardRecord, d *dashboard, user string) error {
+	cur := rec.current()
+	d.ID, d.Version = cur.ID, cur.Version+1
+	d.CreatedBy, d.CreatedAt = cur.CreatedBy, cur.CreatedAt
+	d.UpdatedBy, d.UpdatedAt = user, time.Now().UTC()
+	prev := rec.Versions
+	rec.Versions = append(rec.Versions[:len(rec.Versions):len(rec.Versions)], d)
+	if n := len(rec.Versions) - dashboardMaxVersions; n > 0 {
+		rec.Versions = rec.Versions[n:]
+	}
+	if err := s.save(); err != nil {
+		rec.Versions = prev
+		return err
+	}
+	return nil
+}
+
+// dashboardSummary is a list entry.
+type dashboardSummary struct {
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	Team        string    `json:"team,omitempty"`
+	Panels      int       `json:"panels"`
+	Version     int       `json:"version"`
+	UpdatedBy   string    `json:"updatedBy,omitempty"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// dashboardVersionInfo describes one version in the history.
+type dashboardVersionInfo struct {
+	Version   int       `json:"version"`
+	Message   string    `json:"message,omitempty"`
+	UpdatedBy string    `json:"updatedBy,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// handler serves the dashboard API. user may be nil.
+func (s *dashboardStore) handler(prefix string, user func(*http.Request) string) http.HandlerFunc {
+	userOf := func(r *http.Request) string {
+		if user == nil {
+			return ""
+		}
+		return user(r)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		setCORS(w)
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/"), "/")
+		u := userOf(r)
+		serveLocked(&s.mu, w, r, func(w http.ResponseWriter, r *http.Request) {
+			s.serve(w, r, parts, u)
+		})
+	}
+}
+
+// serve routes a dashboard API request; the caller holds mu.
+func (s *dashboardStore) serve(w http.ResponseWriter, r *http.Request, parts []string, user string) {
+	switch {
+	case parts[0] == "":
+		s.serveCollection(w, r, user)
+		return
+	case len(parts) == 2 && parts[0] == "import" && parts[1] == "grafana":
+		s.serveGrafanaImport(w, r, user)
+		return
+	}
+
+	rec, ok := s.records[parts[0]]
+	if !ok {
+		jsonError(w, http.StatusNotFound, "Dashboard not found")
+		return
+	}
+	switch {
+	case len(parts) == 1:
+		s.serveDashboard(w, r, rec, user)
+	case len(parts) == 2 && parts[1] == "versions" && r.Method == http.MethodGet:
+		out := make([]dashboardVersionInfo, 0, len(rec.Versions))
+		for i := len(rec.Versions) - 1; i >= 0; i-- {
+			d := rec.Versions[i]
+			out = append(out, dashboardVersionInfo{d.Version, d.Message, d.UpdatedBy, d.UpdatedAt})
+		}
+		writeJSON(w, http.StatusOK, out)
+	case len(parts) == 3 && parts[1] == "versions" && r.Method == http.MethodGet,
+		len(parts) == 4 && parts[1] == "versions" && parts[3] == "restore" && r.Method == http.MethodPost:
+		n, _ := strconv.Atoi(parts[2])
+		old, ok := rec.version(n)
+		if !ok {
+			jsonError(w, http.StatusNotFound, "Dashboard version not found")
+			return
+		}
+		if len(parts) == 3 {
+			writeJSON(w, http.StatusOK, old)
+			return
+		}
+		cur := rec.current()
+		if v := expectedVersion(r, 0); v != 0 && v != cur.Version {
+			jsonError(w, http.StatusConflict, fmt.Sprintf("Dashboard was modified (version %d, expected %d)", cur.Version, v))
+			return
+		}
+		d := *old
+		d.Message = fmt.Sprintf("Restored version %d", n)
+		if err := s.update(rec, &d, user); err != nil {
+			jsonError(w, http.StatusInternalServerError, "Failed to save: "+err.Error())
+			return
+		}
+		w.Header().Set("ETag", versionETag(d.Version))
+		writeJSON(w, http.StatusOK, &d)
+	default:
+		jsonError(w, http.StatusNotFound, "Not found")
+	}
+}
+
+// serveCollection lists and creates dashboards; the caller holds mu.
+func (s *dashboardStore) serveCollection(w http.ResponseWriter, r *http.Request, user string) {
+	switch r.Method {
+	case http.MethodGet:
+		p := r.URL.Query()
+		search := strings.ToLower(p.Get("q"))
+		out := []dashboardSummary{}
+		for _, rec := range s.records {
+			d := rec.current()
+			switch {
+			case search != "" && !strings.Contains(strings.ToLower(d.Title+"\n"+d.Description+"\n"+strings.Join(d.Tags, "\n")), search),
+				p.Get("tag") != "" && !containsFold(d.Tags, p.Get("tag")),
+				p.Get("team") != "" && !strings.EqualFold(d.Team, p.Get("team")):
+				continue
+			}
+			out = append(out, dashboardSummary{d.ID, d.Title, d.Description, d.Tags, d.Team, len(d.Panels), d.Version, d.UpdatedBy, d.UpdatedAt})
+		}
+		sort.Slice(out, func(i, j int) bool {
+			if !strings.EqualFold(out[i].Title, out[j].Title) {
+				return strings.ToLower(out[i].Title) < strings.ToLower(out[j].Title)
+			}
+			return out[i].ID < out[j].ID
+		})
+		writeJSON(w, http.StatusOK, out)
+	case http.MethodPost:
+		var d dashboard
+		if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+			jsonError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+		if err := d.validate(); err != nil {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := s.create(&d, user); err != nil {
+			jsonError(w, http.StatusInternalServerError, "Failed to save: "+err.Error())
+			return
+		}
+		w.Header().Set("ETag", versionETag(d.Version))
+		writeJSON(w, http.StatusCreated, &d)
+	default:
+		jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// serveDashboard reads, replaces and deletes one dashboard; the caller
+// holds mu.
+func (s *dashboardStore) serveDashboard(w http.ResponseWriter, r *http.Request, rec *dashboardRecord, user string) {
+	cur := rec.current()
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("ETag", versionETag(cur.Version))
+		writeJSON(w, http.StatusOK, cur)
+	case http.MethodPut:
+		var d dashboard
+		if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+			jsonError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+		switch v := expectedVersion(r, d.Version); {
+		case v == 0:
+			jsonError(w, http.StatusPreconditionRequired, "Send the version being updated as If-Match or in the body")
+			return
+		case v != cur.Version:
+			w.Header().Set("ETag", versionETag(cur.Version))
+			writeJSON(w, http.StatusConflict, map[string]interface{}{
+				"error":   fmt.Sprintf("Dashboard was modified (version %d, expected %d)", cur.Version, v),
+				"current": cur,
+			})
+			return
+		}
+		if err := d.validate(); err != nil {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := s.update(rec, &d, user); err != nil {
+			jsonError(w, http.StatusInternalServerError, "Failed to save: "+err.Error())
+			return
+		}
+		w.Header().Set("ETag", versionETag(d.Version))
+		writeJSON(w, http.StatusOK, &d)
+	case http.MethodDelete:
+		if v := expectedVersion(r, 0); v != 0 && v != cur.Version {
+			jsonError(w, http.StatusConflict, fmt.Sprintf("Dashboard was modified (version %d, expected %d)", cur.Version, v))
+			return
+		}
+		delete(s.records, cur.ID)
+		if err := s.save(); err != nil {
+			s.records[cur.ID] = rec
+			jsonError(w, http.StatusInternalServerError, "Failed to save: "+err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// serveGrafanaImport converts a Grafana dashboard and, unless dryRun is
+// set, saves it; the caller holds mu.
+func (s *dashboardStore) serveGrafanaImport(w http.ResponseWriter, r *http.Request, user string) {
+	if r.Method != http.MethodPost {
+		jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	var raw json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		jsonError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+		return
+	}
+	p := r.URL.Query()
+	d, skipped, err := convertGrafanaDashboard(raw, grafanaImportOptions{
+		prometheus: p.Get("prometheus"),
+		influxdb:   p.Get("influxdb"),
+	})
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "Invalid Grafana dashboard: "+err.Error())
+		return
+	}
+	if err := d.validate(); err != nil {
+		jsonError(w, http.StatusBadRequest, "Converted dashboard is invalid: "+err.Error())
+		return
+	}
+	status := http.StatusOK
+	if dry, _ := strconv.ParseBool(p.Get("dryRun")); !dry {
+		if err := s.create(d, user); err != nil {
+			jsonError(w, http.StatusInternalServerError, "Failed to save: "+err.Error())
+			return
+		}
+		status = http.StatusCreated
+	}
+	writeJSON(w, status, map[string]interface{}{"dashboard": d, "skipped": skipped})
+}
@@ -0,0 +1,57 @@
+package timeseriesui
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDashboardVersions(t *testing.T) {
+	h := newTestHandler(t, Options{DataDir: t.TempDir()})
+	panel := `{"visualization": "timeseries", "type": "prometheus", "queries": [{"query": "up"}], "layout": {"w": 12, "h": 8}}`
+	rec := serve(h, http.MethodPost, "/api/v1/dashboards", strings.NewReader(`{"title": "v1", "panels": [`+panel+`]}`), nil)
+	var d dashboard
+	if err := json.Unmarshal(rec.Body.Bytes(), &d); err != nil || rec.Code != http.StatusCreated || d.Version != 1 {
+		t.Fatalf("create: status %d: %s", rec.Code, rec.Body)
+	}
+	path := "/api/v1/dashboards/" + d.ID
+	v2 := `{"title": "v2", "message": "rename", "panels": [` + panel + `]}`
+	if rec := serve(h, http.MethodPut, path, strings.NewReader(v2), nil); rec.Code != http.StatusPreconditionRequired {
+		t.Errorf("update without a version: status %d", rec.Code)
+	}
+	if rec := serve(h, http.MethodPut, path, strings.NewReader(v2), map[string]string{"If-Match": `"1"`}); rec.Code != http.StatusOK {
+		t.Fatalf("update: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(h, http.MethodPut, path, strings.NewReader(v2), map[string]string{"If-Match": `"1"`}); rec.Code != http.StatusConflict {
+		t.Errorf("stale update: status %d", rec.Code)
+	}
+	if rec := serve(h, http.MethodGet, path+"/versions/1", nil, nil); !strings.Contains(rec.Body.String(), `"title":"v1"`) {
+		t.Errorf("version 1: status %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = serve(h, http.MethodPost, path+"/versions/1/restore", nil, nil)
+	if err := json.Unmarshal(rec.Body.Bytes(), &d); err != nil || d.Title != "v1" || d.Version != 3 {
+		t.Errorf("restore: status %d: %s", rec.Code, rec.Body)
+	}
+	var versions []struct{ Version int }
+	rec = serve(h, http.MethodGet, path+"/versions", nil, nil)
+	if err := json.Unmarshal(rec.Body.Bytes(), &versions); err != nil || len(versions) != 3 || versions[0].Version != 3 {
+		t.Errorf("versions: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(h, http.MethodPost, "/api/v1/dashboards", strings.NewReader(`{"title": "bad", "panels": [{"visualization": "pie", "layout": {"w": 12, "h": 8}}]}`), nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid visualization: status %d", rec.Code)
+	}
+}
+
+func TestGrafanaImportDryRun(t *testing.T) {
+	h := newTestHandler(t, Options{})
+	body := `{"title": "G", "panels": [{"id": 1, "type": "stat", "datasource": "Prom", "targets": [{"refId": "A", "expr": "up"}]}]}`
+	rec := serve(h, http.MethodPost, "/api/v1/dashboards/import/grafana?dryRun=true", strings.NewReader(body), nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"connection":"Prom"`) {
+		t.Errorf("dry run: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(h, http.MethodGet, "/api/v1/dashboards", nil, nil); strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("dry run saved a dashboard: %s", rec.Body)
+	}
+}
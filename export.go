@@ -0,0 +1,447 @@
+package timeseriesui
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ── Query export ────────────────────────────────────────────────────────────
+//
+// /api/v1/export runs a query over a long range as a series of time windows
+// and streams the rows as a download:
+//
+//	GET  /api/v1/export?<query fields>&format=csv|ndjson|parquet&window=&compress=gzip&filename=
+//	POST /api/v1/export  (the same fields as a JSON object)
+//
+// Each window is one /api/v1/query sub-request, so the proxy's response size
+// limit and timeouts apply per window and only one window is held in memory.
+// Errors after the first window end the stream early and are reported in
+// the X-Export-Error trailer (and as a final line of CSV and NDJSON).
+
+// exportRequest is a queryRequest with output options.
+type exportRequest struct {
+	queryRequest
+	Format   string `json:"format,omitempty"`   // csv (default), ndjson or parquet
+	Window   string `json:"window,omitempty"`   // duration of each sub-query (default 1h)
+	Compress string `json:"compress,omitempty"` // "gzip" for a .gz download
+	Filename string `json:"filename,omitempty"`
+}
+
+const (
+	defaultExportWindow = time.Hour
+	defaultExportStep   = time.Minute
+	minExportWindow     = time.Second
+	maxExportWindows    = 100000
+)
+
+// exportFormats maps formats to file extensions and content types.
+var exportFormats = map[string][2]string{
+	"csv":     {"csv", "text/csv; charset=utf-8"},
+	"ndjson":  {"ndjson", "application/x-ndjson"},
+	"parquet": {"parquet", "application/vnd.apache.parquet"},
+}
+
+// exportRow is one value of a frame: CSV and Parquet have a row per value.
+type exportRow struct {
+	time   *int64
+	name   string
+	labels string // JSON object
+	field  string
+	value  interface{}
+}
+
+// frameRows flattens f into exportRows.
+func frameRows(f *queryFrame) []exportRow {
+	labels := "{}"
+	if len(f.Labels) > 0 {
+		b, _ := json.Marshal(f.Labels)
+		labels = string(b)
+	}
+	timeIdx := -1
+	for i, fd := range f.Fields {
+		if fd.Type == "time" {
+			timeIdx = i
+			break
+		}
+	}
+	var rows []exportRow
+	for i, fd := range f.Fields {
+		if i == timeIdx {
+			continue
+		}
+		for j, v := range fd.Values {
+			row := exportRow{name: f.Name, labels: labels, field: fd.Name, value: v}
+			if timeIdx >= 0 {
+				if ts, ok := f.Fields[timeIdx].Values[j].(int64); ok {
+					row.time = &ts
+				}
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// exportWriter encodes frames in one output format.
+type exportWriter interface {
+	writeFrames(frames []queryFrame) error
+	// fail records a mid-stream error in the output, where the format allows.
+	fail(err error)
+	close() error
+}
+
+func newExportWriter(format string, w io.Writer) (exportWriter, error) {
+	switch format {
+	case "ndjson":
+		return &ndjsonExport{enc: json.NewEncoder(w)}, nil
+	case "parquet":
+		pw, err := newParquetWriter(w, []parquetColumn{
+			{name: "time", typ: parquetInt64, converted: parquetTimestampMillis, optional: true},
+			{name: "name", typ: parquetByteArray, converted: parquetUTF8},
+			{name: "labels", typ: parquetByteArray, converted: parquetUTF8},
+			{name: "field", typ: parquetByteArray, converted: parquetUTF8},
+			{name: "value", typ: parquetDouble, converted: -1, optional: true},
+			{name: "text", typ: parquetByteArray, converted: parquetUTF8, optional: true},
+		})
+		return &parquetExport{pw: pw}, err
+	}
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"time", "name", "labels", "field", "value"})
+	return &csvExport{cw: cw}, nil
+}
+
+// csvExport writes a row per value: time (RFC 3339), name, labels, field
+// and value.
+type csvExport struct{ cw *csv.Writer }
+
+func (e *csvExport) writeFrames(frames []queryFrame) error {
+	for i := range frames {
+		for _, row := range frameRows(&frames[i]) {
+			ts := ""
+			if row.time != nil {
+				ts = time.UnixMilli(*row.time).UTC().Format("2006-01-02T15:04:05.000Z07:00")
+			}
+			val := ""
+			switch v := row.value.(type) {
+			case nil:
+			case float64:
+				val = strconv.FormatFloat(v, 'g', -1, 64)
+			default:
+				val = fmt.Sprint(v)
+			}
+			e.cw.Write([]string{ts, row.name, row.labels, row.field, val})
+		}
+	}
+	e.cw.Flush()
+	return e.cw.Error()
+}
+
+func (e *csvExport) fail(err error) {
+	e.cw.Write([]string{"# error: " + err.Error()})
+	e.cw.Flush()
+}
+
+func (e *csvExport) close() error { e.cw.Flush(); return e.cw.Error() }
+
+// ndjsonExport writes a JSON object per frame row with name, labels, time
+// (Unix milliseconds) and one key per field.
+type ndjsonExport struct{ enc *json.Encoder }
+
+func (e *ndjsonExport) writeFrames(frames []queryFrame) error {
+	for _, f := range frames {
+		rows := 0
+		for _, fd := range f.Fields {
+			if len(fd.Values) > rows {
+				rows = len(fd.Values)
+			}
+		}
+		for j := 0; j < rows; j++ {
+			obj := map[string]interface{}{"name": f.Name, "labels": f.Labels}
+			for _, fd := range f.Fields {
+				if j < len(fd.Values) {
+					obj[fd.Name] = fd.Values[j]
+				}
+			}
+			if err := e.enc.Encode(obj); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (e *ndjsonExport) fail(err error) { e.enc.Encode(map[string]string{"error": err.Error()}) }
+
+func (e *ndjsonExport) close() error { return nil }
+
+// parquetExport writes the CSV columns, with numbers in value and strings
+// and booleans in text.
+type parquetExport struct{ pw *parquetWriter }
+
+func (e *parquetExport) writeFrames(frames []queryFrame) error {
+	for i := range frames {
+		for _, row := range frameRows(&frames[i]) {
+			rec := []interface{}{nil, row.name, row.labels, row.field, nil, nil}
+			if row.time != nil {
+				rec[0] = *row.time
+			}
+			switch v := row.value.(type) {
+			case nil:
+			case float64:
+				rec[4] = v
+			case json.Number:
+				if f, err := v.Float64(); err == nil {
+					rec[4] = f
+				} else {
+					rec[5] = v.String()
+				}
+			case string:
+				rec[5] = v
+			default:
+				rec[5] = fmt.Sprint(v)
+			}
+			if err := e.pw.add(rec); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fail leaves the file without a footer, which readers reject.
+func (e *parquetExport) fail(error) {}
+
+func (e *parquetExport) close() error { return e.pw.close() }
+
+// exportHandler serves /api/v1/export.
+func (qr *queryRunner) exportHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setCORS(w)
+		var req exportRequest
+		switch r.Method {
+		case http.MethodOptions:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case http.MethodGet:
+			p := r.URL.Query()
+			req = exportRequest{
+				queryRequest: queryRequestFromParams(p),
+				Format:       p.Get("format"),
+				Window:       p.Get("window"),
+				Compress:     p.Get("compress"),
+				Filename:     p.Get("filename"),
+			}
+		case http.MethodPost:
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				jsonError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+				return
+			}
+		default:
+			jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		if err := qr.export(w, r, &req); err != nil {
+			jsonError(w, httpErrorStatus(err, http.StatusBadGateway), err.Error())
+		}
+	}
+}
+
+// exportPlan is a validated export: the query, its windows and the file it
+// produces.
+type exportPlan struct {
+	req         *exportRequest
+	pq          *preparedQuery
+	windows     []exportWindow
+	filename    string
+	contentType string
+}
+
+// planExport validates req and splits its range into windows.
+func (qr *queryRunner) planExport(req *exportRequest) (*exportPlan, error) {
+	if req.Format == "" {
+		req.Format = "csv"
+	}
+	ft, ok := exportFormats[req.Format]
+	if !ok {
+		return nil, &httpError{http.StatusBadRequest, "format must be csv, ndjson or parquet"}
+	}
+	if req.Compress != "" && req.Compress != "gzip" {
+		return nil, &httpError{http.StatusBadRequest, "compress must be gzip"}
+	}
+	window := defaultExportWindow
+	if req.Window != "" {
+		d, err := parseLongDuration(req.Window)
+		if err != nil {
+			return nil, &httpError{http.StatusBadRequest, "Invalid window: " + err.Error()}
+		}
+		window = d
+	}
+	if window < minExportWindow {
+		return nil, &httpError{http.StatusBadRequest, "window must be at least " + minExportWindow.String()}
+	}
+	if req.Step == "" {
+		req.Step = defaultExportStep.String()
+	}
+	pq, err := qr.prepare(&req.queryRequest)
+	if err != nil {
+		return nil, err
+	}
+	// The range only reaches an InfluxQL query through $timeFilter.
+	if pq.meta.Language == "influxql" && !pq.start.IsZero() && !strings.Contains(pq.query, "$timeFilter") {
+		return nil, &httpError{http.StatusBadRequest, "InfluxQL exports need $timeFilter in the WHERE clause to apply the time range"}
+	}
+	if err := checkExportWindows(pq, window); err != nil {
+		return nil, err
+	}
+	p := &exportPlan{req: req, pq: pq, windows: exportWindows(pq, window), contentType: ft[1]}
+
+	filename := req.Filename
+	if filename == "" {
+		filename = "export-" + time.Now().UTC().Format("20060102-150405")
+	}
+	filename = strings.Map(func(c rune) rune {
+		if c == '"' || c == '\\' || c == '/' || c < ' ' {
+			return '_'
+		}
+		return c
+	}, strings.TrimSuffix(filename, "."+ft[0]))
+	p.filename = filename + "." + ft[0]
+	if req.Compress == "gzip" {
+		p.filename += ".gz"
+		p.contentType = "application/gzip"
+	}
+	return p, nil
+}
+
+// export streams req to w. An error is returned only while nothing has been
+// written yet.
+func (qr *queryRunner) export(w http.ResponseWriter, r *http.Request, req *exportRequest) error {
+	p, err := qr.planExport(req)
+	if err != nil {
+		return err
+	}
+	// Run the first window before committing to a response, so that bad
+	// queries and unreachable backends get a proper error status.
+	first, err := qr.exec(r, p.pq, p.windows[0].start, p.windows[0].end, p.windows[0].exclusive)
+	if err != nil {
+		return err
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", p.contentType)
+	h.Set("Content-Disposition", `attachment; filename="`+p.filename+`"`)
+	h.Set("X-Accel-Buffering", "no")
+	h.Set("Trailer", "X-Export-Error")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	if err := qr.writeExport(w, r, p, first, func(int) { rc.Flush() }); err != nil {
+		h.Set("X-Export-Error", err.Error())
+	}
+	return nil
+}
+
+// writeExport fetches the windows of p — reusing first for the first window
+// when it is set — and writes them to out. progress is called with the
+// number of windows written after each one.
+func (qr *queryRunner) writeExport(out io.Writer, r *http.Request, p *exportPlan, first *queryResult, progress func(done int)) error {
+	var gz *gzip.Writer
+	if p.req.Compress == "gzip" {
+		gz = gzip.NewWriter(out)
+		out = gz
+	}
+	ew, err := newExportWriter(p.req.Format, out)
+	for i, win := range p.windows {
+		if err != nil {
+			break
+		}
+		res := first
+		if i > 0 || res == nil {
+			if res, err = qr.exec(r, p.pq, win.start, win.end, win.exclusive); err != nil {
+				break
+			}
+		}
+		err = ew.writeFrames(res.Frames)
+		if gz != nil {
+			gz.Flush()
+		}
+		progress(i + 1)
+	}
+	if err == nil {
+		err = ew.close()
+	} else if ew != nil {
+		ew.fail(err)
+	}
+	if gz != nil {
+		if cerr := gz.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// exportWindow is one sub-query of an export.
+type exportWindow struct {
+	start, end time.Time
+	exclusive  bool // end is excluded (InfluxQL windows but the last)
+}
+
+// checkExportWindows refuses ranges that exportWindows would split into
+// more than maxExportWindows windows of size.
+func checkExportWindows(pq *preparedQuery, size time.Duration) error {
+	if pq.start.IsZero() {
+		return nil
+	}
+	span := size
+	if pq.meta.Language != "influxql" {
+		span = size / pq.step * pq.step
+		if span < pq.step {
+			span = pq.step
+		}
+	}
+	if n := pq.end.Sub(pq.start)/span + 1; n > maxExportWindows {
+		return &httpError{http.StatusBadRequest, fmt.Sprintf("The range needs %d windows of %s, more than %d; use a larger window", n, size, maxExportWindows)}
+	}
+	return nil
+}
+
+// exportWindows splits pq's range into windows of about size. PromQL windows
+// hold whole steps and abut, so no point is fetched twice; InfluxQL windows
+// are half-open.
+func exportWindows(pq *preparedQuery, size time.Duration) []exportWindow {
+	if pq.start.IsZero() {
+		return []exportWindow{{end: pq.end}}
+	}
+	var out []exportWindow
+	if pq.meta.Language == "influxql" {
+		for s := pq.start; ; s = s.Add(size) {
+			e := s.Add(size)
+			if !e.Before(pq.end) {
+				return append(out, exportWindow{start: s, end: pq.end})
+			}
+			out = append(out, exportWindow{start: s, end: e, exclusive: true})
+		}
+	}
+	n := size / pq.step
+	if n < 1 {
+		n = 1
+	}
+	for s := pq.start; ; {
+		e := s.Add((n - 1) * pq.step)
+		if !e.Before(pq.end) {
+			return append(out, exportWindow{start: s, end: pq.end})
+		}
+		out = append(out, exportWindow{start: s, end: e})
+		s = e.Add(pq.step)
+		if s.After(pq.end) {
+			return out
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package timeseriesui
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportWindows(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	pq := &preparedQuery{meta: queryMeta{Language: "promql"}, start: start, end: start.Add(10 * time.Minute), step: time.Minute}
+	got := exportWindows(pq, 4*time.Minute)
+	want := [][2]int{{0, 3}, {4, 7}, {8, 10}}
+	if len(got) != len(want) {
+		t.Fatalf("promql windows %v", got)
+	}
+	for i, w := range want {
+		if got[i].start != start.Add(time.Duration(w[0])*time.Minute) || got[i].end != start.Add(time.Duration(w[1])*time.Minute) || got[i].exclusive {
+			t.Errorf("promql window %d = %+v, want minutes %v", i, got[i], w)
+		}
+	}
+
+	pq.meta.Language = "influxql"
+	got = exportWindows(pq, 4*time.Minute)
+	want = [][2]int{{0, 4}, {4, 8}, {8, 10}}
+	if len(got) != len(want) {
+		t.Fatalf("influxql windows %v", got)
+	}
+	for i, w := range want {
+		if got[i].start != start.Add(time.Duration(w[0])*time.Minute) || got[i].end != start.Add(time.Duration(w[1])*time.Minute) || got[i].exclusive != (i < len(want)-1) {
+			t.Errorf("influxql window %d = %+v, want minutes %v", i, got[i], w)
+		}
+	}
+}
+
+func TestExportWindowLimits(t *testing.T) {
+	h := newTestHandler(t, Options{
+		Connections: []CLIConnection{{Name: "prom", Type: "prometheus", URL: "http://127.0.0.1:1"}},
+	})
+	cases := []struct{ start, window, step, message string }{
+		{"now-1h", "0s", "", "window"},
+		{"now-1h", "10ms", "", "window must be"},
+		{"now-30d", "1s", "1s", "windows"},
+		{"now-30d", "2s", "1ms", "windows"},
+	}
+	for _, c := range cases {
+		q := url.Values{"connection": {"prom"}, "query": {"up"}, "start": {c.start}, "window": {c.window}, "step": {c.step}}
+		rec := serve(h, http.MethodGet, "/api/v1/export?"+q.Encode(), nil, nil)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), c.message) {
+			t.Errorf("%+v: status %d: %s", c, rec.Code, rec.Body)
+		}
+	}
+}
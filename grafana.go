@@ -0,0 +1,576 @@
+package timeseriesui
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ── Grafana dashboard import ────────────────────────────────────────────────
+//
+// convertGrafanaDashboard turns a subset of Grafana dashboard JSON into a
+// dashboard: graph, time series, stat, gauge, bar, table and text panels with
+// Prometheus, VictoriaMetrics and InfluxQL queries (raw or built with the
+// query editor), in rows or not, and query, custom, constant, interval and
+// textbox variables. Everything else is reported as skipped.
+
+// grafanaImportOptions name the connections used for panels whose Grafana
+// datasource is of that type; without one, the datasource's name is used.
+type grafanaImportOptions struct {
+	prometheus string
+	influxdb   string
+}
+
+// grafanaSkipped reports a part of the Grafana dashboard that was dropped.
+type grafanaSkipped struct {
+	Kind   string `json:"kind"` // "panel", "query" or "variable"
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+type grafanaDashboard struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	Time        struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	} `json:"time"`
+	Refresh    json.RawMessage `json:"refresh"` // a string, or false
+	Panels     []grafanaPanel  `json:"panels"`
+	Rows       []grafanaRow    `json:"rows"` // before schema version 16
+	Templating struct {
+		List []grafanaVariable `json:"list"`
+	} `json:"templating"`
+	Inputs []struct {
+		Name     string `json:"name"`
+		PluginID string `json:"pluginId"`
+	} `json:"__inputs"`
+}
+
+type grafanaRow struct {
+	Title  string          `json:"title"`
+	Height json.RawMessage `json:"height"` // "250px" or 250
+	Panels []grafanaPanel  `json:"panels"`
+}
+
+type grafanaPanel struct {
+	ID         int             `json:"id"`
+	Title      string          `json:"title"`
+	Type       string          `json:"type"`
+	Datasource json.RawMessage `json:"datasource"`
+	Targets    []grafanaTarget `json:"targets"`
+	GridPos    *panelLayout    `json:"gridPos"`
+	Span       float64         `json:"span"`   // 12-column width in old rows
+	Panels     []grafanaPanel  `json:"panels"` // of a collapsed row
+	Content    string          `json:"content"`
+	Mode       string          `json:"mode"`
+	Format     string          `json:"format"`
+	Yaxes      []struct {
+		Format string `json:"format"`
+	} `json:"yaxes"`
+	FieldConfig struct {
+		Defaults struct {
+			Unit string `json:"unit"`
+		} `json:"defaults"`
+	} `json:"fieldConfig"`
+	Options struct {
+		Content string `json:"content"`
+		Mode    string `json:"mode"`
+	} `json:"options"`
+}
+
+type grafanaTarget struct {
+	RefID        string          `json:"refId"`
+	Datasource   json.RawMessage `json:"datasource"`
+	Hide         bool            `json:"hide"`
+	Expr         string          `json:"expr"`
+	LegendFormat string          `json:"legendFormat"`
+	Query        string          `json:"query"`
+	RawQuery     bool            `json:"rawQuery"`
+	Alias        string          `json:"alias"`
+	Measurement  string          `json:"measurement"`
+	Policy       string          `json:"policy"`
+	Select       [][]grafanaPart `json:"select"`
+	Tags         []struct {
+		Key       string `json:"key"`
+		Operator  string `json:"operator"`
+		Value     string `json:"value"`
+		Condition string `json:"condition"`
+	} `json:"tags"`
+	GroupBy []grafanaPart `json:"groupBy"`
+}
+
+type grafanaPart struct {
+	Type   string        `json:"type"`
+	Params []interface{} `json:"params"`
+}
+
+func (p grafanaPart) param(i int) string {
+	if i < len(p.Params) {
+		return fmt.Sprint(p.Params[i])
+	}
+	return ""
+}
+
+type grafanaVariable struct {
+	Name       string          `json:"name"`
+	Label      string          `json:"label"`
+	Type       string          `json:"type"`
+	Datasource json.RawMessage `json:"datasource"`
+	Query      json.RawMessage `json:"query"` // a string, or {"query": …}
+	Multi      bool            `json:"multi"`
+	IncludeAll bool            `json:"includeAll"`
+	Current    struct {
+		Value json.RawMessage `json:"value"` // a string or a list
+	} `json:"current"`
+}
+
+// grafanaVisualizations maps Grafana panel types to visualizations.
+var grafanaVisualizations = map[string]string{
+	"graph": "timeseries", "timeseries": "timeseries",
+	"stat": "stat", "singlestat": "stat",
+	"gauge":    "gauge",
+	"bargauge": "bar", "barchart": "bar",
+	"table": "table", "table-old": "table",
+	"text": "text",
+}
+
+// grafanaBackends maps Grafana datasource plugins to backend types and
+// query languages.
+var grafanaBackends = map[string][2]string{
+	"prometheus":                         {"prometheus", "promql"},
+	"victoriametrics-datasource":         {"victoriametrics", "metricsql"},
+	"victoriametrics-metrics-datasource": {"victoriametrics", "metricsql"},
+	"influxdb":                           {"influxdb", "influxql"},
+}
+
+// grafanaImporter carries the state of one conversion.
+type grafanaImporter struct {
+	opts    grafanaImportOptions
+	inputs  map[string]string // __inputs name → plugin
+	skipped []grafanaSkipped
+}
+
+func (im *grafanaImporter) skip(kind, name, reason string) {
+	im.skipped = append(im.skipped, grafanaSkipped{kind, name, reason})
+}
+
+func convertGrafanaDashboard(raw []byte, opts grafanaImportOptions) (*dashboard, []grafanaSkipped, error) {
+	// Accept the /api/dashboards/uid/<uid> response as well as the export.
+	var wrapper struct {
+		Dashboard json.RawMessage `json:"dashboard"`
+	}
+	if json.Unmarshal(raw, &wrapper) == nil && len(wrapper.Dashboard) > 0 && wrapper.Dashboard[0] == '{' {
+		raw = wrapper.Dashboard
+	}
+	var g grafanaDashboard
+	if err := json.Unmarshal(raw, &g); err != nil {
+		return nil, nil, err
+	}
+	if g.Title == "" {
+		return nil, nil, fmt.Errorf("no title")
+	}
+
+	im := &grafanaImporter{opts: opts, inputs: map[string]string{}, skipped: []grafanaSkipped{}}
+	for _, in := range g.Inputs {
+		im.inputs[in.Name] = in.PluginID
+	}
+	d := &dashboard{
+		Title:       g.Title,
+		Description: g.Description,
+		Tags:        g.Tags,
+		From:        g.Time.From,
+		To:          g.Time.To,
+		Panels:      []dashboardPanel{},
+	}
+	json.Unmarshal(g.Refresh, &d.Refresh)
+
+	for _, v := range g.Templating.List {
+		if dv, ok := im.variable(&v); ok {
+			d.Variables = append(d.Variables, dv)
+		}
+	}
+
+	if len(g.Panels) > 0 {
+		for i := range g.Panels {
+			p := &g.Panels[i]
+			if p.Type == "row" {
+				for j := range p.Panels {
+					im.panel(d, &p.Panels[j], nil)
+				}
+				continue
+			}
+			im.panel(d, p, nil)
+		}
+	} else {
+		y := 0
+		for _, row := range g.Rows {
+			h := grafanaRowHeight(row.Height)
+			x := 0
+			for i := range row.Panels {
+				p := &row.Panels[i]
+				w := int(p.Span * 2)
+				if w < 1 || w > dashboardGridColumns {
+					w = dashboardGridColumns
+				}
+				if x+w > dashboardGridColumns {
+					x, y = 0, y+h
+				}
+				im.panel(d, p, &panelLayout{X: x, Y: y, W: w, H: h})
+				x += w
+			}
+			y += h
+		}
+	}
+	return d, im.skipped, nil
+}
+
+// grafanaRowHeight converts an old row height in pixels to grid units of
+// about 30 pixels.
+func grafanaRowHeight(raw json.RawMessage) int {
+	var px float64
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		fmt.Sscanf(strings.TrimSuffix(s, "px"), "%g", &px)
+	} else {
+		json.Unmarshal(raw, &px)
+	}
+	if h := int(px+29) / 30; h > 0 {
+		return h
+	}
+	return 8
+}
+
+// panel converts p and appends it to d unless it has to be skipped. layout
+// overrides the panel's gridPos.
+func (im *grafanaImporter) panel(d *dashboard, p *grafanaPanel, layout *panelLayout) {
+	name := p.Title
+	if name == "" {
+		name = fmt.Sprintf("#%d", p.ID)
+	}
+	vis, ok := grafanaVisualizations[p.Type]
+	if !ok {
+		im.skip("panel", name, fmt.Sprintf("unsupported panel type %q", p.Type))
+		return
+	}
+	dp := dashboardPanel{ID: p.ID, Title: p.Title, Visualization: vis, Unit: p.FieldConfig.Defaults.Unit}
+	switch {
+	case layout != nil:
+		dp.Layout = *layout
+	case p.GridPos != nil:
+		dp.Layout = *p.GridPos
+	default:
+		dp.Layout = panelLayout{W: 12, H: 8}
+	}
+	if dp.Layout.W < 1 || dp.Layout.W > dashboardGridColumns {
+		dp.Layout.W = dashboardGridColumns
+	}
+	if dp.Layout.X+dp.Layout.W > dashboardGridColumns {
+		dp.Layout.X = dashboardGridColumns - dp.Layout.W
+	}
+	if dp.Layout.H < 1 {
+		dp.Layout.H = 8
+	}
+	if dp.Unit == "" {
+		dp.Unit = p.Format
+		if len(p.Yaxes) > 0 {
+			dp.Unit = p.Yaxes[0].Format
+		}
+		if dp.Unit == "short" || dp.Unit == "none" {
+			dp.Unit = ""
+		}
+	}
+
+	if vis == "text" {
+		content, mode := p.Options.Content, p.Options.Mode
+		if content == "" {
+			content, mode = p.Content, p.Mode
+		}
+		dp.Options = map[string]interface{}{"content": content}
+		if mode != "" {
+			dp.Options["mode"] = mode
+		}
+		d.Panels = append(d.Panels, dp)
+		return
+	}
+
+	panelPlugin, panelName := im.datasource(p.Datasource)
+	for _, t := range p.Targets {
+		ref := name + "/" + t.RefID
+		if t.Hide {
+			im.skip("query", ref, "hidden query")
+			continue
+		}
+		plugin, dsName := panelPlugin, panelName
+		if len(t.Datasource) > 0 && string(t.Datasource) != "null" {
+			plugin, dsName = im.datasource(t.Datasource)
+		}
+		if plugin == "" || plugin == "-- Mixed --" {
+			switch {
+			case t.Expr != "":
+				plugin = "prometheus"
+			case t.Query != "" || t.Measurement != "":
+				plugin = "influxdb"
+			}
+		}
+		be, ok := grafanaBackends[plugin]
+		if !ok {
+			im.skip("query", ref, fmt.Sprintf("unsupported datasource %q", plugin))
+			continue
+		}
+		conn := im.connection(be[0], dsName)
+		if dp.Type == "" {
+			dp.Type, dp.Connection = be[0], conn
+		} else if dp.Type != be[0] || dp.Connection != conn {
+			im.skip("query", ref, "panel mixes datasources; only the first one is kept")
+			continue
+		}
+		q := panelQuery{RefID: t.RefID, Language: be[1]}
+		if be[0] == "influxdb" {
+			query, err := influxQLFromGrafana(&t)
+			if err != nil {
+				im.skip("query", ref, err.Error())
+				continue
+			}
+			q.Query, q.Legend = query, t.Alias
+		} else {
+			q.Query, q.Legend = t.Expr, t.LegendFormat
+		}
+		if strings.TrimSpace(q.Query) == "" {
+			im.skip("query", ref, "empty query")
+			continue
+		}
+		q.Query = grafanaVariableSyntax(q.Query)
+		dp.Queries = append(dp.Queries, q)
+	}
+	if len(dp.Queries) == 0 {
+		im.skip("panel", name, "no supported queries")
+		return
+	}
+	d.Panels = append(d.Panels, dp)
+}
+
+// datasource resolves a datasource reference — a name, "${DS_…}" input, or
+// {"type", "uid"} object — to its plugin and name. Both are empty for the
+// default datasource.
+func (im *grafanaImporter) datasource(raw json.RawMessage) (plugin, name string) {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		if in := strings.Trim(strings.TrimPrefix(s, "$"), "{}"); im.inputs[in] != "" {
+			return im.inputs[in], ""
+		}
+		if s == "-- Mixed --" || s == "-- Grafana --" {
+			return s, ""
+		}
+		return "", s
+	}
+	var obj struct {
+		Type string `json:"type"`
+		UID  string `json:"uid"`
+	}
+	if json.Unmarshal(raw, &obj) == nil {
+		if in := strings.Trim(strings.TrimPrefix(obj.UID, "$"), "{}"); obj.Type == "" && im.inputs[in] != "" {
+			return im.inputs[in], ""
+		}
+		if strings.HasPrefix(obj.UID, "$") {
+			obj.UID = ""
+		}
+		return obj.Type, obj.UID
+	}
+	return "", ""
+}
+
+// connection picks the connection for a datasource of backend type typ.
+func (im *grafanaImporter) connection(typ, dsName string) string {
+	switch {
+	case (typ == "prometheus" || typ == "victoriametrics") && im.opts.prometheus != "":
+		return im.opts.prometheus
+	case typ == "influxdb" && im.opts.influxdb != "":
+		return im.opts.influxdb
+	case strings.HasPrefix(dsName, "$"):
+		return ""
+	}
+	return dsName
+}
+
+func (im *grafanaImporter) variable(v *grafanaVariable) (dashboardVariable, bool) {
+	dv := dashboardVariable{Name: v.Name, Label: v.Label, Kind: v.Type, Multi: v.Multi, IncludeAll: v.IncludeAll}
+	var query string
+	if json.Unmarshal(v.Query, &query) != nil {
+		var obj struct {
+			Query string `json:"query"`
+		}
+		json.Unmarshal(v.Query, &obj)
+		query = obj.Query
+	}
+	var cur []string
+	if json.Unmarshal(v.Current.Value, &cur) != nil {
+		var s string
+		json.Unmarshal(v.Current.Value, &s)
+		cur = []string{s}
+	}
+	if len(cur) > 0 {
+		dv.Default = cur[0]
+	}
+
+	switch v.Type {
+	case "query":
+		plugin, name := im.datasource(v.Datasource)
+		be, ok := grafanaBackends[plugin]
+		if !ok && plugin == "" && query != "" {
+			if strings.HasPrefix(strings.ToUpper(strings.TrimSpace(query)), "SHOW ") {
+				be, ok = grafanaBackends["influxdb"], true
+			} else {
+				be, ok = grafanaBackends["prometheus"], true
+			}
+		}
+		if !ok {
+			im.skip("variable", v.Name, "datasource type could not be determined")
+			return dv, false
+		}
+		if dv.Connection = im.connection(be[0], name); dv.Connection == "" {
+			im.skip("variable", v.Name, fmt.Sprintf("no connection for its datasource; pass ?%s=<connection>", strings.Replace(be[0], "victoriametrics", "prometheus", 1)))
+			return dv, false
+		}
+		dv.Query = grafanaVariableSyntax(query)
+	case "custom", "interval":
+		for _, o := range strings.Split(query, ",") {
+			if o = strings.TrimSpace(o); o != "" {
+				// "key : value" pairs keep the value.
+				if _, val, ok := strings.Cut(o, " : "); ok {
+					o = strings.TrimSpace(val)
+				}
+				dv.Options = append(dv.Options, o)
+			}
+		}
+	case "constant", "textbox":
+		if dv.Default == "" {
+			dv.Default = query
+		}
+	default:
+		im.skip("variable", v.Name, fmt.Sprintf("unsupported variable type %q", v.Type))
+		return dv, false
+	}
+	if !variableName.MatchString(dv.Name) {
+		im.skip("variable", v.Name, "invalid name")
+		return dv, false
+	}
+	return dv, true
+}
+
+var grafanaBracketVar = regexp.MustCompile(`\[\[([A-Za-z_][A-Za-z0-9_]*)(?::[a-z]+)?\]\]`)
+
+// grafanaVariableSyntax rewrites the deprecated [[name]] syntax to ${name}.
+func grafanaVariableSyntax(q string) string {
+	return grafanaBracketVar.ReplaceAllString(q, "$${$1}")
+}
+
+// influxQLFromGrafana returns a target's raw InfluxQL, or builds it from the
+// query editor's select, tag and group-by parts.
+func influxQLFromGrafana(t *grafanaTarget) (string, error) {
+	if t.RawQuery || (t.Measurement == "" && t.Query != "") {
+		if strings.Contains(t.Query, "from(bucket") || strings.Contains(t.Query, "|>") {
+			return "", fmt.Errorf("Flux queries are not supported")
+		}
+		return t.Query, nil
+	}
+	if t.Measurement == "" {
+		return "", fmt.Errorf("no measurement")
+	}
+
+	var fields []string
+	for _, parts := range t.Select {
+		expr, alias := "", ""
+		for _, p := range parts {
+			switch p.Type {
+			case "field":
+				expr = quoteInfluxIdent(p.param(0))
+			case "mean", "sum", "count", "max", "min", "last", "first", "median", "mode",
+				"distinct", "spread", "stddev", "integral", "cumulative_sum", "difference",
+				"non_negative_difference", "elapsed":
+				expr = p.Type + "(" + expr + ")"
+			case "percentile", "top", "bottom", "moving_average", "holt_winters",
+				"derivative", "non_negative_derivative":
+				if p.param(0) != "" {
+					expr = p.Type + "(" + expr + ", " + p.param(0) + ")"
+				} else {
+					expr = p.Type + "(" + expr + ")"
+				}
+			case "math":
+				expr += " " + p.param(0)
+			case "alias":
+				alias = p.param(0)
+			default:
+				return "", fmt.Errorf("unsupported InfluxQL part %q", p.Type)
+			}
+		}
+		if expr == "" {
+			continue
+		}
+		if alias != "" {
+			expr += " AS " + quoteInfluxIdent(alias)
+		}
+		fields = append(fields, expr)
+	}
+	if len(fields) == 0 {
+		fields = []string{`mean("value")`}
+	}
+
+	from := quoteInfluxIdent(t.Measurement)
+	if t.Policy != "" && t.Policy != "default" {
+		from = quoteInfluxIdent(t.Policy) + "." + from
+	}
+	var where strings.Builder
+	for i, tag := range t.Tags {
+		if i > 0 {
+			cond := tag.Condition
+			if cond == "" {
+				cond = "AND"
+			}
+			where.WriteString(" " + cond + " ")
+		}
+		op, val := tag.Operator, tag.Value
+		if op == "" {
+			op = "="
+			if strings.HasPrefix(val, "/") && strings.HasSuffix(val, "/") {
+				op = "=~"
+			}
+		}
+		if op != "=~" && op != "!~" {
+			val = "'" + strings.ReplaceAll(val, "'", `\'`) + "'"
+		}
+		where.WriteString(quoteInfluxIdent(tag.Key) + " " + op + " " + val)
+	}
+	q := "SELECT " + strings.Join(fields, ", ") + " FROM " + from + " WHERE "
+	if where.Len() > 0 {
+		q += "(" + where.String() + ") AND "
+	}
+	q += "$timeFilter"
+
+	var groups []string
+	fill := ""
+	for _, g := range t.GroupBy {
+		switch g.Type {
+		case "time":
+			groups = append(groups, "time("+g.param(0)+")")
+		case "tag":
+			groups = append(groups, quoteInfluxIdent(g.param(0)))
+		case "fill":
+			fill = " fill(" + g.param(0) + ")"
+		}
+	}
+	if len(groups) > 0 {
+		q += " GROUP BY " + strings.Join(groups, ", ")
+	}
+	return q + fill, nil
+}
+
+// quoteInfluxIdent double-quotes an InfluxQL identifier; "*" is kept as is.
+func quoteInfluxIdent(s string) string {
+	if s == "*" {
+		return s
+	}
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
@@ -0,0 +1,75 @@
+package timeseriesui
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConvertGrafanaDashboard(t *testing.T) {
+	raw := []byte(`{"dashboard": {
+		"title": "Hosts",
+		"tags": ["infra"],
+		"time": {"from": "now-6h", "to": "now"},
+		"refresh": "30s",
+		"__inputs": [{"name": "DS_PROM", "pluginId": "prometheus"}],
+		"templating": {"list": [
+			{"name": "host", "type": "query", "datasource": "${DS_PROM}", "query": {"query": "label_values(up, instance)"}, "current": {"value": ["a"]}},
+			{"name": "step", "type": "interval", "query": "1m,5m"},
+			{"name": "ds", "type": "datasource"}
+		]},
+		"panels": [
+			{"id": 1, "title": "CPU", "type": "timeseries", "datasource": "${DS_PROM}", "gridPos": {"x": 0, "y": 0, "w": 12, "h": 8},
+			 "fieldConfig": {"defaults": {"unit": "percent"}},
+			 "targets": [
+				{"refId": "A", "expr": "rate(cpu{instance=\"[[host]]\"}[5m])", "legendFormat": "{{instance}}"},
+				{"refId": "B", "expr": "up", "hide": true}
+			 ]},
+			{"id": 2, "type": "row", "panels": [
+				{"id": 3, "title": "Load", "type": "graph", "datasource": {"type": "influxdb", "uid": "influx"},
+				 "targets": [
+					{"refId": "A", "measurement": "system", "policy": "default",
+					 "select": [[{"type": "field", "params": ["load1"]}, {"type": "mean", "params": []}]],
+					 "tags": [{"key": "host", "operator": "=~", "value": "/^$host$/"}],
+					 "groupBy": [{"type": "time", "params": ["$__interval"]}, {"type": "fill", "params": ["null"]}]},
+					{"refId": "B", "query": "from(bucket: \"b\") |> range(start: -1h)", "rawQuery": true}
+				 ]}
+			]},
+			{"id": 4, "title": "Share", "type": "piechart"}
+		]
+	}}`)
+	d, skipped, err := convertGrafanaDashboard(raw, grafanaImportOptions{prometheus: "prom", influxdb: "influx-prod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Title != "Hosts" || d.From != "now-6h" || d.Refresh != "30s" || len(d.Panels) != 2 || len(d.Variables) != 2 {
+		t.Fatalf("dashboard %+v", d)
+	}
+
+	cpu := d.Panels[0]
+	want := []panelQuery{{RefID: "A", Language: "promql", Query: `rate(cpu{instance="${host}"}[5m])`, Legend: "{{instance}}"}}
+	if cpu.Type != "prometheus" || cpu.Connection != "prom" || cpu.Unit != "percent" || cpu.Layout != (panelLayout{0, 0, 12, 8}) || !reflect.DeepEqual(cpu.Queries, want) {
+		t.Errorf("CPU panel %+v", cpu)
+	}
+	load := d.Panels[1]
+	want = []panelQuery{{RefID: "A", Language: "influxql", Query: `SELECT mean("load1") FROM "system" WHERE ("host" =~ /^$host$/) AND $timeFilter GROUP BY time($__interval) fill(null)`}}
+	if load.Type != "influxdb" || load.Connection != "influx-prod" || load.Visualization != "timeseries" || !reflect.DeepEqual(load.Queries, want) {
+		t.Errorf("Load panel %+v", load)
+	}
+
+	host := d.Variables[0]
+	if host.Kind != "query" || host.Connection != "prom" || host.Query != "label_values(up, instance)" || host.Default != "a" {
+		t.Errorf("host variable %+v", host)
+	}
+	if step := d.Variables[1]; !reflect.DeepEqual(step.Options, []string{"1m", "5m"}) {
+		t.Errorf("step variable %+v", step)
+	}
+
+	var reasons []string
+	for _, s := range skipped {
+		reasons = append(reasons, s.Kind+" "+s.Name)
+	}
+	wantSkipped := []string{"variable ds", "query CPU/B", "query Load/B", "panel Share"}
+	if !reflect.DeepEqual(reasons, wantSkipped) {
+		t.Errorf("skipped %v, want %v", reasons, wantSkipped)
+	}
+}
@@ -0,0 +1,166 @@
+package timeseriesui
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ── Graphite ────────────────────────────────────────────────────────────────
+//
+// graphite connections speak the graphite-web HTTP API: /render for data,
+// /metrics/find and /metrics/expand for browsing the metric tree, and
+// /version for health. VictoriaMetrics serves the same API (single-node at
+// its root, cluster under /select/<tenant>/graphite), so a graphite
+// connection can point at either; health falls back to /health there.
+//
+// The proxy's own target parameter names the backend, so Graphite render
+// targets are passed as gtarget and renamed on the way upstream. (POSTed
+// form bodies with target= are forwarded untouched.)
+//
+// With shape=matrix, /render output is converted into the Prometheus matrix
+// shape. With shape=buildinfo, /version is answered like Prometheus'
+// /api/v1/status/buildinfo so the UI's connection ping works unchanged.
+
+func init() { registerBackend(&graphiteBackend{}) }
+
+type graphiteBackend struct {
+	baseBackend
+	flags connFlags
+}
+
+func (*graphiteBackend) Type() string        { return "graphite" }
+func (*graphiteBackend) DisplayName() string { return "Graphite" }
+func (*graphiteBackend) HealthPath() string  { return "/version" }
+
+// HealthFallbackPath is probed on servers without /version, such as
+// VictoriaMetrics.
+func (*graphiteBackend) HealthFallbackPath() string { return "/health" }
+
+func (b *graphiteBackend) RegisterFlags(fs *flag.FlagSet) {
+	b.flags.register(fs, "graphite", "Graphite")
+	b.flags.registerAuth(fs, "graphite", "Graphite", "basic-auth ")
+}
+
+func (b *graphiteBackend) FlagConnections() []CLIConnection {
+	return b.flags.build(b, nil)
+}
+
+// Classify treats tag registration as a write and tag deletion as admin.
+// Paths are matched cleaned.
+func (*graphiteBackend) Classify(r *http.Request, t *proxyTarget) apiKind {
+	switch classifyPath(t.Path) {
+	case "/tags/tagSeries", "/tags/tagMultiSeries":
+		return apiWrite
+	case "/tags/delSeries":
+		return apiAdmin
+	}
+	return apiRead
+}
+
+// graphiteSeries is one element of a /render?format=json response.
+type graphiteSeries struct {
+	Target     string            `json:"target"`
+	Tags       map[string]string `json:"tags"`
+	Datapoints [][2]*float64     `json:"datapoints"`
+}
+
+// Route renames gtarget to target, strips the proxy-only shape parameter
+// and forces JSON output from /render.
+func (*graphiteBackend) Route(r *http.Request, t *proxyTarget) error {
+	if gt, ok := t.Params["gtarget"]; ok {
+		t.Params["target"] = gt
+		t.Params.Del("gtarget")
+	}
+	shape := t.Params.Get("shape")
+	t.Params.Del("shape")
+	if t.Path == "/render" && shape == "matrix" {
+		t.Params.Set("format", "json")
+	}
+	return nil
+}
+
+// Handler wraps the generic proxy with render and version reshaping.
+func (*graphiteBackend) Handler(env *proxyEnv) http.HandlerFunc {
+	generic := makeGenericProxy(env)
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch {
+		case q.Get("path") == "/render" && q.Get("shape") == "matrix":
+			setCORS(w)
+			convertProxy(w, r, env, nil, nil, convertGraphiteRender)
+		case q.Get("path") == "/version" && q.Get("shape") == "buildinfo":
+			setCORS(w)
+			graphiteBuildInfo(w, r, env)
+		default:
+			generic(w, r)
+		}
+	}
+}
+
+// convertGraphiteRender converts /render JSON into a Prometheus matrix. The
+// target becomes __name__ and Graphite tags become labels; null datapoints
+// are dropped.
+func convertGraphiteRender(w http.ResponseWriter, body io.Reader) error {
+	var in []graphiteSeries
+	if err := json.NewDecoder(body).Decode(&in); err != nil {
+		return err
+	}
+	out := make([]promMatrixSeries, 0, len(in))
+	for _, s := range in {
+		metric := map[string]string{}
+		for k, v := range s.Tags {
+			if k != "name" {
+				metric[k] = v
+			}
+		}
+		metric["__name__"] = s.Target
+		ps := promMatrixSeries{Metric: metric, Values: make([][2]interface{}, 0, len(s.Datapoints))}
+		for _, dp := range s.Datapoints {
+			if dp[0] == nil || dp[1] == nil {
+				continue
+			}
+			ps.Values = append(ps.Values, promSample(*dp[1], *dp[0]))
+		}
+		out = append(out, ps)
+	}
+	writeMatrix(w, out)
+	return nil
+}
+
+// graphiteBuildInfo probes /version and reports it in the buildinfo shape.
+// VictoriaMetrics has no /version, so a 404 falls back to /health.
+func graphiteBuildInfo(w http.ResponseWriter, r *http.Request, env *proxyEnv) {
+	proxyReq, client, ok := buildProxyRequest(w, r, env, nil)
+	if !ok {
+		return
+	}
+	version := ""
+	resp, err := client.Do(proxyReq)
+	if err == nil && resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		proxyReq.URL.Path = strings.TrimSuffix(proxyReq.URL.Path, "/version") + "/health"
+		resp, err = client.Do(proxyReq)
+		version = "unknown"
+	}
+	if err != nil {
+		jsonError(w, http.StatusBadGateway, "Connection failed: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		copyResponse(w, resp)
+		return
+	}
+	if version == "" {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		version = strings.TrimSpace(string(b))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   map[string]string{"version": version},
+	})
+}
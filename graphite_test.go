@@ -0,0 +1,30 @@
+package timeseriesui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGraphiteClassify(t *testing.T) {
+	cases := []struct {
+		path string
+		want apiKind
+	}{
+		{"/tags/tagSeries", apiWrite},
+		{"//tags/tagSeries", apiWrite},
+		{"/tags/./tagMultiSeries", apiWrite},
+		{"/tags/delSeries", apiAdmin},
+		{"/tags/delSeries/", apiAdmin},
+		{"/metrics/../tags/delSeries", apiAdmin},
+		{"/render", apiRead},
+		{"/metrics/find", apiRead},
+	}
+	b := backends["graphite"]
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		if got := b.Classify(r, &proxyTarget{Path: c.path}); got != c.want {
+			t.Errorf("%s: %v, want %v", c.path, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,417 @@
+package timeseriesui
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ── Query history ───────────────────────────────────────────────────────────
+//
+// Every InfluxQL, PromQL and MetricsQL query sent through the proxy is
+// recorded with its user, connection, time range, duration, result size and
+// error. Backends opt in by implementing queryExtractor. The history is
+// served at /api/v1/history and persisted to history.jsonl in the data
+// directory, pruned to HistoryMaxEntries and HistoryRetention.
+
+const (
+	defaultHistoryMaxEntries = 10000
+	defaultHistoryRetention  = 30 * 24 * time.Hour
+)
+
+// queryExtractor is implemented by backends whose query APIs are recorded in
+// the query history.
+type queryExtractor interface {
+	// ExtractQuery returns the query carried by a proxied call, if any.
+	ExtractQuery(r *http.Request, t *proxyTarget) (historyQuery, bool)
+}
+
+// historyQuery is what a backend extracts from a query API call.
+type historyQuery struct {
+	Language string // "influxql", "promql" or "metricsql"
+	Query    string
+	Database string
+	Start    string // as sent by the client
+	End      string
+}
+
+// historyEntry is one recorded query.
+type historyEntry struct {
+	ID          string    `json:"id"`
+	Time        time.Time `json:"time"`
+	User        string    `json:"user,omitempty"`
+	Connection  string    `json:"connection,omitempty"`
+	Type        string    `json:"type"`
+	Target      string    `json:"target"`
+	Language    string    `json:"language"`
+	Query       string    `json:"query"`
+	Database    string    `json:"database,omitempty"`
+	Start       string    `json:"start,omitempty"`
+	End         string    `json:"end,omitempty"`
+	DurationMs  int64     `json:"durationMs"`
+	ResultBytes int64     `json:"resultBytes"`
+	Status      int       `json:"status"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// historyStore keeps entries oldest first, bounded by maxEntries and maxAge.
+// With a path, new entries are appended to it as JSON lines and the file is
+// rewritten when entries are pruned or deleted.
+type historyStore struct {
+	mu         sync.Mutex
+	entries    []historyEntry
+	maxEntries int
+	maxAge     time.Duration
+	path       string
+	stale      int // lines in the file that are no longer in entries
+}
+
+// newHistoryStore loads dir/history.jsonl when dir is set.
+func newHistoryStore(dir string, maxEntries int, maxAge time.Duration) (*historyStore, error) {
+	if maxEntries <= 0 {
+		maxEntries = defaultHistoryMaxEntries
+	}
+	if maxAge <= 0 {
+		maxAge = defaultHistoryRetention
+	}
+	s := &historyStore{maxEntries: maxEntries, maxAge: maxAge}
+	if dir == "" {
+		return s, nil
+	}
+	s.path = filepath.Join(dir, "history.jsonl")
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64<<10), 16<<20)
+	for sc.Scan() {
+		var e historyEntry
+		if json.Unmarshal(sc.Bytes(), &e) == nil && e.ID != "" {
+			s.entries = append(s.entries, e)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	s.prune(time.Now())
+	return s, s.rewrite()
+}
+
+// add records e, assigning its ID.
+func (s *historyStore) add(e historyEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e.ID = newID()
+	s.entries = append(s.entries, e)
+	pruned := s.prune(e.Time)
+	if s.path == "" {
+		return
+	}
+	if pruned > 0 && s.stale+pruned > s.maxEntries/2 {
+		s.rewrite()
+		return
+	}
+	s.stale += pruned
+	if line, err := json.Marshal(e); err == nil {
+		if f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600); err == nil {
+			f.Write(append(line, '\n'))
+			f.Close()
+		}
+	}
+}
+
+// prune drops entries beyond the retention limits and returns how many.
+func (s *historyStore) prune(now time.Time) int {
+	cutoff := now.Add(-s.maxAge)
+	drop := 0
+	for drop < len(s.entries) && (len(s.entries)-drop > s.maxEntries || s.entries[drop].Time.Before(cutoff)) {
+		drop++
+	}
+	if drop > 0 {
+		s.entries = append(s.entries[:0:0], s.entries[drop:]...)
+	}
+	return drop
+}
+
+// rewrite replaces the history file with the current entries.
+func (s *historyStore) rewrite() error {
+	if s.path == "" {
+		return nil
+	}
+	var buf []byte
+	for _, e := range s.entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			continue
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	s.stale = 0
+	return writeFileAtomic(s.path, buf)
+}
+
+// historyFilter selects entries for listing and bulk deletion.
+type historyFilter struct {
+	search     string // case-insensitive substring of the query
+	user       string
+	connection string // connection name or target URL
+	typ        string
+	language   string
+	since      time.Time
+	until      time.Time
+	status     string // "ok" or "error"
+}
+
+func (f *historyFilter) match(e *historyEntry) bool {
+	switch {
+	case f.search != "" && !strings.Contains(strings.ToLower(e.Query), f.search),
+		f.user != "" && e.User != f.user,
+		f.connection != "" && e.Connection != f.connection && e.Target != f.connection,
+		f.typ != "" && e.Type != f.typ,
+		f.language != "" && e.Language != f.language,
+		!f.since.IsZero() && e.Time.Before(f.since),
+		!f.until.IsZero() && e.Time.After(f.until),
+		f.status == "ok" && e.Error != "",
+		f.status == "error" && e.Error == "":
+		return false
+	}
+	return true
+}
+
+// parseHistoryFilter reads the filter from query parameters.
+func parseHistoryFilter(q map[string][]string) (historyFilter, error) {
+	get := func(k string) string {
+		if vs := q[k]; len(vs) > 0 {
+			return vs[0]
+		}
+		return ""
+	}
+	f := historyFilter{
+		search:     strings.ToLower(get("q")),
+		user:       get("user"),
+		connection: get("connection"),
+		typ:        get("type"),
+		language:   get("language"),
+		status:     get("status"),
+	}
+	var err error
+	if f.since, err = parseTimeParam(get("since")); err != nil {
+		return f, &httpError{http.StatusBadRequest, "Invalid since: " + err.Error()}
+	}
+	if f.until, err = parseTimeParam(get("until")); err != nil {
+		return f, &httpError{http.StatusBadRequest, "Invalid until: " + err.Error()}
+	}
+	return f, nil
+}
+
+// parseTimeParam parses an RFC 3339 time or Unix seconds; "" is the zero
+// time.
+func parseTimeParam(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Unix(0, int64(secs*1e9)), nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// parseRelativeTime parses an absolute time (RFC 3339 or Unix seconds) or one
+// relative to now: "now" or "now-<duration>".
+func parseRelativeTime(s string, now time.Time) (t time.Time, relative bool, err error) {
+	if rest, ok := strings.CutPrefix(s, "now"); ok {
+		if rest == "" {
+			return now, true, nil
+		}
+		if strings.HasPrefix(rest, "-") {
+			d, err := parseLongDuration(rest[1:])
+			if err == nil {
+				return now.Add(-d), true, nil
+			}
+		}
+		return t, false, fmt.Errorf("invalid relative time %q", s)
+	}
+	if t, err = parseTimeParam(s); err != nil {
+		return t, false, fmt.Errorf("invalid time %q", s)
+	}
+	return t, false, nil
+}
+
+// parseLongDuration is time.ParseDuration with "d" (days) and "w" (weeks)
+// as whole-number suffixes.
+func parseLongDuration(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			var v int
+			if _, err := fmt.Sscanf(n, "%d", &v); err == nil && fmt.Sprint(v) == n && v > 0 {
+				return time.Duration(v) * unit, nil
+			}
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err == nil && d <= 0 {
+		err = fmt.Errorf("invalid duration %q", s)
+	}
+	return d, err
+}
+
+// handler serves /api/v1/history and /api/v1/history/<id>:
+//
+//	GET    /api/v1/history?q=&user=&connection=&type=&language=&since=&until=&status=&limit=&offset=
+//	DELETE /api/v1/history?<same filters>
+//	DELETE /api/v1/history/<id>
+//
+// With user set, deletes only reach the caller's own entries and those
+// recorded without a user.
+func (s *historyStore) handler(prefix string, user func(*http.Request) string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setCORS(w)
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		id := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+		owns := func(e *historyEntry) bool { return user == nil || e.User == "" || e.User == user(r) }
+
+		if id != "" {
+			if r.Method != http.MethodDelete {
+				jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+				return
+			}
+			foreign, owner := false, ""
+			if !s.delete(func(e *historyEntry) bool {
+				if e.ID != id {
+					return false
+				}
+				if !owns(e) {
+					foreign, owner = true, e.User
+					return false
+				}
+				return true
+			}) {
+				if foreign {
+					jsonError(w, http.StatusForbidden, "This history entry belongs to "+owner)
+				} else {
+					jsonError(w, http.StatusNotFound, "History entry not found")
+				}
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		f, err := parseHistoryFilter(r.URL.Query())
+		if err != nil {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+			if limit <= 0 || limit > 1000 {
+				limit = 100
+			}
+			offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+			total, entries := s.list(&f, offset, limit)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{"total": total, "entries": entries})
+		case http.MethodDelete:
+			if user != nil && f.user != "" && f.user != user(r) {
+				jsonError(w, http.StatusForbidden, "Only your own history can be deleted")
+				return
+			}
+			n := 0
+			s.delete(func(e *historyEntry) bool {
+				if f.match(e) && owns(e) {
+					n++
+					return true
+				}
+				return false
+			})
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]int{"deleted": n})
+		default:
+			jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	}
+}
+
+// list returns the number of matching entries and one page of them, newest
+// first.
+func (s *historyStore) list(f *historyFilter, offset, limit int) (int, []historyEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := []historyEntry{}
+	total := 0
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		e := &s.entries[i]
+		if !f.match(e) {
+			continue
+		}
+		if total >= offset && len(out) < limit {
+			out = append(out, *e)
+		}
+		total++
+	}
+	return total, out
+}
+
+// delete removes the entries for which drop returns true and reports whether
+// any were removed.
+func (s *historyStore) delete(drop func(*historyEntry) bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.entries[:0]
+	for i := range s.entries {
+		if !drop(&s.entries[i]) {
+			kept = append(kept, s.entries[i])
+		}
+	}
+	removed := len(kept) < len(s.entries)
+	s.entries = kept
+	if removed {
+		s.rewrite()
+	}
+	return removed
+}
+
+// historyErrorMessage extracts the error of a failed query from the start of
+// its response body: the "error" field of a JSON body, else the text itself.
+func historyErrorMessage(status int, body []byte) string {
+	var v struct {
+		Error   string `json:"error"`
+		Results []struct {
+			Error string `json:"error"`
+		} `json:"results"`
+	}
+	if json.Unmarshal(body, &v) == nil {
+		if v.Error != "" {
+			return v.Error
+		}
+		for _, res := range v.Results {
+			if res.Error != "" {
+				return res.Error
+			}
+		}
+	}
+	if status < 400 {
+		return ""
+	}
+	if msg := strings.TrimSpace(string(body)); msg != "" {
+		return msg
+	}
+	return http.StatusText(status)
+}
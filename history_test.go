@@ -0,0 +1,60 @@
+package timeseriesui
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHistoryDeleteOwnership(t *testing.T) {
+	s, err := newHistoryStore("", 100, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, u := range []string{"alice", "alice", "bob", ""} {
+		s.add(historyEntry{Time: now, User: u, Query: "up"})
+	}
+	h := s.handler("/api/v1/history", func(r *http.Request) string { return r.Header.Get("X-User") })
+	as := func(user string) map[string]string { return map[string]string{"X-User": user} }
+	users := func() map[string]int {
+		n := map[string]int{}
+		for _, e := range s.entries {
+			n[e.User]++
+		}
+		return n
+	}
+
+	if rec := serve(h, http.MethodDelete, "/api/v1/history?user=alice", nil, as("bob")); rec.Code != http.StatusForbidden {
+		t.Errorf("deleting another user's history: status %d", rec.Code)
+	}
+	var bobEntry string
+	for _, e := range s.entries {
+		if e.User == "bob" {
+			bobEntry = e.ID
+		}
+	}
+	if rec := serve(h, http.MethodDelete, "/api/v1/history/"+bobEntry, nil, as("alice")); rec.Code != http.StatusForbidden {
+		t.Errorf("deleting another user's entry: status %d", rec.Code)
+	}
+
+	rec := serve(h, http.MethodDelete, "/api/v1/history", nil, as("alice"))
+	var res struct{ Deleted int }
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil || rec.Code != http.StatusOK || res.Deleted != 3 {
+		t.Errorf("deleting without filters: status %d: %s", rec.Code, rec.Body)
+	}
+	if n := users(); len(n) != 1 || n["bob"] != 1 {
+		t.Errorf("entries left by user: %v", n)
+	}
+	if rec := serve(h, http.MethodDelete, "/api/v1/history/"+bobEntry, nil, as("bob")); rec.Code != http.StatusNoContent {
+		t.Errorf("deleting an own entry: status %d", rec.Code)
+	}
+
+	// Without users, every entry can be deleted.
+	s.add(historyEntry{Time: now, User: "carol", Query: "up"})
+	rec = serve(s.handler("/api/v1/history", nil), http.MethodDelete, "/api/v1/history", nil, nil)
+	if rec.Code != http.StatusOK || len(s.entries) != 0 {
+		t.Errorf("single-user delete: status %d, %d entries left", rec.Code, len(s.entries))
+	}
+}
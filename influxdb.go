@@ -0,0 +1,135 @@
+package timeseriesui
+
+import (
+	"flag"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ── InfluxDB 1.x ────────────────────────────────────────────────────────────
+//
+// influxdb connections speak the 1.x HTTP API: /query for InfluxQL, /write
+// for line protocol and /ping for health. The InfluxQL explorer reaches them
+// through the legacy /query and /write routes; /proxy/influxdb/ serves
+// everything else.
+
+func init() { registerBackend(&influxDBBackend{}) }
+
+type influxDBBackend struct {
+	baseBackend
+	flags connFlags
+}
+
+func (*influxDBBackend) Type() string        { return "influxdb" }
+func (*influxDBBackend) DisplayName() string { return "InfluxDB" }
+func (*influxDBBackend) HealthPath() string  { return "/ping" }
+
+func (b *influxDBBackend) RegisterFlags(fs *flag.FlagSet) {
+	b.flags.register(fs, "influxdb", "InfluxDB")
+	b.flags.registerAuth(fs, "influxdb", "InfluxDB", "")
+}
+
+func (b *influxDBBackend) FlagConnections() []CLIConnection {
+	return b.flags.build(b, nil)
+}
+
+func (*influxDBBackend) Classify(r *http.Request, t *proxyTarget) apiKind {
+	return classifyInfluxV1(r, classifyPath(t.Path))
+}
+
+// ExtractQuery records InfluxQL queries in the query history.
+func (*influxDBBackend) ExtractQuery(r *http.Request, t *proxyTarget) (historyQuery, bool) {
+	return influxQLQuery(r, t.Params, t.Path)
+}
+
+// influxQLQuery extracts the InfluxQL query of a 1.x /query call.
+func influxQLQuery(r *http.Request, params url.Values, apiPath string) (historyQuery, bool) {
+	if apiPath != "/query" {
+		return historyQuery{}, false
+	}
+	q := requestParam(r, params, "q")
+	return historyQuery{Language: "influxql", Query: q, Database: requestParam(r, params, "db")}, q != ""
+}
+
+// classifyInfluxV1 classifies an InfluxDB 1.x API call. /write is a write;
+// /query is classified by its InfluxQL statements, read from the URL or from
+// a form-encoded body (which is restored for forwarding).
+func classifyInfluxV1(r *http.Request, apiPath string) apiKind {
+	switch apiPath {
+	case "/write", "/api/v2/write":
+		return apiWrite
+	case "/query":
+	default:
+		return apiRead
+	}
+
+	return classifyInfluxQL(requestParam(r, r.URL.Query(), "q"))
+}
+
+// classifyInfluxQL returns the most privileged kind among the statements of
+// q: SELECT … INTO writes, DDL/DCL and KILL administer, the rest read.
+func classifyInfluxQL(q string) apiKind {
+	kind := apiRead
+	for _, stmt := range splitInfluxQL(q) {
+		fields := strings.Fields(strings.ToUpper(stmt))
+		if len(fields) == 0 {
+			continue
+		}
+		var k apiKind
+		switch fields[0] {
+		case "SELECT":
+			for _, f := range fields[1:] {
+				if f == "INTO" {
+					k = apiWrite
+					break
+				}
+			}
+		case "SHOW", "EXPLAIN":
+			k = apiRead
+		default: // CREATE, DROP, ALTER, DELETE, GRANT, REVOKE, KILL, SET, …
+			k = apiAdmin
+		}
+		if k > kind {
+			kind = k
+		}
+	}
+	return kind
+}
+
+// splitInfluxQL splits q on semicolons outside quotes and strips comments.
+func splitInfluxQL(q string) []string {
+	var (
+		stmts []string
+		cur   strings.Builder
+		quote rune
+	)
+	runes := []rune(q)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case quote != 0:
+			cur.WriteRune(c)
+			if c == '\\' && i+1 < len(runes) {
+				i++
+				cur.WriteRune(runes[i])
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			cur.WriteRune(c)
+		case c == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			cur.WriteRune(' ')
+		case c == ';':
+			stmts = append(stmts, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteRune(c)
+		}
+	}
+	return append(stmts, cur.String())
+}
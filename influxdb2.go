@@ -0,0 +1,391 @@
+package timeseriesui
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ── InfluxDB 2.x ────────────────────────────────────────────────────────────
+//
+// InfluxDB 2.x authenticates with an API token and scopes most APIs to an
+// organization. The proxy injects both server-side for CLI connections, or
+// from the X-Proxy-Token / X-Proxy-Org headers for browser-defined ones.
+//
+// Flux queries (/api/v2/query) return annotated CSV. When the client asks for
+// JSON, the proxy requests the annotations and converts the tables into the
+// InfluxQL response shape rendered by the query explorer. InfluxQL itself is
+// served by the v1-compatibility /query endpoint through the DBRP mappings
+// managed at /api/v2/dbrps.
+
+func init() { registerBackend(&influxDB2Backend{}) }
+
+type influxDB2Backend struct {
+	baseBackend
+	flags connFlags
+	token string
+	org   string
+}
+
+func (*influxDB2Backend) Type() string        { return "influxdb2" }
+func (*influxDB2Backend) DisplayName() string { return "InfluxDB 2" }
+func (*influxDB2Backend) HealthPath() string  { return "/health" }
+
+func (b *influxDB2Backend) RegisterFlags(fs *flag.FlagSet) {
+	b.flags.register(fs, "influxdb2", "InfluxDB 2.x")
+	fs.StringVar(&b.token, "influxdb2-token", "", "Default InfluxDB 2.x API token")
+	fs.StringVar(&b.org, "influxdb2-org", "", "Default InfluxDB 2.x organization")
+}
+
+func (b *influxDB2Backend) FlagConnections() []CLIConnection {
+	return b.flags.build(b, func(_ int, c *CLIConnection) {
+		c.Token = b.token
+		c.Org = b.org
+	})
+}
+
+// ExtractQuery records InfluxQL sent to the v1-compatibility /query API.
+func (*influxDB2Backend) ExtractQuery(r *http.Request, t *proxyTarget) (historyQuery, bool) {
+	return influxQLQuery(r, t.Params, t.Path)
+}
+
+// Classify treats v2 and v1-compatible writes, and Flux queries that call
+// to(), as writes, and deletes and changes to buckets, orgs, users, tokens
+// and DBRP mappings as admin.
+func (*influxDB2Backend) Classify(r *http.Request, t *proxyTarget) apiKind {
+	p := classifyPath(t.Path)
+	switch {
+	case p == "/api/v2/write":
+		return apiWrite
+	case p == "/api/v2/delete":
+		return apiAdmin
+	case p == "/query" || p == "/write":
+		return classifyInfluxV1(r, p)
+	case p == "/api/v2/query" && r.Method == http.MethodPost && fluxQueryWrites(r):
+		return apiWrite
+	case r.Method != http.MethodGet && r.Method != http.MethodHead &&
+		p != "/api/v2/query" && strings.HasPrefix(p, "/api/v2/"):
+		return apiAdmin
+	}
+	return apiRead
+}
+
+// fluxWrites matches calls of the Flux functions that write data: to() and
+// wideTo(), in any package (experimental.to, sql.to, …).
+var fluxWrites = regexp.MustCompile(`\b(to|wideTo)\s*\(`)
+
+// fluxQueryWrites reports whether an /api/v2/query body writes data. The
+// body is read as InfluxDB does: raw Flux for application/vnd.flux, JSON
+// otherwise. A body that cannot be read counts as a write.
+func fluxQueryWrites(r *http.Request) bool {
+	if enc := r.Header.Get("Content-Encoding"); enc != "" && enc != "identity" {
+		return true
+	}
+	raw, complete := peekBody(r)
+	if !complete || fluxWrites.Match(raw) {
+		return true
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/vnd.flux" {
+		return false
+	}
+	var body struct {
+		Query string `json:"query"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return true
+	}
+	return fluxWrites.MatchString(body.Query)
+}
+
+// maxFluxRequestSize bounds the Flux request body the proxy buffers to add
+// the CSV dialect.
+const maxFluxRequestSize = 10 << 20
+
+// influxResponse is the InfluxDB 1.x /query response shape that the InfluxQL
+// explorer renders. Other Influx-family backends are normalized into it.
+type influxResponse struct {
+	Results []influxResult `json:"results"`
+}
+
+type influxResult struct {
+	StatementID int            `json:"statement_id"`
+	Series      []influxSeries `json:"series,omitempty"`
+	Error       string         `json:"error,omitempty"`
+}
+
+type influxSeries struct {
+	Name    string            `json:"name"`
+	Tags    map[string]string `json:"tags,omitempty"`
+	Columns []string          `json:"columns"`
+	Values  [][]interface{}   `json:"values"`
+}
+
+// influxDB2OrgScoped lists the v2 APIs that need an org parameter.
+var influxDB2OrgScoped = map[string]bool{
+	"/api/v2/query":   true,
+	"/api/v2/write":   true,
+	"/api/v2/buckets": true,
+	"/api/v2/dbrps":   true,
+}
+
+// Route adds the connection's organization to org-scoped APIs when the
+// caller did not.
+func (*influxDB2Backend) Route(r *http.Request, t *proxyTarget) error {
+	org := r.Header.Get("X-Proxy-Org")
+	if t.Conn != nil {
+		org = t.Conn.Org
+	}
+	if org == "" || !influxDB2OrgScoped[t.Path] {
+		return nil
+	}
+	if t.Params.Get("org") == "" && t.Params.Get("orgID") == "" {
+		t.Params.Set("org", org)
+	}
+	return nil
+}
+
+// Handler wraps the generic proxy with Flux annotated-CSV to JSON conversion
+// for /api/v2/query requests that accept application/json.
+func (*influxDB2Backend) Handler(env *proxyEnv) http.HandlerFunc {
+	generic := makeGenericProxy(env)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Query().Get("path") != "/api/v2/query" ||
+			!strings.Contains(r.Header.Get("Accept"), "application/json") {
+			generic(w, r)
+			return
+		}
+		setCORS(w)
+
+		body, err := fluxRequestWithAnnotations(r)
+		if err != nil {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		// Classify reads the query from the request body.
+		r.Body = io.NopCloser(strings.NewReader(body))
+		r.ContentLength = int64(len(body))
+		r.Header.Set("Content-Type", "application/json")
+		convertProxy(w, r, env, strings.NewReader(body),
+			func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+				req.Header.Set("Accept", "application/csv")
+			},
+			func(w http.ResponseWriter, body io.Reader) error {
+				result, err := parseFluxCSV(body)
+				if err != nil {
+					return err
+				}
+				w.Header().Set("Content-Type", "application/json")
+				return json.NewEncoder(w).Encode(result)
+			})
+	}
+}
+
+// fluxRequestWithAnnotations reads a Flux query (raw application/vnd.flux or
+// a JSON query object) and returns a JSON query object that asks for the
+// datatype, group and default annotations.
+func fluxRequestWithAnnotations(r *http.Request) (string, error) {
+	raw, err := io.ReadAll(io.LimitReader(r.Body, maxFluxRequestSize+1))
+	if err != nil {
+		return "", fmt.Errorf("Failed to read request body: %s", err)
+	}
+	if len(raw) > maxFluxRequestSize {
+		return "", fmt.Errorf("Flux request body exceeds %d bytes", maxFluxRequestSize)
+	}
+
+	q := map[string]interface{}{}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		if err := json.Unmarshal(raw, &q); err != nil {
+			return "", fmt.Errorf("Invalid JSON query: %s", err)
+		}
+	} else {
+		q["query"] = string(raw)
+	}
+	if _, ok := q["type"]; !ok {
+		q["type"] = "flux"
+	}
+	q["dialect"] = map[string]interface{}{
+		"header":      true,
+		"annotations": []string{"datatype", "group", "default"},
+	}
+	out, err := json.Marshal(q)
+	return string(out), err
+}
+
+// parseFluxCSV converts Flux annotated CSV into the InfluxQL response shape.
+// Each Flux table becomes one series: group-key columns become tags, the
+// remaining columns become values, and _time is renamed to time and placed
+// first so the explorer's charts pick it up.
+func parseFluxCSV(r io.Reader) (*influxResponse, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	var (
+		datatypes, groups, defaults, header []string
+		colIdx                              []int // header indexes of value columns, time first
+		order                               []string
+		series                              = map[string]*influxSeries{}
+	)
+
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rec) == 0 {
+			continue
+		}
+		switch rec[0] {
+		case "#datatype":
+			datatypes, header = rec, nil
+			continue
+		case "#group":
+			groups, header = rec, nil
+			continue
+		case "#default":
+			defaults, header = rec, nil
+			continue
+		}
+
+		if header == nil {
+			header = rec
+			colIdx = fluxValueColumns(header, groups)
+			if i := indexOf(header, "error"); i >= 0 && indexOf(header, "table") < 0 {
+				// Flux reports errors as a table with error/reference columns.
+				if row, err := cr.Read(); err == nil && i < len(row) {
+					return &influxResponse{Results: []influxResult{{Error: row[i]}}}, nil
+				}
+			}
+			continue
+		}
+
+		resultName := fieldAt(rec, header, "result", defaults)
+		key := resultName + "\x00" + fieldAt(rec, header, "table", defaults)
+		s, ok := series[key]
+		if !ok {
+			s = &influxSeries{Name: resultName, Tags: map[string]string{}}
+			for i, h := range header {
+				if i == 0 || h == "result" || h == "table" || h == "_start" || h == "_stop" {
+					continue
+				}
+				if i < len(groups) && groups[i] == "true" {
+					s.Tags[h] = cell(rec, defaults, i)
+				}
+			}
+			if m, ok := s.Tags["_measurement"]; ok {
+				s.Name = m
+			}
+			for _, i := range colIdx {
+				name := header[i]
+				if name == "_time" {
+					name = "time"
+				}
+				s.Columns = append(s.Columns, name)
+			}
+			series[key] = s
+			order = append(order, key)
+		}
+
+		row := make([]interface{}, len(colIdx))
+		for j, i := range colIdx {
+			dt := ""
+			if i < len(datatypes) {
+				dt = datatypes[i]
+			}
+			row[j] = fluxValue(cell(rec, defaults, i), dt)
+		}
+		s.Values = append(s.Values, row)
+	}
+
+	result := influxResult{}
+	for _, k := range order {
+		s := series[k]
+		if len(s.Tags) == 0 {
+			s.Tags = nil
+		}
+		result.Series = append(result.Series, *s)
+	}
+	return &influxResponse{Results: []influxResult{result}}, nil
+}
+
+// fluxValueColumns returns the header indexes that hold values rather than
+// group keys or Flux bookkeeping, with _time first.
+func fluxValueColumns(header, groups []string) []int {
+	var idx []int
+	if t := indexOf(header, "_time"); t >= 0 {
+		idx = append(idx, t)
+	}
+	for i, h := range header {
+		if i == 0 || h == "_time" || h == "result" || h == "table" {
+			continue
+		}
+		if i < len(groups) && groups[i] == "true" {
+			continue
+		}
+		idx = append(idx, i)
+	}
+	return idx
+}
+
+// fluxValue converts an annotated CSV cell to a JSON value of its datatype.
+func fluxValue(raw, datatype string) interface{} {
+	if raw == "" && datatype != "string" {
+		return nil
+	}
+	switch datatype {
+	case "long":
+		if v, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			return v
+		}
+	case "unsignedLong":
+		if v, err := strconv.ParseUint(raw, 10, 64); err == nil {
+			return v
+		}
+	case "double":
+		if v, err := strconv.ParseFloat(raw, 64); err == nil {
+			return v
+		}
+	case "boolean":
+		if v, err := strconv.ParseBool(raw); err == nil {
+			return v
+		}
+	}
+	return raw
+}
+
+func cell(rec, defaults []string, i int) string {
+	if i < len(rec) && rec[i] != "" {
+		return rec[i]
+	}
+	if i < len(defaults) {
+		return defaults[i]
+	}
+	return ""
+}
+
+func fieldAt(rec, header []string, name string, defaults []string) string {
+	if i := indexOf(header, name); i >= 0 {
+		return cell(rec, defaults, i)
+	}
+	return ""
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
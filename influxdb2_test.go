@@ -0,0 +1,69 @@
+package timeseriesui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFluxQueryClassification(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+	}))
+	defer upstream.Close()
+	h := newTestHandler(t, Options{
+		Connections:  []CLIConnection{{Name: "v2", Type: "influxdb2", URL: upstream.URL, Token: "tok", Org: "org"}},
+		DisableWrite: true,
+	})
+
+	read := `from(bucket: "b") |> range(start: -1h)`
+	write := read + ` |> to(bucket: "copy")`
+	cases := []struct {
+		contentType, body string
+		writes            bool
+	}{
+		{"application/json", `{"query": "from(bucket: \"b\") |> range(start: -1h)", "type": "flux"}`, false},
+		{"application/json", `{"query": "from(bucket: \"b\") |> range(start: -1h) |> to(bucket: \"copy\")"}`, true},
+		{"application/vnd.flux", read, false},
+		{"application/vnd.flux", write, true},
+		{"application/json", `{"query": "from(bucket: \"b\") |> experimental.to(bucket: \"copy\")"}`, true},
+	}
+	for _, c := range cases {
+		for _, accept := range []string{"", "application/json"} {
+			rec := serve(h, http.MethodPost, proxyPath("influxdb2", upstream.URL, "/api/v2/query"), strings.NewReader(c.body),
+				map[string]string{"Content-Type": c.contentType, "Accept": accept})
+			want := http.StatusOK
+			if c.writes {
+				want = http.StatusForbidden
+			}
+			if rec.Code != want {
+				t.Errorf("%s %q with Accept %q: status %d, want %d: %s", c.contentType, c.body, accept, rec.Code, want, rec.Body)
+			}
+		}
+	}
+}
+
+func TestInfluxDB2Classify(t *testing.T) {
+	cases := []struct {
+		method, path string
+		want         apiKind
+	}{
+		{http.MethodPost, "/api/v2/write", apiWrite},
+		{http.MethodPost, "//api/v2/write", apiWrite},
+		{http.MethodPost, "/api/v2/delete", apiAdmin},
+		{http.MethodPost, "/api/v2/./delete", apiAdmin},
+		{http.MethodPost, "/api/v2/buckets", apiAdmin},
+		{http.MethodDelete, "/api/v2/buckets/1", apiAdmin},
+		{http.MethodGet, "/api/v2/buckets", apiRead},
+		{http.MethodPost, "/write", apiWrite},
+		{http.MethodGet, "/query", apiRead},
+	}
+	b := backends["influxdb2"]
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, "/", nil)
+		if got := b.Classify(r, &proxyTarget{Path: c.path}); got != c.want {
+			t.Errorf("%s %s: %v, want %v", c.method, c.path, got, c.want)
+		}
+	}
+}
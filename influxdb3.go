@@ -0,0 +1,273 @@
+package timeseriesui
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ── InfluxDB 3 ──────────────────────────────────────────────────────────────
+//
+// InfluxDB 3 serves SQL and InfluxQL over /api/v3/query_sql and
+// /api/v3/query_influxql and accepts line protocol at /api/v3/write_lp, all
+// authenticated with a bearer token and addressed to a database (db).
+//
+// Query results are JSON arrays of row objects. When the client adds
+// shape=series, the proxy asks for JSON and regroups the rows into the
+// InfluxQL response shape rendered by the query explorer.
+
+func init() { registerBackend(&influxDB3Backend{}) }
+
+type influxDB3Backend struct {
+	baseBackend
+	flags    connFlags
+	token    string
+	database string
+}
+
+func (*influxDB3Backend) Type() string        { return "influxdb3" }
+func (*influxDB3Backend) DisplayName() string { return "InfluxDB 3" }
+func (*influxDB3Backend) HealthPath() string  { return "/health" }
+
+func (b *influxDB3Backend) RegisterFlags(fs *flag.FlagSet) {
+	b.flags.register(fs, "influxdb3", "InfluxDB 3")
+	fs.StringVar(&b.token, "influxdb3-token", "", "Default InfluxDB 3 API token")
+	fs.StringVar(&b.database, "influxdb3-database", "", "Default InfluxDB 3 database")
+}
+
+func (b *influxDB3Backend) FlagConnections() []CLIConnection {
+	return b.flags.build(b, func(_ int, c *CLIConnection) {
+		c.Token = b.token
+		c.DefaultDatabase = b.database
+	})
+}
+
+// InjectAuth uses the Bearer scheme InfluxDB 3 expects for tokens.
+func (b *influxDB3Backend) InjectAuth(h http.Header, cred credentials) {
+	if cred.Token != "" {
+		h.Set("Authorization", "Bearer "+cred.Token)
+		return
+	}
+	b.baseBackend.InjectAuth(h, cred)
+}
+
+// Classify treats line protocol writes as writes and the configure API
+// (databases, tables, tokens, caches) as admin. Paths are matched cleaned.
+func (*influxDB3Backend) Classify(r *http.Request, t *proxyTarget) apiKind {
+	p := classifyPath(t.Path)
+	switch {
+	case p == "/api/v3/write_lp" || p == "/api/v2/write" || p == "/write":
+		return apiWrite
+	case strings.HasPrefix(p, "/api/v3/configure/"):
+		return apiAdmin
+	}
+	return apiRead
+}
+
+// influxDB3QueryPaths lists the v3 query APIs whose results can be reshaped.
+var influxDB3QueryPaths = map[string]bool{
+	"/api/v3/query_sql":      true,
+	"/api/v3/query_influxql": true,
+}
+
+// influxDB3MeasurementColumn is the column InfluxQL results use to name the
+// measurement each row came from.
+const influxDB3MeasurementColumn = "iox::measurement"
+
+// Route adds the connection's default database to query and write APIs
+// when the caller did not.
+func (*influxDB3Backend) Route(r *http.Request, t *proxyTarget) error {
+	t.Params.Del("shape")
+
+	// POSTed queries carry db in their JSON body instead.
+	inQuery := t.Path == "/api/v3/write_lp" || influxDB3QueryPaths[t.Path] && r.Method == http.MethodGet
+	if db := influxDB3Database(r, t.Conn); db != "" && inQuery && t.Params.Get("db") == "" {
+		t.Params.Set("db", db)
+	}
+	return nil
+}
+
+// influxDB3Database returns the default database of a CLI connection, or the
+// X-Proxy-Database header sent for browser-defined ones.
+func influxDB3Database(r *http.Request, conn *CLIConnection) string {
+	if conn != nil {
+		return conn.DefaultDatabase
+	}
+	return r.Header.Get("X-Proxy-Database")
+}
+
+// Handler wraps the generic proxy with result reshaping for query requests
+// that carry shape=series.
+func (*influxDB3Backend) Handler(env *proxyEnv) http.HandlerFunc {
+	generic := makeGenericProxy(env)
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("shape") != "series" || !influxDB3QueryPaths[q.Get("path")] {
+			generic(w, r)
+			return
+		}
+		setCORS(w)
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		var body io.Reader
+		if r.Method == http.MethodPost {
+			var conn *CLIConnection
+			if target, err := url.Parse(q.Get("target")); err == nil {
+				if up, ok := env.lookup(target); ok {
+					conn = up.conn
+				}
+			}
+			b, err := influxDB3JSONFormatBody(r, influxDB3Database(r, conn))
+			if err != nil {
+				jsonError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			body = bytes.NewReader(b)
+		}
+		convertProxy(w, r, env, body,
+			func(req *http.Request) {
+				if req.Method == http.MethodGet {
+					q := req.URL.Query()
+					q.Set("format", "json")
+					req.URL.RawQuery = q.Encode()
+				} else {
+					req.Header.Set("Content-Type", "application/json")
+				}
+				req.Header.Set("Accept", "application/json")
+			},
+			func(w http.ResponseWriter, body io.Reader) error {
+				result, err := influxDB3RowsToSeries(body)
+				if err != nil {
+					return err
+				}
+				w.Header().Set("Content-Type", "application/json")
+				return json.NewEncoder(w).Encode(result)
+			})
+	}
+}
+
+// influxDB3JSONFormatBody rewrites a POSTed query request to ask for JSON
+// output, adding db when the body names no database.
+func influxDB3JSONFormatBody(r *http.Request, db string) ([]byte, error) {
+	raw, err := io.ReadAll(io.LimitReader(r.Body, maxFluxRequestSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read request body: %s", err)
+	}
+	if len(raw) > maxFluxRequestSize {
+		return nil, fmt.Errorf("Query request body exceeds %d bytes", maxFluxRequestSize)
+	}
+	req := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return nil, fmt.Errorf("Invalid JSON query: %s", err)
+	}
+	req["format"] = "json"
+	if _, ok := req["db"]; !ok && db != "" {
+		req["db"] = db
+	}
+	return json.Marshal(req)
+}
+
+// influxDB3RowsToSeries regroups a JSON array of row objects into the InfluxQL
+// response shape. InfluxQL rows are split into one series per measurement.
+// Each series' columns follow the first appearance of each key, time first.
+func influxDB3RowsToSeries(r io.Reader) (*influxResponse, error) {
+	var rows []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&rows); err != nil {
+		return nil, err
+	}
+
+	type group struct {
+		columns []string
+		seen    map[string]bool
+		rows    []map[string]interface{}
+	}
+	var (
+		order  []string
+		groups = map[string]*group{}
+	)
+	for _, raw := range rows {
+		keys, values, err := orderedObject(raw)
+		if err != nil {
+			return nil, err
+		}
+		name, _ := values[influxDB3MeasurementColumn].(string)
+		g, ok := groups[name]
+		if !ok {
+			g = &group{seen: map[string]bool{}}
+			groups[name] = g
+			order = append(order, name)
+		}
+		for _, k := range keys {
+			if !g.seen[k] && k != influxDB3MeasurementColumn {
+				g.seen[k] = true
+				g.columns = append(g.columns, k)
+			}
+		}
+		g.rows = append(g.rows, values)
+	}
+
+	result := influxResult{}
+	for _, name := range order {
+		g := groups[name]
+		var cols []string
+		if g.seen["time"] {
+			cols = append(cols, "time")
+		}
+		for _, c := range g.columns {
+			if c != "time" {
+				cols = append(cols, c)
+			}
+		}
+		s := influxSeries{Name: name, Columns: cols}
+		for _, row := range g.rows {
+			vals := make([]interface{}, len(cols))
+			for i, c := range cols {
+				vals[i] = row[c]
+			}
+			s.Values = append(s.Values, vals)
+		}
+		result.Series = append(result.Series, s)
+	}
+	return &influxResponse{Results: []influxResult{result}}, nil
+}
+
+// orderedObject decodes a JSON object, returning its keys in document order.
+// Integral numbers decode as int64 and others as float64.
+func orderedObject(raw json.RawMessage) ([]string, map[string]interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, nil, fmt.Errorf("expected a JSON object per row")
+	}
+	var keys []string
+	values := map[string]interface{}{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key := tok.(string)
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return nil, nil, err
+		}
+		if n, ok := v.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				v = i
+			} else if f, err := n.Float64(); err == nil {
+				v = f
+			}
+		}
+		keys = append(keys, key)
+		values[key] = v
+	}
+	return keys, values, nil
+}
@@ -0,0 +1,32 @@
+package timeseriesui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestInfluxDB3Classify(t *testing.T) {
+	cases := []struct {
+		method, path string
+		want         apiKind
+	}{
+		{http.MethodPost, "/api/v3/write_lp", apiWrite},
+		{http.MethodPost, "//api/v3/write_lp", apiWrite},
+		{http.MethodPost, "/api/v3/./write_lp", apiWrite},
+		{http.MethodPost, "/api/v2/write", apiWrite},
+		{http.MethodPost, "/write/", apiWrite},
+		{http.MethodPost, "/api/v3/configure/database", apiAdmin},
+		{http.MethodDelete, "/api/v3//configure/table", apiAdmin},
+		{http.MethodPost, "/api/v3/query/../configure/token/admin", apiAdmin},
+		{http.MethodGet, "/api/v3/query_sql", apiRead},
+		{http.MethodPost, "/api/v3/query_influxql", apiRead},
+	}
+	b := backends["influxdb3"]
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, "/", nil)
+		if got := b.Classify(r, &proxyTarget{Path: c.path}); got != c.want {
+			t.Errorf("%s %s: %v, want %v", c.method, c.path, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package timeseriesui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestInfluxDBClassify(t *testing.T) {
+	cases := []struct {
+		method, path, q string
+		want            apiKind
+	}{
+		{http.MethodPost, "/write", "", apiWrite},
+		{http.MethodPost, "//write", "", apiWrite},
+		{http.MethodPost, "/api/v2/./write", "", apiWrite},
+		{http.MethodGet, "/query", "SHOW DATABASES", apiRead},
+		{http.MethodGet, "/query", "SELECT * INTO b FROM a", apiWrite},
+		{http.MethodPost, "/query", "DROP DATABASE db", apiAdmin},
+		{http.MethodPost, "//query", "DROP DATABASE db", apiAdmin},
+		{http.MethodPost, "/query/", "SELECT 1; DROP MEASUREMENT m", apiAdmin},
+		{http.MethodGet, "/ping", "", apiRead},
+	}
+	b := backends["influxdb"]
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, "/?"+url.Values{"q": {c.q}}.Encode(), nil)
+		if got := b.Classify(r, &proxyTarget{Path: c.path}); got != c.want {
+			t.Errorf("%s %s %q: %v, want %v", c.method, c.path, c.q, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,720 @@
+package timeseriesui
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ── Background jobs ─────────────────────────────────────────────────────────
+//
+// Long-running operations run as jobs that outlive the request submitting
+// them:
+//
+//	POST   /api/v1/jobs                {"type": "export", "params": {…}}
+//	GET    /api/v1/jobs                ?type=&state=&owner=
+//	GET    /api/v1/jobs/<id>
+//	GET    /api/v1/jobs/<id>/events    state, progress and logs as server-sent events
+//	POST   /api/v1/jobs/<id>/cancel
+//	POST   /api/v1/jobs/<id>/resume    run an interrupted, failed or canceled job again
+//	GET    /api/v1/jobs/<id>/download  the file of an export job
+//	DELETE /api/v1/jobs/<id>
+//
+// Jobs wait in a queue for one of a fixed number of workers and run with the
+// headers of the request that submitted (or resumed) them, so proxied calls
+// are authorized as that user. Records are persisted to jobs.json in the
+// data directory; jobs queued or running when the server stopped are marked
+// interrupted and can be resumed. Users see and change only their own jobs.
+
+// Job states.
+const (
+	jobQueued      = "queued"
+	jobRunning     = "running"
+	jobSucceeded   = "succeeded"
+	jobFailed      = "failed"
+	jobCanceled    = "canceled"
+	jobInterrupted = "interrupted"
+)
+
+const (
+	defaultJobWorkers = 2
+	maxQueuedJobs     = 1000
+	maxJobs           = 1000 // finished jobs beyond this are dropped, oldest first
+	maxJobLogs        = 500
+	jobSaveInterval   = time.Second
+)
+
+// job is the record of a submitted job.
+type job struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	Params      json.RawMessage `json:"params"`
+	Owner       string          `json:"owner,omitempty"`
+	State       string          `json:"state"`
+	Progress    jobProgress     `json:"progress"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	Logs        []jobLog        `json:"logs,omitempty"`
+	LogsDropped int             `json:"logsDropped,omitempty"` // oldest lines dropped beyond maxJobLogs
+	Attempts    int             `json:"attempts"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	StartedAt   *time.Time      `json:"startedAt,omitempty"`
+	FinishedAt  *time.Time      `json:"finishedAt,omitempty"`
+
+	req     *http.Request      // the request the job runs on behalf of
+	cancel  context.CancelFunc // set while running
+	changed chan struct{}      // closed and replaced on every change
+}
+
+type jobProgress struct {
+	Done    int     `json:"done"`
+	Total   int     `json:"total"`
+	Percent float64 `json:"percent"`
+	Message string  `json:"message,omitempty"`
+}
+
+type jobLog struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// jobFinished reports whether state is final.
+func jobFinished(state string) bool {
+	return state != jobQueued && state != jobRunning
+}
+
+// jobKind runs the jobs of one type.
+type jobKind struct {
+	// validate checks the params of a job being submitted.
+	validate func(params json.RawMessage) error
+	run      func(jc *jobContext) error
+	// upload jobs read a file staged by their own endpoint rather than
+	// being submitted to /api/v1/jobs.
+	upload bool
+}
+
+// jobContext is what a running job sees of the runner.
+type jobContext struct {
+	jr  *jobRunner
+	job *job
+	// r carries the job's context, canceled by /cancel, and the headers of
+	// the request that submitted or resumed the job.
+	r *http.Request
+}
+
+// progress records done out of total steps.
+func (jc *jobContext) progress(done, total int, message string) {
+	jc.jr.change(jc.job, false, func(j *job) {
+		j.Progress = jobProgress{Done: done, Total: total, Percent: progressPercent(done, total), Message: message}
+	})
+}
+
+// logf appends a line to the job's log.
+func (jc *jobContext) logf(format string, args ...interface{}) {
+	jc.jr.change(jc.job, false, func(j *job) {
+		j.Logs = append(j.Logs, jobLog{Time: time.Now().UTC(), Message: fmt.Sprintf(format, args...)})
+		if n := len(j.Logs) - maxJobLogs; n > 0 {
+			j.Logs = append([]jobLog(nil), j.Logs[n:]...)
+			j.LogsDropped += n
+		}
+	})
+}
+
+// setResult stores v as the job's result.
+func (jc *jobContext) setResult(v interface{}) {
+	data, _ := json.Marshal(v)
+	jc.jr.change(jc.job, true, func(j *job) { j.Result = data })
+}
+
+// result decodes the result of an earlier attempt into v, reporting
+// whether there was one.
+func (jc *jobContext) result(v interface{}) bool {
+	jc.jr.mu.Lock()
+	data := jc.job.Result
+	jc.jr.mu.Unlock()
+	return len(data) > 0 && json.Unmarshal(data, v) == nil
+}
+
+// artifact is the path of the file the job produces or reads.
+func (jc *jobContext) artifact() string {
+	return filepath.Join(jc.jr.dir, jc.job.ID)
+}
+
+// jobRunner queues, runs and stores jobs.
+type jobRunner struct {
+	mu       sync.Mutex
+	jobs     map[string]*job
+	kinds    map[string]jobKind
+	queue    chan *job
+	path     string // jobs.json, or empty
+	dir      string // job files
+	lastSave time.Time
+	user     func(*http.Request) string
+}
+
+// newJobRunner loads the jobs stored in dataDir and starts workers. Without
+// a data directory, job files go to a temporary directory.
+func newJobRunner(dataDir string, workers int, user func(*http.Request) string) (*jobRunner, error) {
+	if workers <= 0 {
+		workers = defaultJobWorkers
+	}
+	jr := &jobRunner{
+		jobs:  map[string]*job{},
+		kinds: map[string]jobKind{},
+		queue: make(chan *job, maxQueuedJobs),
+		user:  user,
+	}
+	var err error
+	if dataDir == "" {
+		jr.dir, err = os.MkdirTemp("", "timeseriesui-jobs")
+	} else {
+		jr.dir = filepath.Join(dataDir, "jobs")
+		err = os.MkdirAll(jr.dir, 0o700)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if dataDir != "" {
+		jr.path = filepath.Join(dataDir, "jobs.json")
+		var list []*job
+		if err := loadJSONFile(jr.path, &list); err != nil {
+			return nil, err
+		}
+		for _, j := range list {
+			if !jobFinished(j.State) {
+				j.State, j.Error = jobInterrupted, "the server stopped"
+			}
+			j.changed = make(chan struct{})
+			jr.jobs[j.ID] = j
+		}
+	}
+	for i := 0; i < workers; i++ {
+		go jr.work()
+	}
+	return jr, nil
+}
+
+// register adds a job type.
+func (jr *jobRunner) register(typ string, k jobKind) { jr.kinds[typ] = k }
+
+// save persists the jobs; the caller holds mu.
+func (jr *jobRunner) save() error {
+	jr.lastSave = time.Now()
+	if jr.path == "" {
+		return nil
+	}
+	return saveJSONFile(jr.path, jr.sorted())
+}
+
+// sorted returns the jobs newest first; the caller holds mu.
+func (jr *jobRunner) sorted() []*job {
+	list := make([]*job, 0, len(jr.jobs))
+	for _, j := range jr.jobs {
+		list = append(list, j)
+	}
+	sort.Slice(list, func(i, k int) bool {
+		if !list[i].CreatedAt.Equal(list[k].CreatedAt) {
+			return list[i].CreatedAt.After(list[k].CreatedAt)
+		}
+		return list[i].ID < list[k].ID
+	})
+	return list
+}
+
+// change applies fn to j and calls touch.
+func (jr *jobRunner) change(j *job, persist bool, fn func(*job)) {
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+	fn(j)
+	jr.touch(j, persist)
+}
+
+// touch wakes the followers of j and persists the jobs: always with
+// persist, otherwise at most every jobSaveInterval. The caller holds mu.
+func (jr *jobRunner) touch(j *job, persist bool) error {
+	close(j.changed)
+	j.changed = make(chan struct{})
+	if persist || time.Since(jr.lastSave) >= jobSaveInterval {
+		return jr.save()
+	}
+	return nil
+}
+
+// snapshot returns a copy of j that is safe to encode after mu is released;
+// the caller holds mu.
+func (j *job) snapshot(withLogs bool) *job {
+	c := *j
+	c.Logs = nil
+	if withLogs {
+		c.Logs = append([]jobLog(nil), j.Logs...)
+	}
+	return &c
+}
+
+// enqueue queues j to run on behalf of r; the caller holds mu, so workers
+// see j only once it is marked queued.
+func (jr *jobRunner) enqueue(j *job, r *http.Request) error {
+	select {
+	case jr.queue <- j:
+	default:
+		return &httpError{http.StatusServiceUnavailable, "The job queue is full"}
+	}
+	sub := r.Clone(context.Background())
+	sub.Body, sub.ContentLength = http.NoBody, 0
+	j.req = sub
+	j.State, j.Error = jobQueued, ""
+	j.StartedAt, j.FinishedAt = nil, nil
+	j.Progress = jobProgress{}
+	jr.prune()
+	return jr.touch(j, true)
+}
+
+// prune drops the oldest finished jobs beyond maxJobs; the caller holds mu.
+func (jr *jobRunner) prune() {
+	list := jr.sorted()
+	for i := len(list) - 1; i >= maxJobs; i-- {
+		if jobFinished(list[i].State) {
+			delete(jr.jobs, list[i].ID)
+			os.Remove(filepath.Join(jr.dir, list[i].ID))
+		}
+	}
+}
+
+// work runs queued jobs.
+func (jr *jobRunner) work() {
+	for j := range jr.queue {
+		jr.execute(j)
+	}
+}
+
+// execute runs j unless it was canceled while queued.
+func (jr *jobRunner) execute(j *job) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	jr.mu.Lock()
+	if j.State != jobQueued || jr.jobs[j.ID] != j {
+		jr.mu.Unlock()
+		return
+	}
+	now := time.Now().UTC()
+	j.State, j.StartedAt, j.cancel = jobRunning, &now, cancel
+	j.Attempts++
+	jc := &jobContext{jr: jr, job: j, r: j.req.WithContext(ctx)}
+	kind := jr.kinds[j.Type]
+	jr.touch(j, true)
+	jr.mu.Unlock()
+
+	err := func() (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = fmt.Errorf("job panicked: %v", p)
+			}
+		}()
+		return kind.run(jc)
+	}()
+
+	jr.change(j, true, func(j *job) {
+		now := time.Now().UTC()
+		j.FinishedAt, j.cancel, j.req = &now, nil, nil
+		switch {
+		case err == nil:
+			j.State = jobSucceeded
+		case ctx.Err() != nil:
+			j.State, j.Error = jobCanceled, "canceled"
+		default:
+			j.State, j.Error = jobFailed, err.Error()
+		}
+	})
+}
+
+// handler serves the job API.
+func (jr *jobRunner) handler(prefix string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setCORS(w)
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		rest := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+		id, action, _ := strings.Cut(rest, "/")
+
+		if id == "" {
+			switch r.Method {
+			case http.MethodGet:
+				q := r.URL.Query()
+				out := []*job{}
+				jr.mu.Lock()
+				for _, j := range jr.sorted() {
+					if jr.owns(r, j) &&
+						(q.Get("type") == "" || j.Type == q.Get("type")) &&
+						(q.Get("state") == "" || j.State == q.Get("state")) &&
+						(q.Get("owner") == "" || j.Owner == q.Get("owner")) {
+						out = append(out, j.snapshot(false))
+					}
+				}
+				jr.mu.Unlock()
+				writeJSON(w, http.StatusOK, out)
+			case http.MethodPost:
+				jr.submit(w, r, prefix)
+			default:
+				jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+			return
+		}
+
+		jr.mu.Lock()
+		j, ok := jr.jobs[id]
+		jr.mu.Unlock()
+		if !ok {
+			jsonError(w, http.StatusNotFound, "Job not found")
+			return
+		}
+		switch {
+		case action == "" && r.Method == http.MethodGet:
+			if !jr.allowed(w, r, j) {
+				return
+			}
+			jr.mu.Lock()
+			snap := j.snapshot(true)
+			jr.mu.Unlock()
+			writeJSON(w, http.StatusOK, snap)
+		case action == "events" && r.Method == http.MethodGet:
+			if jr.allowed(w, r, j) {
+				jr.follow(w, r, j)
+			}
+		case action == "download" && r.Method == http.MethodGet:
+			if jr.allowed(w, r, j) {
+				jr.download(w, r, j)
+			}
+		case action == "" && r.Method == http.MethodDelete,
+			action == "cancel" && r.Method == http.MethodPost,
+			action == "resume" && r.Method == http.MethodPost:
+			if !jr.allowed(w, r, j) {
+				return
+			}
+			jr.mu.Lock()
+			defer jr.mu.Unlock()
+			switch {
+			case action == "":
+				if !jobFinished(j.State) {
+					jsonError(w, http.StatusConflict, "Job is "+j.State+"; cancel it first")
+					return
+				}
+				delete(jr.jobs, id)
+				os.Remove(filepath.Join(jr.dir, id))
+				if err := jr.save(); err != nil {
+					jsonError(w, http.StatusInternalServerError, "Failed to save: "+err.Error())
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			case action == "cancel":
+				switch j.State {
+				case jobQueued:
+					now := time.Now().UTC()
+					j.State, j.Error, j.FinishedAt = jobCanceled, "canceled", &now
+					jr.touch(j, true)
+				case jobRunning:
+					j.cancel()
+				default:
+					jsonError(w, http.StatusConflict, "Job is "+j.State)
+					return
+				}
+			default:
+				if !jobFinished(j.State) || j.State == jobSucceeded {
+					jsonError(w, http.StatusConflict, "Job is "+j.State)
+					return
+				}
+				if err := jr.enqueue(j, r); err != nil {
+					jsonError(w, httpErrorStatus(err, http.StatusInternalServerError), err.Error())
+					return
+				}
+			}
+			writeJSON(w, http.StatusAccepted, j.snapshot(false))
+		case action == "" || action == "events" || action == "download" || action == "cancel" || action == "resume":
+			jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		default:
+			jsonError(w, http.StatusNotFound, "Not found")
+		}
+	}
+}
+
+// owns reports whether the user of r may see and change j.
+func (jr *jobRunner) owns(r *http.Request, j *job) bool {
+	return j.Owner == "" || jr.user == nil || jr.user(r) == j.Owner
+}
+
+// allowed reports whether the user of r may see and change j, and answers
+// 403 otherwise.
+func (jr *jobRunner) allowed(w http.ResponseWriter, r *http.Request, j *job) bool {
+	if jr.owns(r, j) {
+		return true
+	}
+	jsonError(w, http.StatusForbidden, "This job belongs to "+j.Owner)
+	return false
+}
+
+// submit serves POST /api/v1/jobs.
+func (jr *jobRunner) submit(w http.ResponseWriter, r *http.Request, prefix string) {
+	var req struct {
+		Type   string          `json:"type"`
+		Params json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+		return
+	}
+	if kind, ok := jr.kinds[req.Type]; !ok || kind.upload {
+		var types []string
+		for t, k := range jr.kinds {
+			if !k.upload {
+				types = append(types, t)
+			}
+		}
+		sort.Strings(types)
+		jsonError(w, http.StatusBadRequest, "type must be one of "+strings.Join(types, ", "))
+		return
+	}
+	if len(req.Params) == 0 {
+		req.Params = json.RawMessage("{}")
+	}
+	jr.start(w, r, prefix, req.Type, req.Params, nil)
+}
+
+// start validates params and queues a job of type typ. stage, when not nil,
+// writes the job's input to its file first.
+func (jr *jobRunner) start(w http.ResponseWriter, r *http.Request, prefix, typ string, params json.RawMessage, stage func(path string) error) {
+	if err := jr.kinds[typ].validate(params); err != nil {
+		jsonError(w, httpErrorStatus(err, http.StatusBadRequest), err.Error())
+		return
+	}
+
+	j := &job{ID: newID(), Type: typ, Params: params, CreatedAt: time.Now().UTC(), changed: make(chan struct{})}
+	if jr.user != nil {
+		j.Owner = jr.user(r)
+	}
+	file := filepath.Join(jr.dir, j.ID)
+	if stage != nil {
+		if err := stage(file); err != nil {
+			os.Remove(file)
+			jsonError(w, httpErrorStatus(err, http.StatusInternalServerError), err.Error())
+			return
+		}
+	}
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+	jr.jobs[j.ID] = j
+	if err := jr.enqueue(j, r); err != nil {
+		delete(jr.jobs, j.ID)
+		os.Remove(file)
+		jsonError(w, httpErrorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+	w.Header().Set("Location", prefix+"/"+j.ID)
+	writeJSON(w, http.StatusAccepted, j.snapshot(false))
+}
+
+// follow streams j as server-sent events: "job" with the record (without
+// logs) whenever its state or progress changes, "log" for each log line,
+// with the line number as ID, and "end" once the job has finished. A
+// Last-Event-ID header skips the lines already received.
+func (jr *jobRunner) follow(w http.ResponseWriter, r *http.Request, j *job) {
+	sent, _ := strconv.Atoi(r.Header.Get("Last-Event-ID"))
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	event := func(name, id string, v interface{}) {
+		data, _ := json.Marshal(v)
+		if id != "" {
+			fmt.Fprintf(w, "id: %s\n", id)
+		}
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data)
+	}
+
+	ping := time.NewTicker(15 * time.Second)
+	defer ping.Stop()
+	var last []byte
+	for {
+		jr.mu.Lock()
+		snap := j.snapshot(false)
+		if sent < j.LogsDropped {
+			sent = j.LogsDropped
+		}
+		var logs []jobLog
+		if i := sent - j.LogsDropped; i < len(j.Logs) {
+			logs = append(logs, j.Logs[i:]...)
+		}
+		changed := j.changed
+		jr.mu.Unlock()
+
+		for _, l := range logs {
+			sent++
+			event("log", strconv.Itoa(sent), l)
+		}
+		if jobFinished(snap.State) {
+			event("end", "", snap)
+			rc.Flush()
+			return
+		}
+		if data, _ := json.Marshal(snap); string(data) != string(last) {
+			event("job", "", snap)
+			last = data
+		}
+		rc.Flush()
+		select {
+		case <-changed:
+		case <-ping.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// download serves the file of a finished export job.
+func (jr *jobRunner) download(w http.ResponseWriter, r *http.Request, j *job) {
+	jr.mu.Lock()
+	state, data, finished := j.State, j.Result, j.FinishedAt
+	jr.mu.Unlock()
+	var res exportJobResult
+	if state != jobSucceeded || json.Unmarshal(data, &res) != nil || res.Filename == "" {
+		jsonError(w, http.StatusNotFound, "This job has no file to download")
+		return
+	}
+	f, err := os.Open(filepath.Join(jr.dir, j.ID))
+	if err != nil {
+		jsonError(w, http.StatusNotFound, "The job's file is gone")
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", res.ContentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+res.Filename+`"`)
+	http.ServeContent(w, r, "", *finished, f)
+}
+
+// ── Job types ───────────────────────────────────────────────────────────────
+
+// exportJobResult describes the file of an export job.
+type exportJobResult struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+	Download    string `json:"download"`
+}
+
+// exportJob runs an /api/v1/export request into a file.
+func exportJob(qr *queryRunner, prefix string) jobKind {
+	plan := func(params json.RawMessage) (*exportPlan, error) {
+		var req exportRequest
+		if err := json.Unmarshal(params, &req); err != nil {
+			return nil, &httpError{http.StatusBadRequest, "Invalid params: " + err.Error()}
+		}
+		return qr.planExport(&req)
+	}
+	return jobKind{
+		validate: func(params json.RawMessage) error {
+			_, err := plan(params)
+			return err
+		},
+		run: func(jc *jobContext) error {
+			p, err := plan(jc.job.Params)
+			if err != nil {
+				return err
+			}
+			f, err := os.Create(jc.artifact())
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			jc.logf("exporting %s in %d windows", p.filename, len(p.windows))
+			err = qr.writeExport(f, jc.r, p, nil, func(done int) {
+				jc.progress(done, len(p.windows), "")
+			})
+			if err != nil {
+				return err
+			}
+			info, err := f.Stat()
+			if err != nil {
+				return err
+			}
+			jc.logf("wrote %d bytes", info.Size())
+			jc.setResult(exportJobResult{Filename: p.filename, ContentType: p.contentType, Size: info.Size(),
+				Download: prefix + "/" + jc.job.ID + "/download"})
+			return nil
+		},
+	}
+}
+
+// migrationJob runs a migration. Resuming the job resumes the migration
+// from its checkpoint.
+func migrationJob(qr *queryRunner, s *migrationStore, user func(*http.Request) string) jobKind {
+	return jobKind{
+		validate: func(params json.RawMessage) error {
+			var spec migrationSpec
+			if err := json.Unmarshal(params, &spec); err != nil {
+				return &httpError{http.StatusBadRequest, "Invalid params: " + err.Error()}
+			}
+			_, err := spec.check(qr)
+			return err
+		},
+		run: func(jc *jobContext) error {
+			var spec migrationSpec
+			if err := json.Unmarshal(jc.job.Params, &spec); err != nil {
+				return err
+			}
+			var m *migration
+			var prev struct {
+				Migration string `json:"migration"`
+			}
+			if jc.result(&prev) && prev.Migration != "" {
+				s.mu.Lock()
+				m = s.migrations[prev.Migration]
+				s.mu.Unlock()
+				if m == nil {
+					return fmt.Errorf("migration %s was deleted", prev.Migration)
+				}
+				if err := s.begin(m); err != nil {
+					return err
+				}
+				jc.logf("resuming migration %s at %s of %s", m.ID, m.Checkpoint.Next.Format(time.RFC3339),
+					m.Measurements[m.Checkpoint.Measurement])
+			} else {
+				var err error
+				if m, err = s.create(jc.r, qr, &spec, user); err != nil {
+					return err
+				}
+				if m.ID != "" {
+					jc.setResult(map[string]string{"migration": m.ID})
+				}
+				jc.logf("migrating %d measurements", len(m.Measurements))
+			}
+
+			err := s.execute(jc.r, qr, m, func(ev migrationEvent) {
+				if ev.Event != "progress" {
+					return
+				}
+				msg := fmt.Sprintf("%s %s – %s: %d samples", ev.Measurement,
+					ev.WindowStart.Format(time.RFC3339), ev.WindowEnd.Format(time.RFC3339), ev.Samples)
+				jc.logf("%s", msg)
+				jc.progress(ev.Progress.WindowsDone, ev.Progress.Windows, msg)
+			})
+			if m.ID == "" {
+				s.mu.Lock()
+				jc.setResult(m)
+				s.mu.Unlock()
+			}
+			return err
+		},
+	}
+}
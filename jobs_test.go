@@ -0,0 +1,79 @@
+package timeseriesui
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJobOwnership(t *testing.T) {
+	jr, err := newJobRunner(t.TempDir(), 1, func(r *http.Request) string { return r.Header.Get("X-User") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	jr.register("wait", jobKind{
+		validate: func(json.RawMessage) error { return nil },
+		run: func(jc *jobContext) error {
+			<-jc.r.Context().Done()
+			return jc.r.Context().Err()
+		},
+	})
+	h := jr.handler("/api/v1/jobs")
+	as := func(user string) map[string]string { return map[string]string{"X-User": user} }
+
+	rec := serve(h, http.MethodPost, "/api/v1/jobs", strings.NewReader(`{"type": "wait"}`), as("alice"))
+	var j job
+	if err := json.Unmarshal(rec.Body.Bytes(), &j); err != nil || rec.Code != http.StatusAccepted || j.Owner != "alice" {
+		t.Fatalf("submit: status %d: %s", rec.Code, rec.Body)
+	}
+	path := "/api/v1/jobs/" + j.ID
+
+	var list []job
+	rec = serve(h, http.MethodGet, "/api/v1/jobs", nil, as("bob"))
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list) != 0 {
+		t.Errorf("bob's list: %s", rec.Body)
+	}
+	rec = serve(h, http.MethodGet, "/api/v1/jobs", nil, as("alice"))
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list) != 1 {
+		t.Errorf("alice's list: %s", rec.Body)
+	}
+	for _, c := range []struct{ method, path string }{
+		{http.MethodGet, path},
+		{http.MethodPost, path + "/cancel"},
+		{http.MethodPost, path + "/resume"},
+		{http.MethodDelete, path},
+		{http.MethodGet, path + "/download"},
+	} {
+		if rec := serve(h, c.method, c.path, nil, as("bob")); rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "belongs to alice") {
+			t.Errorf("bob: %s %s: status %d: %s", c.method, c.path, rec.Code, rec.Body)
+		}
+	}
+
+	if rec := serve(h, http.MethodPost, path+"/cancel", nil, as("alice")); rec.Code != http.StatusAccepted {
+		t.Fatalf("cancel: status %d: %s", rec.Code, rec.Body)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		jr.mu.Lock()
+		state := jr.jobs[j.ID].State
+		jr.mu.Unlock()
+		if jobFinished(state) {
+			if state != jobCanceled {
+				t.Fatalf("state %s after cancel", state)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("job not canceled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if rec := serve(h, http.MethodDelete, path, nil, as("alice")); rec.Code != http.StatusNoContent {
+		t.Errorf("delete: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(h, http.MethodGet, path, nil, as("alice")); rec.Code != http.StatusNotFound {
+		t.Errorf("after delete: status %d", rec.Code)
+	}
+}
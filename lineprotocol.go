@@ -0,0 +1,683 @@
+package timeseriesui
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ── InfluxDB line protocol ──────────────────────────────────────────────────
+//
+//	measurement[,tag=value…] field=value[,field=value…] [timestamp]
+//
+// Measurements escape commas and spaces; tag keys, tag values and field
+// keys also escape "=". String field values are quoted.
+
+// appendLineEscaped appends s with backslashes before the characters in
+// special. A trailing backslash is doubled so it cannot escape the
+// delimiter that follows.
+func appendLineEscaped(b []byte, s, special string) []byte {
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(special, s[i]) >= 0 {
+			b = append(b, '\\')
+		}
+		b = append(b, s[i])
+	}
+	if strings.HasSuffix(s, "\\") {
+		b = append(b, '\\')
+	}
+	return b
+}
+
+// lineBreaks replaces line breaks in tag values, which line protocol cannot
+// escape, with spaces.
+var lineBreaks = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ")
+
+// appendLine appends one point with a float field and a timestamp in the
+// write's precision. Tags are appended in the order of keys.
+func appendLine(b []byte, measurement string, keys []string, tags map[string]string, field string, value float64, ts int64) []byte {
+	b = appendLineEscaped(b, measurement, ", ")
+	for _, k := range keys {
+		if tags[k] == "" {
+			continue
+		}
+		b = append(b, ',')
+		b = appendLineEscaped(b, k, ",= ")
+		b = append(b, '=')
+		b = appendLineEscaped(b, lineBreaks.Replace(tags[k]), ",= ")
+	}
+	b = append(b, ' ')
+	b = appendLineEscaped(b, field, ",= ")
+	b = append(b, '=')
+	b = strconv.AppendFloat(b, value, 'g', -1, 64)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, ts, 10)
+	return append(b, '\n')
+}
+
+// appendPoint appends p, whose field values are already line protocol
+// literals. Empty tag values are left out.
+func appendPoint(b []byte, p *linePoint) []byte {
+	b = appendLineEscaped(b, p.Measurement, ", ")
+	for _, t := range p.Tags {
+		if t.Value == "" {
+			continue
+		}
+		b = append(b, ',')
+		b = appendLineEscaped(b, t.Key, ",= ")
+		b = append(b, '=')
+		b = appendLineEscaped(b, lineBreaks.Replace(t.Value), ",= ")
+	}
+	for i, f := range p.Fields {
+		if i == 0 {
+			b = append(b, ' ')
+		} else {
+			b = append(b, ',')
+		}
+		b = appendLineEscaped(b, f.Key, ",= ")
+		b = append(b, '=')
+		b = append(b, f.Raw...)
+	}
+	if p.HasTime {
+		b = append(b, ' ')
+		b = strconv.AppendInt(b, p.Time, 10)
+	}
+	return append(b, '\n')
+}
+
+// ── Parsing ─────────────────────────────────────────────────────────────────
+
+// linePoint is one parsed line.
+type linePoint struct {
+	Measurement string
+	Tags        []lineTag
+	Fields      []lineField
+	Time        int64
+	HasTime     bool
+	timePos     int // byte offset of the timestamp
+}
+
+type lineTag struct{ Key, Value string }
+
+// lineField is a field with its type ("float", "integer", "unsigned",
+// "string" or "boolean") and its value as written.
+type lineField struct {
+	Key, Type, Raw string
+	pos            int // byte offset in the line
+}
+
+// lineSyntaxError is a parse error at a byte offset of the line.
+type lineSyntaxError struct {
+	pos int
+	msg string
+}
+
+func (e *lineSyntaxError) Error() string { return e.msg }
+
+// parseLine parses one line that is neither blank nor a comment.
+func parseLine(s string) (*linePoint, error) {
+	fail := func(pos int, format string, args ...interface{}) (*linePoint, error) {
+		return nil, &lineSyntaxError{pos, fmt.Sprintf(format, args...)}
+	}
+	p := &linePoint{}
+	var i int
+	p.Measurement, i = scanLineName(s, 0, ", ")
+	if p.Measurement == "" {
+		return fail(0, "missing measurement")
+	}
+	for i < len(s) && s[i] == ',' {
+		var t lineTag
+		start := i + 1
+		t.Key, i = scanLineName(s, start, ",= ")
+		if t.Key == "" {
+			return fail(start, "missing tag key")
+		}
+		if i >= len(s) || s[i] != '=' {
+			return fail(i, "missing tag value for %q", t.Key)
+		}
+		t.Value, i = scanLineName(s, i+1, ",= ")
+		if t.Value == "" {
+			return fail(i, "missing tag value for %q", t.Key)
+		}
+		if i < len(s) && s[i] == '=' {
+			return fail(i, "unescaped \"=\" in the value of tag %q", t.Key)
+		}
+		p.Tags = append(p.Tags, t)
+	}
+	for i < len(s) && s[i] == ' ' {
+		i++
+	}
+	if i >= len(s) {
+		return fail(i, "missing fields")
+	}
+
+	for {
+		f := lineField{pos: i}
+		f.Key, i = scanLineName(s, i, ",= ")
+		if f.Key == "" {
+			return fail(f.pos, "missing field key")
+		}
+		if i >= len(s) || s[i] != '=' {
+			return fail(i, "missing value for field %q", f.Key)
+		}
+		i++
+		start := i
+		if i < len(s) && s[i] == '"' {
+			j := i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return fail(start, "unterminated string in field %q", f.Key)
+			}
+			i = j + 1
+			f.Type, f.Raw = "string", s[start:i]
+		} else {
+			for i < len(s) && s[i] != ',' && s[i] != ' ' {
+				i++
+			}
+			f.Raw = s[start:i]
+			if f.Raw == "" {
+				return fail(start, "missing value for field %q", f.Key)
+			}
+			typ, err := lineFieldType(f.Raw)
+			if err != nil {
+				return fail(start, "invalid value %q for field %q: %s", f.Raw, f.Key, err)
+			}
+			f.Type = typ
+		}
+		p.Fields = append(p.Fields, f)
+		if i < len(s) && s[i] == ',' {
+			i++
+			continue
+		}
+		break
+	}
+	if i < len(s) && s[i] != ' ' {
+		return fail(i, "unexpected %q after the fields", s[i])
+	}
+
+	for i < len(s) && s[i] == ' ' {
+		i++
+	}
+	if i < len(s) {
+		start := i
+		for i < len(s) && s[i] != ' ' {
+			i++
+		}
+		ts, err := strconv.ParseInt(s[start:i], 10, 64)
+		if err != nil {
+			return fail(start, "invalid timestamp %q", s[start:i])
+		}
+		p.Time, p.HasTime = ts, true
+		p.timePos = start
+		for i < len(s) && s[i] == ' ' {
+			i++
+		}
+		if i < len(s) {
+			return fail(i, "unexpected text after the timestamp")
+		}
+	}
+	return p, nil
+}
+
+// scanLineName reads an escaped name from s[i:] up to the first unescaped
+// character of stop.
+func scanLineName(s string, i int, stop string) (string, int) {
+	var b []byte
+	for i < len(s) {
+		c := s[i]
+		if c == '\\' && i+1 < len(s) {
+			// Like InfluxDB, a backslash escapes any character but only
+			// the characters of stop are unescaped.
+			if strings.IndexByte(stop, s[i+1]) < 0 {
+				b = append(b, c)
+			}
+			b = append(b, s[i+1])
+			i += 2
+			continue
+		}
+		if strings.IndexByte(stop, c) >= 0 {
+			break
+		}
+		b = append(b, c)
+		i++
+	}
+	return string(b), i
+}
+
+// lineFieldType returns the type of an unquoted field value.
+func lineFieldType(v string) (string, error) {
+	switch v {
+	case "t", "T", "true", "True", "TRUE", "f", "F", "false", "False", "FALSE":
+		return "boolean", nil
+	}
+	switch last := v[len(v)-1]; last {
+	case 'i':
+		if _, err := strconv.ParseInt(v[:len(v)-1], 10, 64); err != nil {
+			return "", numError(err, "integer")
+		}
+		return "integer", nil
+	case 'u':
+		if _, err := strconv.ParseUint(v[:len(v)-1], 10, 64); err != nil {
+			return "", numError(err, "unsigned integer")
+		}
+		return "unsigned", nil
+	}
+	if strings.Trim(v, "0123456789+-.eE") != "" {
+		return "", errors.New("not a number, boolean or quoted string")
+	}
+	if _, err := strconv.ParseFloat(v, 64); err != nil {
+		return "", numError(err, "float")
+	}
+	return "float", nil
+}
+
+func numError(err error, kind string) error {
+	if errors.Is(err, strconv.ErrRange) {
+		return errors.New(kind + " out of range")
+	}
+	return errors.New("invalid " + kind)
+}
+
+// ── Validation ──────────────────────────────────────────────────────────────
+
+const maxLineErrors = 100 // errors listed in a report
+
+// lineReport describes a batch of line protocol.
+type lineReport struct {
+	Valid               bool              `json:"valid"`
+	Error               string            `json:"error,omitempty"` // the first error, when invalid
+	Lines               int               `json:"lines"`           // not blank or comments
+	Points              int               `json:"points"`
+	Errors              []lineError       `json:"errors"`
+	ErrorCount          int               `json:"errorCount"`
+	Measurements        int               `json:"measurements"`
+	TagKeys             int               `json:"tagKeys"` // distinct per measurement
+	Fields              int               `json:"fields"`  // distinct per measurement
+	Series              int               `json:"series"`
+	Precision           string            `json:"precision"`
+	DetectedPrecision   string            `json:"detectedPrecision,omitempty"`
+	PrecisionMismatches int               `json:"precisionMismatches"`
+	NoTimestamp         int               `json:"noTimestamp"`
+	MinTime             *time.Time        `json:"minTime,omitempty"`
+	MaxTime             *time.Time        `json:"maxTime,omitempty"`
+	Warnings            []string          `json:"warnings,omitempty"`
+	Breakdown           []lineMeasurement `json:"breakdown"`
+}
+
+// lineError is an invalid line. Line and column count from 1; the column
+// counts characters.
+type lineError struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+	Text    string `json:"text"` // the line, truncated
+}
+
+// lineMeasurement is the breakdown of one measurement.
+type lineMeasurement struct {
+	Name    string            `json:"name"`
+	Points  int               `json:"points"`
+	Series  int               `json:"series"`
+	TagKeys []string          `json:"tagKeys"`
+	Fields  map[string]string `json:"fields"` // key → type
+}
+
+// lineValidator accumulates a lineReport line by line.
+type lineValidator struct {
+	rep       lineReport
+	unit      time.Duration // 0 for auto
+	line      int
+	series    map[string]bool
+	measures  map[string]*lineMeasurement
+	tagKeys   map[string]map[string]bool
+	fieldLine map[string]int // measurement\x00field → line of its first type
+	detected  map[string]int // guessed precision → points
+	mismatch  map[string]*precisionMismatch
+}
+
+// linePrecisions maps the precision names of the InfluxDB 1.x, 2.x and 3
+// write APIs to units; "auto" (0) guesses per point.
+var linePrecisions = map[string]time.Duration{
+	"n": time.Nanosecond, "ns": time.Nanosecond, "nanosecond": time.Nanosecond,
+	"u": time.Microsecond, "us": time.Microsecond, "µ": time.Microsecond, "microsecond": time.Microsecond,
+	"ms": time.Millisecond, "millisecond": time.Millisecond,
+	"s": time.Second, "second": time.Second,
+	"m": time.Minute, "h": time.Hour,
+	"auto": 0,
+}
+
+var precisionNames = map[time.Duration]string{
+	time.Nanosecond: "ns", time.Microsecond: "us", time.Millisecond: "ms", time.Second: "s",
+	time.Minute: "m", time.Hour: "h", 0: "auto",
+}
+
+func newLineValidator(precision string) (*lineValidator, error) {
+	unit, ok := linePrecisions[precision]
+	if !ok {
+		return nil, &httpError{http.StatusBadRequest, fmt.Sprintf("invalid precision %q", precision)}
+	}
+	return &lineValidator{
+		rep:       lineReport{Precision: precisionNames[unit], Errors: []lineError{}},
+		unit:      unit,
+		series:    map[string]bool{},
+		measures:  map[string]*lineMeasurement{},
+		tagKeys:   map[string]map[string]bool{},
+		fieldLine: map[string]int{},
+		detected:  map[string]int{},
+		mismatch:  map[string]*precisionMismatch{},
+	}, nil
+}
+
+// check validates every line of r.
+func (v *lineValidator) check(r io.Reader) (*lineReport, error) {
+	br := bufio.NewReader(r)
+	for {
+		s, err := br.ReadString('\n')
+		if s != "" {
+			v.add(strings.TrimRight(s, "\r\n"))
+		}
+		if err == io.EOF {
+			return v.report(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// add validates the next line.
+func (v *lineValidator) add(s string) {
+	v.line++
+	if t := strings.TrimLeft(s, " \t"); t == "" || t[0] == '#' {
+		return
+	}
+	v.rep.Lines++
+	p, err := parseLine(s)
+	if err != nil {
+		v.fail(s, err.(*lineSyntaxError).pos, err.Error())
+		return
+	}
+	var guess string
+	var unit time.Duration
+	if p.HasTime {
+		guess = guessPrecision(p.Time)
+		if unit = v.unit; unit == 0 {
+			unit = linePrecisions[guess]
+		}
+		if unit != 0 && (p.Time > math.MaxInt64/int64(unit) || p.Time < math.MinInt64/int64(unit)) {
+			v.fail(s, p.timePos, fmt.Sprintf("timestamp %d is out of range", p.Time))
+			return
+		}
+	}
+	m := v.measures[p.Measurement]
+	if m == nil {
+		m = &lineMeasurement{Name: p.Measurement, TagKeys: []string{}, Fields: map[string]string{}}
+		v.measures[p.Measurement] = m
+		v.tagKeys[p.Measurement] = map[string]bool{}
+	}
+	for _, f := range p.Fields {
+		if typ, ok := m.Fields[f.Key]; ok && typ != f.Type {
+			v.fail(s, f.pos, fmt.Sprintf("field %q is %s, but %s on line %d", f.Key, f.Type, typ, v.fieldLine[p.Measurement+"\x00"+f.Key]))
+			return
+		}
+	}
+	for _, f := range p.Fields {
+		if _, ok := m.Fields[f.Key]; !ok {
+			m.Fields[f.Key] = f.Type
+			v.fieldLine[p.Measurement+"\x00"+f.Key] = v.line
+		}
+	}
+
+	v.rep.Points++
+	m.Points++
+	tags := append([]lineTag(nil), p.Tags...)
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Key < tags[j].Key })
+	key := p.Measurement
+	for _, t := range tags {
+		key += "\x00" + t.Key + "\x00" + t.Value
+		if !v.tagKeys[p.Measurement][t.Key] {
+			v.tagKeys[p.Measurement][t.Key] = true
+			m.TagKeys = append(m.TagKeys, t.Key)
+		}
+	}
+	if !v.series[key] {
+		v.series[key] = true
+		m.Series++
+	}
+
+	if !p.HasTime {
+		v.rep.NoTimestamp++
+		return
+	}
+	v.detected[guess]++
+	if unit == 0 {
+		return
+	}
+	t := time.Unix(0, 0).UTC().Add(time.Duration(p.Time) * unit)
+	if v.unit != 0 && v.unit <= time.Second && guess != v.rep.Precision {
+		v.rep.PrecisionMismatches++
+		if m := v.mismatch[guess]; m != nil {
+			m.count++
+		} else {
+			v.mismatch[guess] = &precisionMismatch{count: 1, line: v.line, at: t}
+		}
+	}
+	if v.rep.MinTime == nil || t.Before(*v.rep.MinTime) {
+		min := t
+		v.rep.MinTime = &min
+	}
+	if v.rep.MaxTime == nil || t.After(*v.rep.MaxTime) {
+		max := t
+		v.rep.MaxTime = &max
+	}
+}
+
+func (v *lineValidator) fail(s string, pos int, msg string) {
+	v.rep.ErrorCount++
+	if len(v.rep.Errors) >= maxLineErrors {
+		return
+	}
+	text := s
+	if len(text) > 200 {
+		text = strings.ToValidUTF8(text[:200], "") + "…"
+	}
+	v.rep.Errors = append(v.rep.Errors, lineError{
+		Line:    v.line,
+		Column:  utf8.RuneCountInString(s[:pos]) + 1,
+		Message: msg,
+		Text:    text,
+	})
+}
+
+// report finishes the counts and warnings.
+func (v *lineValidator) report() *lineReport {
+	rep := v.rep
+	rep.Valid = rep.ErrorCount == 0 && rep.Points > 0
+	switch {
+	case rep.ErrorCount > 0:
+		e := rep.Errors[0]
+		rep.Error = fmt.Sprintf("%d invalid lines; line %d, column %d: %s", rep.ErrorCount, e.Line, e.Column, e.Message)
+		if rep.ErrorCount == 1 {
+			rep.Error = fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+		}
+	case rep.Points == 0:
+		rep.Error = "no points to write"
+	}
+
+	best := 0
+	for p, n := range v.detected {
+		if p != "" && n > best {
+			rep.DetectedPrecision, best = p, n
+		}
+	}
+	for _, p := range []string{"s", "ms", "us", "ns", ""} {
+		m := v.mismatch[p]
+		if m == nil {
+			continue
+		}
+		look := "look like " + precisionWords[p]
+		if p == "" {
+			look = "are not between 1990 and 2100 in any precision"
+		}
+		rep.Warnings = append(rep.Warnings, fmt.Sprintf("%d of %d timestamps %s, but the precision is %s: line %d would be written at %s",
+			m.count, rep.Points-rep.NoTimestamp, look, precisionWords[rep.Precision], m.line, m.at.Format(time.RFC3339Nano)))
+	}
+	if n := v.detected[""]; n > 0 && v.unit == 0 {
+		rep.Warnings = append(rep.Warnings, fmt.Sprintf("the precision of %d timestamps could not be guessed", n))
+	}
+
+	names := make([]string, 0, len(v.measures))
+	for name := range v.measures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	rep.Breakdown = make([]lineMeasurement, 0, len(names))
+	for _, name := range names {
+		m := *v.measures[name]
+		sort.Strings(m.TagKeys)
+		rep.TagKeys += len(m.TagKeys)
+		rep.Fields += len(m.Fields)
+		rep.Breakdown = append(rep.Breakdown, m)
+	}
+	rep.Measurements = len(names)
+	rep.Series = len(v.series)
+	return &rep
+}
+
+// precisionMismatch counts the timestamps guessed to have one precision
+// other than the requested one, with the first of them.
+type precisionMismatch struct {
+	count, line int
+	at          time.Time
+}
+
+var precisionWords = map[string]string{"ns": "nanoseconds", "us": "microseconds", "ms": "milliseconds", "s": "seconds", "m": "minutes", "h": "hours"}
+
+// guessPrecision returns the unit that puts ts between 1990 and 2100, or ""
+// when none does. The ranges of the units do not overlap.
+func guessPrecision(ts int64) string {
+	const lo, hi = 631152000, 4102444800 // 1990-01-01, 2100-01-01 in seconds
+	f := math.Abs(float64(ts))
+	for _, p := range []struct {
+		name  string
+		scale float64
+	}{{"s", 1}, {"ms", 1e3}, {"us", 1e6}, {"ns", 1e9}} {
+		if f >= lo*p.scale && f < hi*p.scale {
+			return p.name
+		}
+	}
+	return ""
+}
+
+// ── Dry runs ────────────────────────────────────────────────────────────────
+//
+// POST requests to the line protocol write APIs accept two parameters that
+// are handled here rather than upstream:
+//
+//	dryRun=true    validate and report; nothing is written
+//	validate=true  write only when every line is valid, else answer 400
+//	               with the report
+//
+// The report lists per-line errors with line and column, the measurement,
+// tag and field counts, the distinct series, and timestamps that look like
+// another precision than the one requested.
+
+// lineProtocolPaths are the line protocol write APIs of each connection type.
+var lineProtocolPaths = map[string][]string{
+	"influxdb":        {"/write", "/api/v2/write"},
+	"influxdb2":       {"/write", "/api/v2/write"},
+	"influxdb3":       {"/write", "/api/v2/write", "/api/v3/write_lp"},
+	"victoriametrics": {"/write", "/influx/write", "/influx/api/v2/write"},
+}
+
+// lineProtocolAPIPath cleans the API path p and strips the /insert/<tenant>
+// prefix of VictoriaMetrics cluster pass-through paths, so that p can be
+// matched against lineProtocolPaths.
+func lineProtocolAPIPath(typ, p string) string {
+	p = classifyPath(p)
+	if typ == "victoriametrics" && strings.HasPrefix(p, "/insert/") {
+		if _, rest, ok := strings.Cut(strings.TrimPrefix(p, "/insert/"), "/"); ok {
+			p = "/" + rest
+		}
+	}
+	return p
+}
+
+// lineProtocolCheck serves dry runs of the writes to typ's line protocol
+// APIs, and validates them before next when asked. apiPath returns the
+// backend API path of a request.
+func lineProtocolCheck(typ string, apiPath func(*http.Request) string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		dryRun, _ := strconv.ParseBool(q.Get("dryRun"))
+		validate, _ := strconv.ParseBool(q.Get("validate"))
+		path := lineProtocolAPIPath(typ, apiPath(r))
+		if (!dryRun && !validate) || r.Method != http.MethodPost || !containsString(lineProtocolPaths[typ], path) {
+			next(w, r)
+			return
+		}
+		setCORS(w)
+		q.Del("dryRun")
+		q.Del("validate")
+		r.URL.RawQuery = q.Encode()
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			var mbe *http.MaxBytesError
+			if errors.As(err, &mbe) {
+				jsonError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+			} else {
+				jsonError(w, http.StatusBadRequest, "Failed to read the request body: "+err.Error())
+			}
+			return
+		}
+		text := io.Reader(bytes.NewReader(body))
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			if text, err = gzip.NewReader(text); err != nil {
+				jsonError(w, http.StatusBadRequest, "Invalid gzip body: "+err.Error())
+				return
+			}
+		}
+		precision := q.Get("precision")
+		if precision == "" {
+			precision = "ns"
+			if path == "/api/v3/write_lp" {
+				precision = "auto"
+			}
+		}
+		v, err := newLineValidator(precision)
+		if err != nil {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		rep, err := v.check(text)
+		if err != nil {
+			jsonError(w, http.StatusBadRequest, "Failed to read the line protocol: "+err.Error())
+			return
+		}
+		switch {
+		case dryRun:
+			writeJSON(w, http.StatusOK, rep)
+		case !rep.Valid:
+			writeJSON(w, http.StatusBadRequest, rep)
+		default:
+			r.Body, r.ContentLength = io.NopCloser(bytes.NewReader(body)), int64(len(body))
+			next(w, r)
+		}
+	}
+}
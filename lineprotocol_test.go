@@ -0,0 +1,162 @@
+package timeseriesui
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAppendPointEscaping(t *testing.T) {
+	cases := []struct {
+		p    linePoint
+		want string
+	}{
+		{
+			linePoint{Measurement: "cpu load,total", Tags: []lineTag{{"host name", "a=b,c"}}, Fields: []lineField{{Key: "v=1", Raw: "1"}}},
+			`cpu\ load\,total,host\ name=a\=b\,c v\=1=1` + "\n",
+		},
+		{
+			linePoint{Measurement: "disk", Tags: []lineTag{{"path", `C:\`}, {"host", "a"}}, Fields: []lineField{{Key: "used", Raw: "1"}}, Time: 5, HasTime: true},
+			`disk,path=C:\\,host=a used=1 5` + "\n",
+		},
+		{
+			linePoint{Measurement: "log", Tags: []lineTag{{"msg", "two\r\nlines\n"}, {"empty", ""}}, Fields: []lineField{{Key: "n", Raw: `"x"`}}},
+			`log,msg=two\ lines\  n="x"` + "\n",
+		},
+	}
+	for _, c := range cases {
+		got := string(appendPoint(nil, &c.p))
+		if got != c.want {
+			t.Errorf("appendPoint(%+v) = %q, want %q", c.p, got, c.want)
+			continue
+		}
+		p, err := parseLine(strings.TrimSuffix(got, "\n"))
+		if err != nil {
+			t.Errorf("parseLine(%q): %v", got, err)
+			continue
+		}
+		if p.Measurement != c.p.Measurement || len(p.Tags) != len(c.p.Tags)-countEmpty(c.p.Tags) || len(p.Fields) != len(c.p.Fields) {
+			t.Errorf("parseLine(%q) = %+v", got, p)
+		}
+	}
+}
+
+func countEmpty(tags []lineTag) int {
+	n := 0
+	for _, t := range tags {
+		if t.Value == "" {
+			n++
+		}
+	}
+	return n
+}
+
+func TestParseLineNames(t *testing.T) {
+	p, err := parseLine(`m\ 1,k\=1=v\,1,path=C:\\,x=\a f\ 1=1i`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []lineTag{{"k=1", "v,1"}, {"path", `C:\\`}, {"x", `\a`}}
+	if p.Measurement != "m 1" || !reflect.DeepEqual(p.Tags, want) || p.Fields[0].Key != "f 1" || p.Fields[0].Type != "integer" {
+		t.Errorf("parseLine = %+v", p)
+	}
+}
+
+func TestLineValidatorErrors(t *testing.T) {
+	cases := []struct {
+		precision, text string
+		line, column    int
+		message         string
+	}{
+		{"ns", "m", 1, 2, "missing fields"},
+		{"ns", "m,t v=1", 1, 4, `missing tag value for "t"`},
+		{"ns", "m,t=a=b v=1", 1, 6, `unescaped "=" in the value of tag "t"`},
+		{"ns", "m v=abc", 1, 5, `invalid value "abc" for field "v"`},
+		{"ns", `m v="abc`, 1, 5, `unterminated string in field "v"`},
+		{"ns", "m v=1 12x", 1, 7, `invalid timestamp "12x"`},
+		{"ns", "m v=1 1 2", 1, 9, "unexpected text after the timestamp"},
+		{"ns", "温度 v=x", 1, 6, `invalid value "x" for field "v"`},
+		{"ns", "m v=1i\nm v=1", 2, 3, `field "v" is float, but integer on line 1`},
+		{"s", "m v=1 9223372036854775", 1, 7, "timestamp 9223372036854775 is out of range"},
+		{"h", "m v=1 -2562048", 1, 7, "timestamp -2562048 is out of range"},
+	}
+	for _, c := range cases {
+		v, err := newLineValidator(c.precision)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rep, err := v.check(strings.NewReader(c.text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rep.Valid || rep.ErrorCount != 1 {
+			t.Errorf("%q: valid %v with %d errors", c.text, rep.Valid, rep.ErrorCount)
+			continue
+		}
+		e := rep.Errors[0]
+		if e.Line != c.line || e.Column != c.column || !strings.HasPrefix(e.Message, c.message) {
+			t.Errorf("%q: line %d, column %d: %s; want line %d, column %d: %s", c.text, e.Line, e.Column, e.Message, c.line, c.column, c.message)
+		}
+	}
+}
+
+func TestLineValidatorReport(t *testing.T) {
+	v, err := newLineValidator("s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep, err := v.check(strings.NewReader("# comment\n\ncpu,host=a v=1 1700000000\ncpu,host=b v=2,w=3i 1700000060\nmem free=1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rep.Valid || rep.Lines != 3 || rep.Points != 3 || rep.Series != 3 || rep.Measurements != 2 || rep.NoTimestamp != 1 {
+		t.Errorf("report %+v", rep)
+	}
+	if rep.MinTime == nil || rep.MinTime.Unix() != 1700000000 || rep.MaxTime.Unix() != 1700000060 {
+		t.Errorf("time range %v to %v", rep.MinTime, rep.MaxTime)
+	}
+}
+
+func TestLineProtocolDryRunPaths(t *testing.T) {
+	writes := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writes++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer upstream.Close()
+	h := newTestHandler(t, Options{})
+	for _, c := range []struct{ typ, path string }{
+		{"influxdb", "/write"},
+		{"influxdb", "//write"},
+		{"influxdb2", "/api/v2/./write"},
+		{"victoriametrics", "/influx/write"},
+		{"victoriametrics", "/insert/0/influx/write"},
+		{"victoriametrics", "/insert/1:2/influx/api/v2/write"},
+		{"victoriametrics", "//insert/0//write"},
+	} {
+		rec := serve(h, http.MethodPost, proxyPath(c.typ, upstream.URL, c.path)+"&dryRun=true", strings.NewReader("cpu v=1\n"), nil)
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"valid":true`) {
+			t.Errorf("%s %s: status %d: %s", c.typ, c.path, rec.Code, rec.Body)
+		}
+	}
+	if writes != 0 {
+		t.Errorf("dry runs reached the upstream %d times", writes)
+	}
+}
+
+func TestLineProtocolCheckReadError(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(strings.Repeat("cpu v=1\n", 100)))
+	zw.Close()
+	body := buf.Bytes()[:buf.Len()/2]
+	h := newTestHandler(t, Options{})
+	rec := serve(h, http.MethodPost, proxyPath("influxdb", "http://127.0.0.1:1", "/write")+"&dryRun=true", bytes.NewReader(body), map[string]string{"Content-Encoding": "gzip"})
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "Failed to read the line protocol") {
+		t.Errorf("status %d: %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,313 @@
+package timeseriesui
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ── Short links ─────────────────────────────────────────────────────────────
+//
+// A short link stores a view — connection, page, query, time range and
+// display options — under a short ID, persisted to links.json in the data
+// directory. /s/<id> redirects to the SPA route with the stored fields as
+// query parameters, for clients that read them; the bundled UI opens the
+// route but does not restore the view:
+//
+//	POST   /api/v1/links
+//	GET    /api/v1/links
+//	GET    /api/v1/links/<id>
+//	DELETE /api/v1/links/<id>
+//	GET    /s/<id>
+
+// shortLink is one stored view.
+type shortLink struct {
+	ID         string            `json:"id"`
+	Connection string            `json:"connection,omitempty"` // name or URL
+	Page       string            `json:"page"`                 // SPA route, e.g. "/prometheus/query"
+	Query      string            `json:"query,omitempty"`
+	Start      string            `json:"start,omitempty"` // absolute, or relative such as "now-1h"
+	End        string            `json:"end,omitempty"`
+	PinTime    bool              `json:"pinTime,omitempty"` // relative times were resolved when the link was made
+	Options    map[string]string `json:"options,omitempty"`
+	CreatedBy  string            `json:"createdBy,omitempty"`
+	CreatedAt  time.Time         `json:"createdAt"`
+	ExpiresAt  *time.Time        `json:"expiresAt,omitempty"`
+}
+
+// linkRequest is the body of POST /api/v1/links.
+type linkRequest struct {
+	shortLink
+	ExpiresIn string `json:"expiresIn,omitempty"` // duration such as "24h" or "7d"
+}
+
+// expired reports whether l has expired at now.
+func (l *shortLink) expired(now time.Time) bool {
+	return l.ExpiresAt != nil && !now.Before(*l.ExpiresAt)
+}
+
+// reservedLinkParams are the query parameters the redirect sets itself.
+var reservedLinkParams = map[string]bool{"connection": true, "query": true, "start": true, "end": true}
+
+// validate checks the fields a client may set and, with PinTime, resolves
+// relative times against now.
+func (l *shortLink) validate(now time.Time) error {
+	if !strings.HasPrefix(l.Page, "/") || strings.HasPrefix(l.Page, "//") ||
+		strings.ContainsAny(l.Page, "?#\\") || strings.Contains(l.Page, "..") {
+		return fmt.Errorf("page must be an SPA route such as /prometheus/query")
+	}
+	for k := range l.Options {
+		if reservedLinkParams[k] {
+			return fmt.Errorf("option %q is reserved", k)
+		}
+	}
+	for _, ts := range []*string{&l.Start, &l.End} {
+		if *ts == "" {
+			continue
+		}
+		t, relative, err := parseRelativeTime(*ts, now)
+		if err != nil {
+			return err
+		}
+		if l.PinTime && relative {
+			*ts = t.UTC().Format(time.RFC3339)
+		}
+	}
+	return nil
+}
+
+// redirectURL is the SPA location of l under basePath.
+func (l *shortLink) redirectURL(basePath string) string {
+	q := url.Values{}
+	for k, v := range l.Options {
+		q.Set(k, v)
+	}
+	for k, v := range map[string]string{"connection": l.Connection, "query": l.Query, "start": l.Start, "end": l.End} {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	u := basePath + "/ui" + l.Page
+	if enc := q.Encode(); enc != "" {
+		u += "?" + enc
+	}
+	return u
+}
+
+// linkStore holds the links in memory and, with a path, on disk.
+type linkStore struct {
+	mu    sync.Mutex
+	links map[string]*shortLink
+	path  string
+}
+
+func newLinkStore(dir string) (*linkStore, error) {
+	s := &linkStore{links: map[string]*shortLink{}}
+	if dir == "" {
+		return s, nil
+	}
+	s.path = filepath.Join(dir, "links.json")
+	var list []*shortLink
+	if err := loadJSONFile(s.path, &list); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, l := range list {
+		if !l.expired(now) {
+			s.links[l.ID] = l
+		}
+	}
+	return s, nil
+}
+
+// save drops expired links and persists the rest; the caller holds mu.
+func (s *linkStore) save(now time.Time) error {
+	for id, l := range s.links {
+		if l.expired(now) {
+			delete(s.links, id)
+		}
+	}
+	if s.path == "" {
+		return nil
+	}
+	return saveJSONFile(s.path, s.sorted())
+}
+
+// sorted returns the links newest first; the caller holds mu.
+func (s *linkStore) sorted() []*shortLink {
+	list := make([]*shortLink, 0, len(s.links))
+	for _, l := range s.links {
+		list = append(list, l)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.After(list[j].CreatedAt)
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// get returns the link with id unless it is missing or expired.
+func (s *linkStore) get(id string) (*shortLink, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.links[id]
+	if !ok || l.expired(time.Now()) {
+		return nil, false
+	}
+	return l, true
+}
+
+// add stores l under a new ID.
+func (s *linkStore) add(l *shortLink, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l.ID = newLinkID()
+	for s.links[l.ID] != nil {
+		l.ID = newLinkID()
+	}
+	s.links[l.ID] = l
+	if err := s.save(now); err != nil {
+		delete(s.links, l.ID)
+		return err
+	}
+	return nil
+}
+
+// remove deletes l.
+func (s *linkStore) remove(l *shortLink) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.links, l.ID)
+	if err := s.save(time.Now()); err != nil {
+		s.links[l.ID] = l
+		return err
+	}
+	return nil
+}
+
+const linkIDAlphabet = "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// newLinkID returns a random 8-character ID without look-alike characters.
+func newLinkID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	for i := range b {
+		b[i] = linkIDAlphabet[int(b[i])%len(linkIDAlphabet)]
+	}
+	return string(b[:])
+}
+
+// handler serves the link API. user may be nil.
+func (s *linkStore) handler(prefix, basePath string, user func(*http.Request) string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setCORS(w)
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		id := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+
+		if id != "" {
+			l, ok := s.get(id)
+			if !ok {
+				jsonError(w, http.StatusNotFound, "Link not found")
+				return
+			}
+			switch r.Method {
+			case http.MethodGet:
+				writeJSON(w, http.StatusOK, linkResponse(l, basePath))
+			case http.MethodDelete:
+				if err := s.remove(l); err != nil {
+					jsonError(w, http.StatusInternalServerError, "Failed to save: "+err.Error())
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+			default:
+				jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			s.mu.Lock()
+			now := time.Now()
+			out := []interface{}{}
+			for _, l := range s.sorted() {
+				if !l.expired(now) {
+					out = append(out, linkResponse(l, basePath))
+				}
+			}
+			s.mu.Unlock()
+			writeJSON(w, http.StatusOK, out)
+		case http.MethodPost:
+			var req linkRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				jsonError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+				return
+			}
+			now := time.Now().UTC()
+			l := req.shortLink
+			if err := l.validate(now); err != nil {
+				jsonError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if req.ExpiresIn != "" {
+				d, err := parseLongDuration(req.ExpiresIn)
+				if err != nil {
+					jsonError(w, http.StatusBadRequest, "Invalid expiresIn: "+err.Error())
+					return
+				}
+				at := now.Add(d)
+				l.ExpiresAt = &at
+			} else if l.ExpiresAt != nil && !l.ExpiresAt.After(now) {
+				jsonError(w, http.StatusBadRequest, "expiresAt is in the past")
+				return
+			}
+			l.CreatedAt = now
+			if user != nil {
+				l.CreatedBy = user(r)
+			}
+
+			if err := s.add(&l, now); err != nil {
+				jsonError(w, http.StatusInternalServerError, "Failed to save: "+err.Error())
+				return
+			}
+			writeJSON(w, http.StatusCreated, linkResponse(&l, basePath))
+		default:
+			jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	}
+}
+
+// linkResponse adds the short URL and the target location to l.
+func linkResponse(l *shortLink, basePath string) interface{} {
+	return struct {
+		*shortLink
+		URL      string `json:"url"`
+		Location string `json:"location"`
+	}{l, basePath + "/s/" + l.ID, l.redirectURL(basePath)}
+}
+
+// redirectHandler serves /s/<id>.
+func (s *linkStore) redirectHandler(prefix, basePath string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+		l, ok := s.get(id)
+		if !ok {
+			http.Error(w, "This link does not exist or has expired.", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		http.Redirect(w, r, l.redirectURL(basePath), http.StatusFound)
+	}
+}
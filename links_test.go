@@ -0,0 +1,46 @@
+package timeseriesui
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestLinks(t *testing.T) {
+	dir := t.TempDir()
+	h := newTestHandler(t, Options{DataDir: dir})
+	body := `{"page": "/prometheus/query", "connection": "prom", "query": "up", "start": "now-1h", "options": {"tab": "graph"}, "expiresIn": "1d"}`
+	rec := serve(h, http.MethodPost, "/api/v1/links", strings.NewReader(body), nil)
+	var l struct {
+		ID, URL, Location string
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &l); err != nil || rec.Code != http.StatusCreated || l.URL != "/s/"+l.ID {
+		t.Fatalf("create: status %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = serve(h, http.MethodGet, l.URL, nil, nil)
+	loc, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil || rec.Code != http.StatusFound || loc.Path != "/ui/prometheus/query" {
+		t.Fatalf("redirect: status %d to %q", rec.Code, rec.Header().Get("Location"))
+	}
+	if q := loc.Query(); q.Get("connection") != "prom" || q.Get("query") != "up" || q.Get("start") != "now-1h" || q.Get("tab") != "graph" {
+		t.Errorf("redirect query %v", q)
+	}
+
+	// Links survive a restart.
+	h = newTestHandler(t, Options{DataDir: dir})
+	rec = serve(h, http.MethodGet, "/api/v1/links", nil, nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), l.ID) {
+		t.Errorf("list: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(h, http.MethodDelete, "/api/v1/links/"+l.ID, nil, nil); rec.Code != http.StatusNoContent {
+		t.Errorf("delete: status %d: %s", rec.Code, rec.Body)
+	}
+	for _, target := range []string{"/api/v1/links/" + l.ID, l.URL} {
+		if rec := serve(h, http.MethodGet, target, nil, nil); rec.Code != http.StatusNotFound {
+			t.Errorf("%s after delete: status %d", target, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package timeseriesui
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ── Loki ────────────────────────────────────────────────────────────────────
+//
+// Loki's query, label and series APIs are plain HTTP and go through the
+// generic proxy. Multi-tenant Loki selects the tenant with X-Scope-OrgID,
+// taken from the connection's tenantId.
+//
+// /loki/api/v1/tail is a WebSocket. A plain GET is dialled server-side, with
+// the same auth and tenant injection, and each message is relayed to the
+// browser as a Server-Sent Event so no upgrade has to pass through
+// intermediaries. WebSocket clients can also upgrade through the proxy.
+
+func init() { registerBackend(&lokiBackend{}) }
+
+type lokiBackend struct {
+	baseBackend
+	flags  connFlags
+	tenant string
+}
+
+func (*lokiBackend) Type() string        { return "loki" }
+func (*lokiBackend) DisplayName() string { return "Loki" }
+func (*lokiBackend) HealthPath() string  { return "/ready" }
+
+func (b *lokiBackend) RegisterFlags(fs *flag.FlagSet) {
+	b.flags.register(fs, "loki", "Loki")
+	b.flags.registerAuth(fs, "loki", "Loki", "basic-auth ")
+	fs.StringVar(&b.tenant, "loki-tenant", "", "Loki tenant sent as X-Scope-OrgID")
+}
+
+func (b *lokiBackend) FlagConnections() []CLIConnection {
+	return b.flags.build(b, func(_ int, c *CLIConnection) { c.TenantID = b.tenant })
+}
+
+// Classify treats pushes (native, legacy and OTLP) as writes, and log
+// deletion, flushes and ingester shutdown as admin. Paths are matched
+// cleaned.
+func (*lokiBackend) Classify(r *http.Request, t *proxyTarget) apiKind {
+	p := classifyPath(t.Path)
+	switch {
+	case p == "/loki/api/v1/push", p == "/api/prom/push", p == "/otlp/v1/logs":
+		return apiWrite
+	case strings.HasPrefix(p, "/loki/api/v1/delete"), p == "/flush",
+		strings.HasPrefix(p, "/ingester/"):
+		return apiAdmin
+	}
+	return apiRead
+}
+
+// lokiTailPath is Loki's live-tail WebSocket API.
+const lokiTailPath = "/loki/api/v1/tail"
+
+// Route sets X-Scope-OrgID from the CLI connection, or forwards the one
+// sent by the browser.
+func (*lokiBackend) Route(r *http.Request, t *proxyTarget) error {
+	tenant := r.Header.Get("X-Scope-OrgID")
+	if t.Conn != nil && t.Conn.TenantID != "" {
+		tenant = t.Conn.TenantID
+	}
+	if tenant != "" {
+		t.Header.Set("X-Scope-OrgID", tenant)
+	}
+	return nil
+}
+
+// Handler wraps the generic proxy with tail streaming.
+func (*lokiBackend) Handler(env *proxyEnv) http.HandlerFunc {
+	generic := makeGenericProxy(env)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Query().Get("path") != lokiTailPath || isUpgradeRequest(r) {
+			generic(w, r)
+			return
+		}
+		setCORS(w)
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			jsonError(w, http.StatusInternalServerError, "Streaming is not supported by this server")
+			return
+		}
+		proxyReq, client, ok := buildProxyRequest(w, r, env, nil)
+		if !ok {
+			return
+		}
+		// Accept/Content-Type describe the SSE side, not the upstream socket.
+		proxyReq.Header.Del("Accept")
+		proxyReq.Header.Del("Content-Type")
+
+		ws, err := dialWebSocket(r.Context(), client, proxyReq.URL.String(), proxyReq.Header)
+		if err != nil {
+			jsonError(w, http.StatusBadGateway, fmt.Sprintf("Connection failed: %s", err))
+			return
+		}
+		defer ws.Close()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		for {
+			msg, err := ws.ReadMessage()
+			if err != nil {
+				if err != io.EOF && r.Context().Err() == nil {
+					fmt.Fprintf(w, "event: error\ndata: %s\n\n", strings.ReplaceAll(err.Error(), "\n", " "))
+					flusher.Flush()
+				}
+				return
+			}
+			fmt.Fprintf(w, "data: %s\n\n", strings.ReplaceAll(string(msg), "\n", "\ndata: "))
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package timeseriesui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLokiClassify(t *testing.T) {
+	cases := []struct {
+		method, path string
+		want         apiKind
+	}{
+		{http.MethodPost, "/loki/api/v1/push", apiWrite},
+		{http.MethodPost, "//loki/api/v1/push", apiWrite},
+		{http.MethodPost, "/loki/api/v1/../v1/push", apiWrite},
+		{http.MethodPost, "/api/prom/push", apiWrite},
+		{http.MethodPost, "/otlp/v1/logs", apiWrite},
+		{http.MethodPost, "/loki/api/v1/delete", apiAdmin},
+		{http.MethodDelete, "/loki/api/v1//delete", apiAdmin},
+		{http.MethodPost, "/flush", apiAdmin},
+		{http.MethodPost, "/ingester/shutdown", apiAdmin},
+		{http.MethodPost, "/ingester/./shutdown", apiAdmin},
+		{http.MethodGet, "/loki/api/v1/query_range", apiRead},
+		{http.MethodGet, "/loki/api/v1/labels", apiRead},
+	}
+	b := backends["loki"]
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, "/", nil)
+		if got := b.Classify(r, &proxyTarget{Path: c.path}); got != c.want {
+			t.Errorf("%s %s: %v, want %v", c.method, c.path, got, c.want)
+		}
+	}
+}
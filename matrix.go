@@ -0,0 +1,46 @@
+package timeseriesui
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// ── Prometheus matrix shape ─────────────────────────────────────────────────
+//
+// The Prometheus query pages render range results as a "matrix". Backends
+// with their own series formats (Graphite, OpenTSDB) are normalized into it
+// so those pages can chart them unchanged.
+
+type promMatrixResponse struct {
+	Status string         `json:"status"`
+	Data   promMatrixData `json:"data"`
+}
+
+type promMatrixData struct {
+	ResultType string             `json:"resultType"`
+	Result     []promMatrixSeries `json:"result"`
+}
+
+type promMatrixSeries struct {
+	Metric map[string]string `json:"metric"`
+	Values [][2]interface{}  `json:"values"`
+}
+
+// promSample formats a sample the way the Prometheus API does: a float Unix
+// timestamp in seconds and the value as a string.
+func promSample(tsSeconds float64, v float64) [2]interface{} {
+	return [2]interface{}{tsSeconds, strconv.FormatFloat(v, 'f', -1, 64)}
+}
+
+// writeMatrix writes series as a successful Prometheus matrix response.
+func writeMatrix(w http.ResponseWriter, series []promMatrixSeries) {
+	if series == nil {
+		series = []promMatrixSeries{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(promMatrixResponse{
+		Status: "success",
+		Data:   promMatrixData{ResultType: "matrix", Result: series},
+	})
+}
@@ -0,0 +1,838 @@
+package timeseriesui
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ── InfluxDB migration ──────────────────────────────────────────────────────
+//
+// /api/v1/migrations copies measurements from an InfluxQL connection to
+// VictoriaMetrics or any Prometheus remote-write endpoint:
+//
+//	POST   /api/v1/migrations              start; streams progress as NDJSON
+//	GET    /api/v1/migrations              list
+//	GET    /api/v1/migrations/<id>         state, progress and checkpoint
+//	POST   /api/v1/migrations/<id>/resume  continue from the checkpoint
+//	DELETE /api/v1/migrations/<id>
+//
+// Each measurement is read window by window with SELECT * … GROUP BY *,
+// mapped to series by the rules and written through the destination's
+// proxy, so access policy, RBAC hooks and audit apply to both sides. The
+// checkpoint advances after every written window and is persisted to
+// migrations.json; a migration whose stream is closed can be resumed.
+// Migrations also run as background jobs of type "migration".
+
+// migrationSpec describes a migration.
+type migrationSpec struct {
+	Source      migrationSource `json:"source"`
+	Destination migrationDest   `json:"destination"`
+	Rules       migrationRules  `json:"rules"`
+	Window      string          `json:"window,omitempty"`    // time range read per query (default 1h)
+	BatchSize   int             `json:"batchSize,omitempty"` // samples per write (default 10000)
+	RateLimit   float64         `json:"rateLimit,omitempty"` // samples written per second; 0 for no limit
+	DryRun      bool            `json:"dryRun,omitempty"`
+}
+
+type migrationSource struct {
+	Connection   string   `json:"connection"` // an influxdb, influxdb2 or influxdb3 connection
+	Type         string   `json:"type,omitempty"`
+	Database     string   `json:"database,omitempty"`
+	Measurements []string `json:"measurements,omitempty"` // default: all of the database
+	Start        string   `json:"start"`
+	End          string   `json:"end,omitempty"`
+}
+
+type migrationDest struct {
+	Connection string `json:"connection"` // a victoriametrics or prometheus connection
+	Type       string `json:"type,omitempty"`
+	Protocol   string `json:"protocol,omitempty"` // "import" (VictoriaMetrics default) or "remote_write"
+	Path       string `json:"path,omitempty"`     // API path, e.g. /api/v1/push for Mimir
+}
+
+// migrationRules map InfluxDB points to Prometheus series.
+type migrationRules struct {
+	// MetricName is a template with {database}, {measurement} and {field}
+	// (default "{measurement}_{field}"). Invalid characters become "_".
+	MetricName string `json:"metricName,omitempty"`
+	// Measurements and Fields rename before the template is applied. Fields
+	// are matched as "measurement.field", then "field"; "" drops a field.
+	Measurements map[string]string `json:"measurements,omitempty"`
+	Fields       map[string]string `json:"fields,omitempty"`
+	// Tags renames tags to labels; "" drops a tag. With IncludeTags, only
+	// the listed tags become labels.
+	Tags        map[string]string `json:"tags,omitempty"`
+	IncludeTags []string          `json:"includeTags,omitempty"`
+	// Labels are added to every series.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+const (
+	defaultMigrationWindow = time.Hour
+	defaultMigrationBatch  = 10000
+	migrationExamples      = 10
+)
+
+// Migration states. Interrupted migrations lost their stream or the server
+// restarted; they resume from the checkpoint like failed ones.
+const (
+	migrationRunning     = "running"
+	migrationCompleted   = "completed"
+	migrationFailed      = "failed"
+	migrationInterrupted = "interrupted"
+)
+
+// migration is the persisted record of a run.
+type migration struct {
+	ID           string              `json:"id,omitempty"` // empty for dry runs, which are not stored
+	Spec         migrationSpec       `json:"spec"`
+	State        string              `json:"state"`
+	Error        string              `json:"error,omitempty"`
+	Start        time.Time           `json:"start"` // the resolved time range
+	End          time.Time           `json:"end"`
+	Measurements []string            `json:"measurements"`
+	Checkpoint   migrationCheckpoint `json:"checkpoint"`
+	Progress     migrationProgress   `json:"progress"`
+	Examples     []map[string]string `json:"examples,omitempty"` // dry runs: the first mapped series
+	CreatedBy    string              `json:"createdBy,omitempty"`
+	CreatedAt    time.Time           `json:"createdAt"`
+	UpdatedAt    time.Time           `json:"updatedAt"`
+}
+
+// migrationCheckpoint is where a resumed migration continues: the window
+// starting at Next of the measurement at index Measurement.
+type migrationCheckpoint struct {
+	Measurement int       `json:"measurement"`
+	Next        time.Time `json:"next"`
+}
+
+type migrationProgress struct {
+	Windows     int     `json:"windows"`
+	WindowsDone int     `json:"windowsDone"`
+	Percent     float64 `json:"percent"`
+	Samples     int64   `json:"samples"`          // written, or mapped in a dry run
+	Skipped     int64   `json:"skipped"`          // string values, which Prometheus cannot store
+	Series      int     `json:"series,omitempty"` // distinct series; dry runs only
+	Writes      int64   `json:"writes"`
+}
+
+// migrationEvent is one line of the progress stream.
+type migrationEvent struct {
+	Event       string             `json:"event"` // "start", "progress" or "end"
+	Measurement string             `json:"measurement,omitempty"`
+	WindowStart *time.Time         `json:"windowStart,omitempty"`
+	WindowEnd   *time.Time         `json:"windowEnd,omitempty"`
+	Samples     int                `json:"samples,omitempty"` // of this window
+	Progress    *migrationProgress `json:"progress,omitempty"`
+	Migration   *migration         `json:"migration,omitempty"`
+}
+
+// migrationStore holds the migration records in memory and, with a path,
+// on disk.
+type migrationStore struct {
+	mu         sync.Mutex
+	migrations map[string]*migration
+	running    map[string]bool
+	path       string
+}
+
+func newMigrationStore(dir string) (*migrationStore, error) {
+	s := &migrationStore{migrations: map[string]*migration{}, running: map[string]bool{}}
+	if dir == "" {
+		return s, nil
+	}
+	s.path = filepath.Join(dir, "migrations.json")
+	var list []*migration
+	if err := loadJSONFile(s.path, &list); err != nil {
+		return nil, err
+	}
+	for _, m := range list {
+		if m.State == migrationRunning {
+			m.State = migrationInterrupted
+		}
+		s.migrations[m.ID] = m
+	}
+	return s, nil
+}
+
+// save persists the records; the caller holds mu.
+func (s *migrationStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	return saveJSONFile(s.path, s.sorted())
+}
+
+// sorted returns the records newest first; the caller holds mu.
+func (s *migrationStore) sorted() []*migration {
+	list := make([]*migration, 0, len(s.migrations))
+	for _, m := range s.migrations {
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list
+}
+
+// update applies fn to the record of m under the lock and persists it.
+func (s *migrationStore) update(m *migration, fn func()) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn()
+	m.UpdatedAt = time.Now().UTC()
+	if m.ID == "" {
+		return nil
+	}
+	return s.save()
+}
+
+// handler serves the migration API. With user set, each user sees and
+// changes only the migrations they created, like jobs.
+func (s *migrationStore) handler(prefix string, qr *queryRunner, user func(*http.Request) string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setCORS(w)
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		rest := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+		id, action, _ := strings.Cut(rest, "/")
+		owns := func(m *migration) bool { return m.CreatedBy == "" || user == nil || user(r) == m.CreatedBy }
+
+		if id == "" {
+			switch r.Method {
+			case http.MethodGet:
+				s.mu.Lock()
+				list := []*migration{}
+				for _, m := range s.sorted() {
+					if owns(m) {
+						list = append(list, m)
+					}
+				}
+				data, _ := json.Marshal(list)
+				s.mu.Unlock()
+				w.Header().Set("Content-Type", "application/json")
+				w.Write(append(data, '\n'))
+			case http.MethodPost:
+				var spec migrationSpec
+				if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+					jsonError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+					return
+				}
+				m, err := s.create(r, qr, &spec, user)
+				if err != nil {
+					jsonError(w, httpErrorStatus(err, http.StatusBadGateway), err.Error())
+					return
+				}
+				s.run(w, r, qr, m)
+			default:
+				jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+			return
+		}
+
+		s.mu.Lock()
+		m, ok := s.migrations[id]
+		var snapshot []byte
+		if ok {
+			snapshot, _ = json.Marshal(m)
+		}
+		s.mu.Unlock()
+		if !ok {
+			jsonError(w, http.StatusNotFound, "Migration not found")
+			return
+		}
+		if !owns(m) {
+			jsonError(w, http.StatusForbidden, "This migration belongs to "+m.CreatedBy)
+			return
+		}
+		switch {
+		case action == "" && r.Method == http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(append(snapshot, '\n'))
+		case action == "" && r.Method == http.MethodDelete:
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if s.running[id] {
+				jsonError(w, http.StatusConflict, "Migration is running")
+				return
+			}
+			delete(s.migrations, id)
+			if err := s.save(); err != nil {
+				s.migrations[id] = m
+				jsonError(w, http.StatusInternalServerError, "Failed to save: "+err.Error())
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case action == "resume" && r.Method == http.MethodPost:
+			if err := s.begin(m); err != nil {
+				jsonError(w, httpErrorStatus(err, http.StatusConflict), err.Error())
+				return
+			}
+			s.run(w, r, qr, m)
+		case action == "" || action == "resume":
+			jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		default:
+			jsonError(w, http.StatusNotFound, "Not found")
+		}
+	}
+}
+
+// check validates spec and prepares the query listing the source's
+// measurements.
+func (spec *migrationSpec) check(qr *queryRunner) (*preparedQuery, error) {
+	if spec.Source.Start == "" {
+		return nil, &httpError{http.StatusBadRequest, "source.start is required"}
+	}
+	window, err := spec.window()
+	if err != nil {
+		return nil, err
+	}
+	if spec.BatchSize < 0 || spec.RateLimit < 0 {
+		return nil, &httpError{http.StatusBadRequest, "batchSize and rateLimit must not be negative"}
+	}
+	if _, _, _, err := qr.migrationDest(&spec.Destination); err != nil {
+		return nil, err
+	}
+	pq, err := qr.migrationQuery(&spec.Source, "SHOW MEASUREMENTS")
+	if err != nil {
+		return nil, err
+	}
+	if err := checkExportWindows(pq, window); err != nil {
+		return nil, err
+	}
+	return pq, nil
+}
+
+// create validates spec, resolves its time range and measurements and,
+// unless it is a dry run, stores a new running record.
+func (s *migrationStore) create(r *http.Request, qr *queryRunner, spec *migrationSpec, user func(*http.Request) string) (*migration, error) {
+	pq, err := spec.check(qr)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	m := &migration{Spec: *spec, State: migrationRunning, Start: pq.start, End: pq.end, CreatedAt: now, UpdatedAt: now}
+	m.Measurements = spec.Source.Measurements
+	if len(m.Measurements) == 0 {
+		res, err := qr.exec(r, pq, time.Time{}, pq.end, false)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range res.Frames {
+			for _, fd := range f.Fields {
+				if fd.Name != "name" {
+					continue
+				}
+				for _, v := range fd.Values {
+					if name, ok := v.(string); ok {
+						m.Measurements = append(m.Measurements, name)
+					}
+				}
+			}
+		}
+		if len(m.Measurements) == 0 {
+			return nil, &httpError{http.StatusBadRequest, "the source database has no measurements"}
+		}
+	}
+	m.Checkpoint.Next = m.Start
+	if user != nil {
+		m.CreatedBy = user(r)
+	}
+	if spec.DryRun {
+		return m, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m.ID = newID()
+	s.migrations[m.ID] = m
+	s.running[m.ID] = true
+	if err := s.save(); err != nil {
+		delete(s.migrations, m.ID)
+		delete(s.running, m.ID)
+		return nil, &httpError{http.StatusInternalServerError, "Failed to save: " + err.Error()}
+	}
+	return m, nil
+}
+
+// window returns the read window of spec.
+func (spec *migrationSpec) window() (time.Duration, error) {
+	if spec.Window == "" {
+		return defaultMigrationWindow, nil
+	}
+	d, err := parseLongDuration(spec.Window)
+	if err != nil || d <= 0 {
+		return 0, &httpError{http.StatusBadRequest, "Invalid window: " + spec.Window}
+	}
+	if d < minExportWindow {
+		return 0, &httpError{http.StatusBadRequest, "window must be at least " + minExportWindow.String()}
+	}
+	return d, nil
+}
+
+// migrationQuery prepares query against the source of a migration.
+func (qr *queryRunner) migrationQuery(src *migrationSource, query string) (*preparedQuery, error) {
+	pq, err := qr.prepare(&queryRequest{
+		Connection: src.Connection,
+		Type:       src.Type,
+		Language:   "influxql",
+		Query:      query,
+		Database:   src.Database,
+		Start:      src.Start,
+		End:        src.End,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if pq.start.IsZero() {
+		return nil, &httpError{http.StatusBadRequest, "source.start is required"}
+	}
+	return pq, nil
+}
+
+// migrationDest resolves the destination of a migration to its proxy type,
+// target URL and API path.
+func (qr *queryRunner) migrationDest(d *migrationDest) (typ, target, path string, err error) {
+	target, meta, err := qr.resolve(&queryRequest{Connection: d.Connection, Type: d.Type})
+	if err != nil {
+		return "", "", "", err
+	}
+	switch {
+	case meta.Type != "victoriametrics" && meta.Type != "prometheus":
+		return "", "", "", &httpError{http.StatusBadRequest, "destination must be a victoriametrics or prometheus connection"}
+	case d.Protocol == "":
+		d.Protocol = "remote_write"
+		if meta.Type == "victoriametrics" {
+			d.Protocol = "import"
+		}
+	case d.Protocol != "import" && d.Protocol != "remote_write":
+		return "", "", "", &httpError{http.StatusBadRequest, "destination.protocol must be import or remote_write"}
+	case d.Protocol == "import" && meta.Type != "victoriametrics":
+		return "", "", "", &httpError{http.StatusBadRequest, "the import protocol needs a victoriametrics destination"}
+	}
+	path = d.Path
+	if path == "" {
+		path = "/api/v1/write"
+		if d.Protocol == "import" {
+			path = "/api/v1/import"
+		}
+	}
+	return meta.Type, target, path, nil
+}
+
+// begin marks a stored migration as running again, unless it is running
+// or has completed.
+func (s *migrationStore) begin(m *migration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case s.running[m.ID]:
+		return &httpError{http.StatusConflict, "Migration is running"}
+	case m.State == migrationCompleted:
+		return &httpError{http.StatusConflict, "Migration has completed"}
+	}
+	s.running[m.ID] = true
+	m.State, m.Error = migrationRunning, ""
+	return nil
+}
+
+// run copies the remaining windows of m, streaming progress events to w.
+func (s *migrationStore) run(w http.ResponseWriter, r *http.Request, qr *queryRunner, m *migration) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	// Events are marshalled under s.mu, since they point at m, but written
+	// under wmu so a slow client never holds s.mu.
+	var wmu sync.Mutex
+	emit := func(ev migrationEvent) {
+		s.mu.Lock()
+		line, err := json.Marshal(ev)
+		s.mu.Unlock()
+		if err != nil {
+			return
+		}
+		wmu.Lock()
+		defer wmu.Unlock()
+		w.Write(append(line, '\n'))
+		rc.Flush()
+	}
+	s.execute(r, qr, m, emit)
+	emit(migrationEvent{Event: "end", Migration: m})
+}
+
+// execute copies the remaining windows of m, which begin or create marked
+// as running, and records the outcome. Events are passed to emit.
+func (s *migrationStore) execute(r *http.Request, qr *queryRunner, m *migration, emit func(migrationEvent)) error {
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, m.ID)
+		s.mu.Unlock()
+	}()
+	err := s.copy(r, qr, m, emit)
+	s.update(m, func() {
+		switch {
+		case err == nil:
+			m.State = migrationCompleted
+		case r.Context().Err() != nil:
+			m.State, m.Error = migrationInterrupted, "the migration was stopped"
+		default:
+			m.State, m.Error = migrationFailed, err.Error()
+		}
+	})
+	return err
+}
+
+// copy does the work of execute.
+func (s *migrationStore) copy(r *http.Request, qr *queryRunner, m *migration, emit func(migrationEvent)) error {
+	spec := &m.Spec
+	window, err := spec.window()
+	if err != nil {
+		return err
+	}
+	destType, destTarget, destPath, err := qr.migrationDest(&spec.Destination)
+	if err != nil {
+		return err
+	}
+	batchSize := spec.BatchSize
+	if batchSize == 0 {
+		batchSize = defaultMigrationBatch
+	}
+	src := spec.Source
+	src.Start, src.End = m.Start.Format(time.RFC3339Nano), m.End.Format(time.RFC3339Nano)
+
+	// Count the windows, for the progress percentage.
+	pq, err := qr.migrationQuery(&src, "$timeFilter")
+	if err != nil {
+		return err
+	}
+	resume := m.Checkpoint
+	perMeasurement := exportWindows(pq, window)
+	done := resume.Measurement * len(perMeasurement)
+	for _, win := range perMeasurement {
+		if win.start.Before(resume.Next) {
+			done++
+		}
+	}
+	s.update(m, func() {
+		m.Progress.Windows = len(m.Measurements) * len(perMeasurement)
+		m.Progress.WindowsDone = done
+		m.Progress.Percent = progressPercent(done, m.Progress.Windows)
+	})
+	emit(migrationEvent{Event: "start", Migration: m})
+
+	w := &migrationWriter{qr: qr, r: r, typ: destType, target: destTarget, path: destPath,
+		protocol: spec.Destination.Protocol, rate: spec.RateLimit, began: time.Now()}
+	seen := map[string]bool{}
+	for mi := resume.Measurement; mi < len(m.Measurements); mi++ {
+		name := m.Measurements[mi]
+		query := "SELECT * FROM " + quoteInfluxIdent(name) + " WHERE $timeFilter GROUP BY *"
+		if pq, err = qr.migrationQuery(&src, query); err != nil {
+			return err
+		}
+		for _, win := range exportWindows(pq, window) {
+			if mi == resume.Measurement && win.start.Before(resume.Next) {
+				continue
+			}
+			res, err := qr.exec(r, pq, win.start, win.end, win.exclusive)
+			if err != nil {
+				return fmt.Errorf("reading %s: %w", name, err)
+			}
+			series, skipped := spec.Rules.mapFrames(pq.database, res.Frames)
+			samples := 0
+			for _, ps := range series {
+				samples += len(ps.Values)
+			}
+			var examples []map[string]string
+			if spec.DryRun {
+				for _, ps := range series {
+					key := seriesKey(ps.Labels)
+					if !seen[key] && len(examples) < migrationExamples {
+						examples = append(examples, ps.Labels)
+					}
+					seen[key] = true
+				}
+			} else if err := w.write(series, batchSize); err != nil {
+				return fmt.Errorf("writing %s: %w", name, err)
+			}
+
+			next := win.end
+			if !win.exclusive {
+				next = win.end.Add(time.Millisecond)
+			}
+			var progress migrationProgress
+			if err := s.update(m, func() {
+				m.Checkpoint = migrationCheckpoint{Measurement: mi, Next: next}
+				m.Progress.WindowsDone++
+				m.Progress.Percent = progressPercent(m.Progress.WindowsDone, m.Progress.Windows)
+				m.Progress.Samples += int64(samples)
+				m.Progress.Skipped += int64(skipped)
+				m.Progress.Writes = w.writes
+				if spec.DryRun {
+					m.Progress.Series = len(seen)
+					if n := migrationExamples - len(m.Examples); n > 0 {
+						m.Examples = append(m.Examples, examples[:min(n, len(examples))]...)
+					}
+				}
+				progress = m.Progress
+			}); err != nil {
+				return fmt.Errorf("saving checkpoint: %w", err)
+			}
+			start, end := win.start, win.end
+			emit(migrationEvent{Event: "progress", Measurement: name, WindowStart: &start, WindowEnd: &end, Samples: samples, Progress: &progress})
+		}
+		if mi+1 < len(m.Measurements) {
+			s.update(m, func() { m.Checkpoint = migrationCheckpoint{Measurement: mi + 1, Next: m.Start} })
+		}
+	}
+	return nil
+}
+
+// progressPercent is done out of total as a percentage with one decimal.
+func progressPercent(done, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return float64(done*1000/total) / 10
+}
+
+// seriesKey identifies a label set.
+func seriesKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte(0)
+		b.WriteString(labels[k])
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+// ── Mapping ─────────────────────────────────────────────────────────────────
+
+// mapFrames converts InfluxDB frames — a measurement's tags and fields per
+// frame — to series, and counts the string values it skips.
+func (ru *migrationRules) mapFrames(db string, frames []queryFrame) ([]promSeries, int) {
+	var out []promSeries
+	skipped := 0
+	for _, f := range frames {
+		timeIdx := -1
+		for i, fd := range f.Fields {
+			if fd.Type == "time" {
+				timeIdx = i
+			}
+		}
+		if timeIdx < 0 {
+			continue
+		}
+		times := f.Fields[timeIdx].Values
+		for i, fd := range f.Fields {
+			if i == timeIdx {
+				continue
+			}
+			labels, ok := ru.labels(db, f.Name, fd.Name, f.Labels)
+			if !ok {
+				continue
+			}
+			ps := promSeries{Labels: labels}
+			for j, v := range fd.Values {
+				ts, ok := times[j].(int64)
+				if !ok {
+					continue
+				}
+				var val float64
+				switch v := v.(type) {
+				case nil:
+					continue
+				case json.Number:
+					f, err := v.Float64()
+					if err != nil {
+						skipped++
+						continue
+					}
+					val = f
+				case bool:
+					if v {
+						val = 1
+					}
+				default:
+					skipped++
+					continue
+				}
+				ps.Values = append(ps.Values, val)
+				ps.Timestamps = append(ps.Timestamps, ts)
+			}
+			if len(ps.Values) > 0 {
+				out = append(out, ps)
+			}
+		}
+	}
+	return out, skipped
+}
+
+// labels returns the label set, including __name__, of field in a series
+// of measurement with tags, or false when the field is dropped.
+func (ru *migrationRules) labels(db, measurement, field string, tags map[string]string) (map[string]string, bool) {
+	if r, ok := ru.Fields[measurement+"."+field]; ok {
+		field = r
+	} else if r, ok := ru.Fields[field]; ok {
+		field = r
+	}
+	if field == "" {
+		return nil, false
+	}
+	if r := ru.Measurements[measurement]; r != "" {
+		measurement = r
+	}
+	tmpl := ru.MetricName
+	if tmpl == "" {
+		tmpl = "{measurement}_{field}"
+	}
+	name := strings.NewReplacer("{database}", db, "{measurement}", measurement, "{field}", field).Replace(tmpl)
+
+	labels := map[string]string{}
+	for k, v := range tags {
+		if v == "" || (len(ru.IncludeTags) > 0 && !containsString(ru.IncludeTags, k)) {
+			continue
+		}
+		if r, ok := ru.Tags[k]; ok {
+			if r == "" {
+				continue
+			}
+			k = r
+		}
+		labels[sanitizePromName(k, false)] = v
+	}
+	for k, v := range ru.Labels {
+		labels[sanitizePromName(k, false)] = v
+	}
+	labels["__name__"] = sanitizePromName(name, true)
+	return labels, true
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizePromName replaces the characters Prometheus does not allow in
+// metric names (with colons) or label names with "_".
+func sanitizePromName(s string, metric bool) string {
+	b := []byte(s)
+	for i, c := range b {
+		ok := c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' ||
+			(i > 0 && c >= '0' && c <= '9') || (metric && c == ':')
+		if !ok {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 {
+		return "_"
+	}
+	return string(b)
+}
+
+// ── Writing ─────────────────────────────────────────────────────────────────
+
+// migrationWriter sends series to the destination in batches, paced to the
+// rate limit.
+type migrationWriter struct {
+	qr                *queryRunner
+	r                 *http.Request
+	typ, target, path string
+	protocol          string
+	rate              float64
+	began             time.Time
+	sent, writes      int64
+}
+
+// write sends series in requests of about batchSize samples.
+func (mw *migrationWriter) write(series []promSeries, batchSize int) error {
+	var batch []promSeries
+	n := 0
+	for _, ps := range series {
+		for len(ps.Values) > 0 {
+			k := batchSize - n
+			if k > len(ps.Values) {
+				k = len(ps.Values)
+			}
+			batch = append(batch, promSeries{Labels: ps.Labels, Values: ps.Values[:k], Timestamps: ps.Timestamps[:k]})
+			ps.Values, ps.Timestamps = ps.Values[k:], ps.Timestamps[k:]
+			if n += k; n >= batchSize {
+				if err := mw.send(batch, n); err != nil {
+					return err
+				}
+				batch, n = nil, 0
+			}
+		}
+	}
+	if n > 0 {
+		return mw.send(batch, n)
+	}
+	return nil
+}
+
+// send writes one batch of n samples.
+func (mw *migrationWriter) send(batch []promSeries, n int) error {
+	if mw.rate > 0 {
+		due := mw.began.Add(time.Duration(float64(mw.sent) / mw.rate * float64(time.Second)))
+		if d := time.Until(due); d > 0 {
+			if err := sleepContext(mw.r.Context(), d); err != nil {
+				return err
+			}
+		}
+	}
+	header := http.Header{}
+	var body []byte
+	if mw.protocol == "import" {
+		header.Set("Content-Type", "application/json")
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		for _, ps := range batch {
+			enc.Encode(map[string]interface{}{"metric": ps.Labels, "values": ps.Values, "timestamps": ps.Timestamps})
+		}
+		body = buf.Bytes()
+	} else {
+		header.Set("Content-Type", remoteWriteContentType)
+		header.Set("Content-Encoding", "snappy")
+		header.Set("X-Prometheus-Remote-Write-Version", remoteWriteVersion)
+		body = encodeWriteRequest(batch)
+	}
+	params := url.Values{"target": {mw.target}, "path": {mw.path}}
+	if _, err := mw.qr.send(mw.r, http.MethodPost, mw.typ, params, header, body); err != nil {
+		return err
+	}
+	mw.sent += int64(n)
+	mw.writes++
+	return nil
+}
+
+// sleepContext waits for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,66 @@
+package timeseriesui
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMigrationWindowLimits(t *testing.T) {
+	h := newTestHandler(t, Options{
+		DataDir: t.TempDir(),
+		Connections: []CLIConnection{
+			{Name: "influx", Type: "influxdb", URL: "http://127.0.0.1:1"},
+			{Name: "vm", Type: "victoriametrics", URL: "http://127.0.0.1:2"},
+		},
+	})
+	cases := []struct{ start, window, message string }{
+		{"now-1h", "0s", "window"},
+		{"now-1h", "100ms", "window must be"},
+		{"now-30d", "1s", "windows"},
+	}
+	for _, c := range cases {
+		body := `{"source": {"connection": "influx", "database": "db", "start": "` + c.start + `"},
+			"destination": {"connection": "vm"}, "window": "` + c.window + `"}`
+		rec := serve(h, http.MethodPost, "/api/v1/migrations", strings.NewReader(body), map[string]string{"Content-Type": "application/json"})
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), c.message) {
+			t.Errorf("%+v: status %d: %s", c, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestMigrationOwnership(t *testing.T) {
+	s, err := newMigrationStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for i, owner := range []string{"alice", "bob", ""} {
+		id := "m" + string(rune('1'+i))
+		s.migrations[id] = &migration{ID: id, State: "completed", CreatedBy: owner, CreatedAt: now.Add(time.Duration(i) * time.Second)}
+	}
+	h := s.handler("/api/v1/migrations", nil, func(r *http.Request) string { return r.Header.Get("X-User") })
+	as := func(user string) map[string]string { return map[string]string{"X-User": user} }
+
+	rec := serve(h, http.MethodGet, "/api/v1/migrations", nil, as("alice"))
+	var list []migration
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list) != 2 || list[0].ID != "m3" || list[1].ID != "m1" {
+		t.Errorf("list for alice: status %d: %s", rec.Code, rec.Body)
+	}
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		if rec := serve(h, method, "/api/v1/migrations/m2", nil, as("alice")); rec.Code != http.StatusForbidden {
+			t.Errorf("%s of another user's migration: status %d", method, rec.Code)
+		}
+	}
+	if rec := serve(h, http.MethodPost, "/api/v1/migrations/m2/resume", nil, as("alice")); rec.Code != http.StatusForbidden {
+		t.Errorf("resuming another user's migration: status %d", rec.Code)
+	}
+	if rec := serve(h, http.MethodDelete, "/api/v1/migrations/m2", nil, as("bob")); rec.Code != http.StatusNoContent || s.migrations["m2"] != nil {
+		t.Errorf("deleting an own migration: status %d", rec.Code)
+	}
+	if rec := serve(h, http.MethodGet, "/api/v1/migrations/m3", nil, as("bob")); rec.Code != http.StatusOK {
+		t.Errorf("reading a migration without owner: status %d", rec.Code)
+	}
+}
@@ -0,0 +1,172 @@
+package timeseriesui
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ── OpenTSDB ────────────────────────────────────────────────────────────────
+//
+// opentsdb connections proxy the OpenTSDB 2.x HTTP API: /api/query for data,
+// /api/suggest and /api/search/lookup for discovery and /api/put for writes.
+// VictoriaMetrics accepts /api/put on its OpenTSDB HTTP listener, so a
+// write-only opentsdb connection can point there.
+//
+// With shape=matrix, /api/query results are converted into the Prometheus
+// matrix shape.
+
+func init() { registerBackend(&openTSDBBackend{}) }
+
+type openTSDBBackend struct {
+	baseBackend
+	flags connFlags
+}
+
+func (*openTSDBBackend) Type() string        { return "opentsdb" }
+func (*openTSDBBackend) DisplayName() string { return "OpenTSDB" }
+func (*openTSDBBackend) HealthPath() string  { return "/api/version" }
+
+func (b *openTSDBBackend) RegisterFlags(fs *flag.FlagSet) {
+	b.flags.register(fs, "opentsdb", "OpenTSDB")
+	b.flags.registerAuth(fs, "opentsdb", "OpenTSDB", "basic-auth ")
+}
+
+func (b *openTSDBBackend) FlagConnections() []CLIConnection {
+	return b.flags.build(b, nil)
+}
+
+// openTSDBSeries is one element of an /api/query response. dps is either an
+// object keyed by timestamp or, with arrays=true, a list of [ts, value].
+type openTSDBSeries struct {
+	Metric        string            `json:"metric"`
+	Tags          map[string]string `json:"tags"`
+	AggregateTags []string          `json:"aggregateTags"`
+	DPS           json.RawMessage   `json:"dps"`
+}
+
+// Classify reports which OpenTSDB APIs write data or administer the server;
+// everything else is a read.
+func (*openTSDBBackend) Classify(r *http.Request, t *proxyTarget) apiKind {
+	p := classifyPath(t.Path)
+	switch {
+	case p == "/api/put", p == "/api/rollup", p == "/api/histogram":
+		return apiWrite
+	case strings.HasPrefix(p, "/api/query") && (r.Method == http.MethodDelete || strings.EqualFold(t.Params.Get("delete"), "true")),
+		strings.HasPrefix(p, "/api/query") && r.Method != http.MethodGet && openTSDBBodyDeletes(r),
+		strings.HasPrefix(p, "/api/uid/"),
+		p == "/api/dropcaches",
+		strings.HasPrefix(p, "/api/annotation") && r.Method != http.MethodGet,
+		strings.HasPrefix(p, "/api/tree") && r.Method != http.MethodGet:
+		return apiAdmin
+	}
+	return apiRead
+}
+
+// openTSDBBodyDeletes reports whether a query body asks to delete the data
+// it matches ("delete": true). A body that cannot be read as JSON counts as
+// a delete.
+func openTSDBBodyDeletes(r *http.Request) bool {
+	if enc := r.Header.Get("Content-Encoding"); enc != "" && enc != "identity" {
+		return true
+	}
+	raw, complete := peekBody(r)
+	if !complete {
+		return true
+	}
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return false
+	}
+	var q struct {
+		Delete bool `json:"delete"`
+	}
+	return json.Unmarshal(raw, &q) != nil || q.Delete
+}
+
+// Route strips the proxy-only shape parameter.
+func (*openTSDBBackend) Route(r *http.Request, t *proxyTarget) error {
+	t.Params.Del("shape")
+	return nil
+}
+
+// Handler wraps the generic proxy with query reshaping.
+func (*openTSDBBackend) Handler(env *proxyEnv) http.HandlerFunc {
+	generic := makeGenericProxy(env)
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("path") != "/api/query" || q.Get("shape") != "matrix" {
+			generic(w, r)
+			return
+		}
+		setCORS(w)
+		convertProxy(w, r, env, nil, nil, convertOpenTSDBQuery)
+	}
+}
+
+// convertOpenTSDBQuery converts /api/query output into a Prometheus matrix.
+// Millisecond timestamps (msResolution=true) are detected by magnitude.
+func convertOpenTSDBQuery(w http.ResponseWriter, body io.Reader) error {
+	var in []openTSDBSeries
+	if err := json.NewDecoder(body).Decode(&in); err != nil {
+		return err
+	}
+	out := make([]promMatrixSeries, 0, len(in))
+	for _, s := range in {
+		points, err := openTSDBPoints(s.DPS)
+		if err != nil {
+			return fmt.Errorf("metric %s: %w", s.Metric, err)
+		}
+		metric := map[string]string{"__name__": s.Metric}
+		for k, v := range s.Tags {
+			metric[k] = v
+		}
+		ps := promMatrixSeries{Metric: metric, Values: make([][2]interface{}, 0, len(points))}
+		for _, p := range points {
+			ps.Values = append(ps.Values, promSample(p.ts, p.v))
+		}
+		out = append(out, ps)
+	}
+	writeMatrix(w, out)
+	return nil
+}
+
+type openTSDBPoint struct {
+	ts float64 // seconds
+	v  float64
+}
+
+// openTSDBPoints decodes dps in either of its two forms, sorted by time.
+func openTSDBPoints(raw json.RawMessage) ([]openTSDBPoint, error) {
+	var points []openTSDBPoint
+	var asMap map[string]float64
+	if err := json.Unmarshal(raw, &asMap); err == nil {
+		for k, v := range asMap {
+			ts, err := strconv.ParseFloat(k, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timestamp %q", k)
+			}
+			points = append(points, openTSDBPoint{ts, v})
+		}
+	} else {
+		var asArray [][2]float64
+		if err := json.Unmarshal(raw, &asArray); err != nil {
+			return nil, fmt.Errorf("unrecognized dps format")
+		}
+		for _, p := range asArray {
+			points = append(points, openTSDBPoint{p[0], p[1]})
+		}
+	}
+	for i := range points {
+		if points[i].ts > 1e11 {
+			points[i].ts /= 1000
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].ts < points[j].ts })
+	return points, nil
+}
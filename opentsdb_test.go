@@ -0,0 +1,57 @@
+package timeseriesui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestOpenTSDBClassify(t *testing.T) {
+	cases := []struct {
+		method, path, params, body string
+		want                       apiKind
+	}{
+		{http.MethodPost, "/api/put", "", "", apiWrite},
+		{http.MethodPost, "//api/put", "", "", apiWrite},
+		{http.MethodPost, "/api/histogram", "", "", apiWrite},
+		{http.MethodGet, "/api/query", "m=sum:cpu", "", apiRead},
+		{http.MethodGet, "/api/query", "m=sum:cpu&delete=true", "", apiAdmin},
+		{http.MethodDelete, "/api/query", "", "", apiAdmin},
+		{http.MethodPost, "/api/query", "", `{"queries": []}`, apiRead},
+		{http.MethodPost, "/api/query", "", `{"queries": [], "delete": true}`, apiAdmin},
+		{http.MethodPost, "/api/query", "", `not json`, apiAdmin},
+		{http.MethodPost, "/api/uid/assign", "", "", apiAdmin},
+		{http.MethodGet, "/api/uid/uidmeta", "", "", apiAdmin},
+		{http.MethodPost, "/api/dropcaches", "", "", apiAdmin},
+		{http.MethodGet, "/api/annotation", "", "", apiRead},
+		{http.MethodPost, "/api/annotation", "", "", apiAdmin},
+		{http.MethodGet, "/api/suggest", "type=metrics", "", apiRead},
+	}
+	b := backends["opentsdb"]
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, "/", strings.NewReader(c.body))
+		params, _ := url.ParseQuery(c.params)
+		if got := b.Classify(r, &proxyTarget{Path: c.path, Params: params}); got != c.want {
+			t.Errorf("%s %s?%s %s: %v, want %v", c.method, c.path, c.params, c.body, got, c.want)
+		}
+	}
+}
+
+func TestOpenTSDBMatrix(t *testing.T) {
+	rec := httptest.NewRecorder()
+	err := convertOpenTSDBQuery(rec, strings.NewReader(`[
+		{"metric": "cpu", "tags": {"host": "a"}, "dps": {"1700000060": 2, "1700000000": 1.5}},
+		{"metric": "mem", "tags": {}, "dps": [[1700000000, 3]]}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"status":"success","data":{"resultType":"matrix","result":[` +
+		`{"metric":{"__name__":"cpu","host":"a"},"values":[[1700000000,"1.5"],[1700000060,"2"]]},` +
+		`{"metric":{"__name__":"mem"},"values":[[1700000000,"3"]]}]}}`
+	if got := strings.TrimSpace(rec.Body.String()); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
@@ -0,0 +1,315 @@
+package timeseriesui
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// ── Parquet writer ──────────────────────────────────────────────────────────
+//
+// A minimal streaming Parquet writer for flat schemas: uncompressed, PLAIN
+// encoded, one data page per column chunk. Rows are buffered into row groups
+// that are written out as they fill, so memory stays bounded whatever the
+// file size. File metadata is Thrift compact protocol, as the format
+// requires.
+
+// Parquet physical types, converted types and enums used here.
+const (
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetUTF8            = 0
+	parquetTimestampMillis = 9
+
+	parquetRequired = 0
+	parquetOptional = 1
+
+	parquetEncodingPlain = 0
+	parquetEncodingRLE   = 3
+
+	parquetRowGroupRows = 64 << 10
+)
+
+// parquetColumn describes a top-level column. converted is -1 for none.
+type parquetColumn struct {
+	name      string
+	typ       int32
+	converted int32
+	optional  bool
+}
+
+// parquetWriter writes rows of int64, float64 and string values (nil for
+// null in optional columns) to w.
+type parquetWriter struct {
+	w         io.Writer
+	cols      []parquetColumn
+	offset    int64
+	values    []bytes.Buffer // PLAIN-encoded non-null values per column
+	defined   [][]bool       // definition levels per optional column
+	rows      int
+	totalRows int64
+	groups    []parquetRowGroup
+	err       error
+}
+
+type parquetRowGroup struct {
+	rows   int
+	chunks []parquetChunk
+}
+
+type parquetChunk struct {
+	offset int64
+	size   int64
+	values int
+}
+
+func newParquetWriter(w io.Writer, cols []parquetColumn) (*parquetWriter, error) {
+	pw := &parquetWriter{w: w, cols: cols, values: make([]bytes.Buffer, len(cols)), defined: make([][]bool, len(cols))}
+	pw.write([]byte("PAR1"))
+	return pw, pw.err
+}
+
+func (pw *parquetWriter) write(p []byte) {
+	if pw.err != nil {
+		return
+	}
+	n, err := pw.w.Write(p)
+	pw.offset += int64(n)
+	pw.err = err
+}
+
+// add appends one row; values must match the column types.
+func (pw *parquetWriter) add(row []interface{}) error {
+	for i, v := range row {
+		c := &pw.cols[i]
+		if c.optional {
+			pw.defined[i] = append(pw.defined[i], v != nil)
+		}
+		buf := &pw.values[i]
+		switch v := v.(type) {
+		case int64:
+			binary.Write(buf, binary.LittleEndian, v)
+		case float64:
+			binary.Write(buf, binary.LittleEndian, math.Float64bits(v))
+		case string:
+			binary.Write(buf, binary.LittleEndian, uint32(len(v)))
+			buf.WriteString(v)
+		}
+	}
+	pw.rows++
+	if pw.rows >= parquetRowGroupRows {
+		pw.flushRowGroup()
+	}
+	return pw.err
+}
+
+// flushRowGroup writes the buffered rows as a row group.
+func (pw *parquetWriter) flushRowGroup() {
+	if pw.rows == 0 {
+		return
+	}
+	g := parquetRowGroup{rows: pw.rows}
+	for i, c := range pw.cols {
+		var page bytes.Buffer
+		if c.optional {
+			levels := encodeDefinitionLevels(pw.defined[i])
+			binary.Write(&page, binary.LittleEndian, uint32(len(levels)))
+			page.Write(levels)
+		}
+		page.Write(pw.values[i].Bytes())
+
+		var t thriftWriter
+		t.i32(1, 0) // DATA_PAGE
+		t.i32(2, int32(page.Len()))
+		t.i32(3, int32(page.Len()))
+		t.beginStruct(5)
+		t.i32(1, int32(pw.rows))
+		t.i32(2, parquetEncodingPlain)
+		t.i32(3, parquetEncodingRLE)
+		t.i32(4, parquetEncodingRLE)
+		t.endStruct()
+		t.stop()
+
+		offset := pw.offset
+		pw.write(t.buf.Bytes())
+		pw.write(page.Bytes())
+		g.chunks = append(g.chunks, parquetChunk{offset: offset, size: pw.offset - offset, values: pw.rows})
+		pw.values[i].Reset()
+		pw.defined[i] = pw.defined[i][:0]
+	}
+	pw.groups = append(pw.groups, g)
+	pw.totalRows += int64(pw.rows)
+	pw.rows = 0
+}
+
+// encodeDefinitionLevels encodes 0/1 levels in the RLE hybrid encoding
+// with bit width 1, as runs.
+func encodeDefinitionLevels(defined []bool) []byte {
+	var out []byte
+	for i := 0; i < len(defined); {
+		j := i
+		for j < len(defined) && defined[j] == defined[i] {
+			j++
+		}
+		out = binary.AppendUvarint(out, uint64(j-i)<<1)
+		if defined[i] {
+			out = append(out, 1)
+		} else {
+			out = append(out, 0)
+		}
+		i = j
+	}
+	return out
+}
+
+// close writes the remaining rows and the footer.
+func (pw *parquetWriter) close() error {
+	pw.flushRowGroup()
+
+	var t thriftWriter
+	t.i32(1, 1) // version
+	t.beginList(2, thriftStruct, len(pw.cols)+1)
+	t.beginElem()
+	t.binary(4, "schema")
+	t.i32(5, int32(len(pw.cols)))
+	t.endStruct()
+	for _, c := range pw.cols {
+		t.beginElem()
+		t.i32(1, c.typ)
+		rep := int32(parquetRequired)
+		if c.optional {
+			rep = parquetOptional
+		}
+		t.i32(3, rep)
+		t.binary(4, c.name)
+		if c.converted >= 0 {
+			t.i32(6, c.converted)
+		}
+		t.endStruct()
+	}
+	t.i64(3, pw.totalRows)
+	t.beginList(4, thriftStruct, len(pw.groups))
+	for _, g := range pw.groups {
+		t.beginElem()
+		t.beginList(1, thriftStruct, len(g.chunks))
+		var total int64
+		for i, ch := range g.chunks {
+			c := pw.cols[i]
+			t.beginElem()
+			t.i64(2, ch.offset)
+			t.beginStruct(3)
+			t.i32(1, c.typ)
+			t.beginList(2, thriftI32, 2)
+			t.listI32(parquetEncodingPlain)
+			t.listI32(parquetEncodingRLE)
+			t.beginList(3, thriftBinary, 1)
+			t.listBinary(c.name)
+			t.i32(4, 0) // UNCOMPRESSED
+			t.i64(5, int64(ch.values))
+			t.i64(6, ch.size)
+			t.i64(7, ch.size)
+			t.i64(9, ch.offset)
+			t.endStruct()
+			t.endStruct()
+			total += ch.size
+		}
+		t.i64(2, total)
+		t.i64(3, int64(g.rows))
+		t.endStruct()
+	}
+	t.binary(6, "timeseriesui")
+	t.stop()
+
+	pw.write(t.buf.Bytes())
+	var tail [4]byte
+	binary.LittleEndian.PutUint32(tail[:], uint32(t.buf.Len()))
+	pw.write(tail[:])
+	pw.write([]byte("PAR1"))
+	return pw.err
+}
+
+// ── Thrift compact protocol ─────────────────────────────────────────────────
+
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes structs field by field. Field IDs are delta-encoded
+// against the previous field of the same struct.
+type thriftWriter struct {
+	buf   bytes.Buffer
+	last  int16
+	stack []int16
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	if d := id - t.last; d > 0 && d <= 15 {
+		t.buf.WriteByte(byte(d)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(int64(id))
+	}
+	t.last = id
+}
+
+func (t *thriftWriter) varint(v int64) {
+	t.buf.Write(binary.AppendUvarint(nil, uint64((v<<1)^(v>>63))))
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(v)
+}
+
+func (t *thriftWriter) binary(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.listBinary(s)
+}
+
+func (t *thriftWriter) beginStruct(id int16) {
+	t.field(id, thriftStruct)
+	t.beginElem()
+}
+
+// beginElem starts a struct that is a list element.
+func (t *thriftWriter) beginElem() {
+	t.stack = append(t.stack, t.last)
+	t.last = 0
+}
+
+func (t *thriftWriter) endStruct() {
+	t.stop()
+	t.last = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+func (t *thriftWriter) stop() { t.buf.WriteByte(0) }
+
+func (t *thriftWriter) beginList(id int16, elem byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf.WriteByte(byte(n)<<4 | elem)
+	} else {
+		t.buf.WriteByte(0xf0 | elem)
+		t.buf.Write(binary.AppendUvarint(nil, uint64(n)))
+	}
+}
+
+func (t *thriftWriter) listI32(v int32) { t.varint(int64(v)) }
+
+func (t *thriftWriter) listBinary(s string) {
+	t.buf.Write(binary.AppendUvarint(nil, uint64(len(s))))
+	t.buf.WriteString(s)
+}
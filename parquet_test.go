@@ -0,0 +1,262 @@
+package timeseriesui
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// thriftReader decodes Thrift compact protocol structs into maps of field
+// ID to value: int64, string, bool, []interface{} or map[int16]interface{}.
+type thriftReader struct {
+	t *testing.T
+	b []byte
+}
+
+func (r *thriftReader) byte() byte {
+	if len(r.b) == 0 {
+		r.t.Fatal("thrift: truncated")
+	}
+	c := r.b[0]
+	r.b = r.b[1:]
+	return c
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.t.Fatal("thrift: bad varint")
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *thriftReader) zigzag() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case 1, 2:
+		return typ == 1
+	case 3:
+		return int64(int8(r.byte()))
+	case 4, 5, 6:
+		return r.zigzag()
+	case 8:
+		n := r.uvarint()
+		if uint64(len(r.b)) < n {
+			r.t.Fatal("thrift: truncated binary")
+		}
+		s := string(r.b[:n])
+		r.b = r.b[n:]
+		return s
+	case 9:
+		h := r.byte()
+		n := uint64(h >> 4)
+		if n == 15 {
+			n = r.uvarint()
+		}
+		list := make([]interface{}, n)
+		for i := range list {
+			list[i] = r.value(h & 15)
+		}
+		return list
+	case 12:
+		return r.readStruct()
+	}
+	r.t.Fatalf("thrift: unsupported type %d", typ)
+	return nil
+}
+
+func (r *thriftReader) readStruct() map[int16]interface{} {
+	s := map[int16]interface{}{}
+	var last int16
+	for {
+		h := r.byte()
+		if h == 0 {
+			return s
+		}
+		id := last + int16(h>>4)
+		if h>>4 == 0 {
+			id = int16(r.zigzag())
+		}
+		s[id] = r.value(h & 15)
+		last = id
+	}
+}
+
+// parquetFooter checks the magic numbers of file and decodes its metadata.
+func parquetFooter(t *testing.T, file []byte) map[int16]interface{} {
+	t.Helper()
+	if len(file) < 12 || string(file[:4]) != "PAR1" || string(file[len(file)-4:]) != "PAR1" {
+		t.Fatal("missing PAR1 magic")
+	}
+	n := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	if n > len(file)-12 {
+		t.Fatalf("footer length %d exceeds the file", n)
+	}
+	r := &thriftReader{t: t, b: file[len(file)-8-n : len(file)-8]}
+	meta := r.readStruct()
+	if len(r.b) != 0 {
+		t.Fatalf("%d bytes after the footer struct", len(r.b))
+	}
+	return meta
+}
+
+func TestParquetWriter(t *testing.T) {
+	var buf bytes.Buffer
+	pw, err := newParquetWriter(&buf, []parquetColumn{
+		{name: "time", typ: parquetInt64, converted: parquetTimestampMillis},
+		{name: "value", typ: parquetDouble, converted: -1, optional: true},
+		{name: "series", typ: parquetByteArray, converted: parquetUTF8},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := [][]interface{}{
+		{int64(1700000000000), 1.5, "cpu"},
+		{int64(1700000015000), nil, "cpu"},
+		{int64(1700000030000), -2.0, "mem"},
+	}
+	for _, row := range rows {
+		if err := pw.add(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pw.close(); err != nil {
+		t.Fatal(err)
+	}
+	file := buf.Bytes()
+	meta := parquetFooter(t, file)
+
+	if meta[1] != int64(1) || meta[3] != int64(3) {
+		t.Errorf("version %v, num_rows %v", meta[1], meta[3])
+	}
+	schema := meta[2].([]interface{})
+	if len(schema) != 4 || schema[0].(map[int16]interface{})[5] != int64(3) {
+		t.Fatalf("schema %v", schema)
+	}
+	want := []struct {
+		name                string
+		typ, rep, converted int64
+	}{
+		{"time", parquetInt64, parquetRequired, parquetTimestampMillis},
+		{"value", parquetDouble, parquetOptional, -1},
+		{"series", parquetByteArray, parquetRequired, parquetUTF8},
+	}
+	for i, w := range want {
+		el := schema[i+1].(map[int16]interface{})
+		conv, ok := el[6]
+		if !ok {
+			conv = int64(-1)
+		}
+		if el[4] != w.name || el[1] != w.typ || el[3] != w.rep || conv != w.converted {
+			t.Errorf("schema element %d = %v, want %+v", i+1, el, w)
+		}
+	}
+
+	groups := meta[4].([]interface{})
+	if len(groups) != 1 {
+		t.Fatalf("%d row groups", len(groups))
+	}
+	g := groups[0].(map[int16]interface{})
+	chunks := g[1].([]interface{})
+	if g[3] != int64(3) || len(chunks) != 3 {
+		t.Fatalf("row group %v", g)
+	}
+	var total int64
+	pages := make([][]byte, len(chunks))
+	for i, c := range chunks {
+		cm := c.(map[int16]interface{})[3].(map[int16]interface{})
+		off, size := cm[9].(int64), cm[7].(int64)
+		if c.(map[int16]interface{})[2] != off || cm[6] != size || cm[5] != int64(3) {
+			t.Errorf("column %d metadata %v", i, cm)
+		}
+		if path := cm[3].([]interface{}); len(path) != 1 || path[0] != want[i].name {
+			t.Errorf("column %d path %v", i, path)
+		}
+		total += size
+
+		r := &thriftReader{t: t, b: file[off : off+size]}
+		ph := r.readStruct()
+		dp := ph[5].(map[int16]interface{})
+		if ph[1] != int64(0) || ph[2] != int64(len(r.b)) || ph[3] != int64(len(r.b)) || dp[1] != int64(3) {
+			t.Errorf("column %d page header %v with %d bytes of page", i, ph, len(r.b))
+		}
+		pages[i] = r.b
+	}
+	if g[2] != total {
+		t.Errorf("total_byte_size %v, chunks add up to %d", g[2], total)
+	}
+
+	var times []int64
+	for p := pages[0]; len(p) > 0; p = p[8:] {
+		times = append(times, int64(binary.LittleEndian.Uint64(p)))
+	}
+	if !reflect.DeepEqual(times, []int64{1700000000000, 1700000015000, 1700000030000}) {
+		t.Errorf("time values %v", times)
+	}
+
+	p := pages[1]
+	n := binary.LittleEndian.Uint32(p)
+	var defined []bool
+	for levels := p[4 : 4+n]; len(levels) > 0; {
+		run, k := binary.Uvarint(levels)
+		if run&1 != 0 {
+			t.Fatal("bit-packed definition levels")
+		}
+		for j := uint64(0); j < run>>1; j++ {
+			defined = append(defined, levels[k] == 1)
+		}
+		levels = levels[k+1:]
+	}
+	if !reflect.DeepEqual(defined, []bool{true, false, true}) {
+		t.Errorf("definition levels %v", defined)
+	}
+	var values []float64
+	for p = p[4+n:]; len(p) > 0; p = p[8:] {
+		values = append(values, math.Float64frombits(binary.LittleEndian.Uint64(p)))
+	}
+	if !reflect.DeepEqual(values, []float64{1.5, -2}) {
+		t.Errorf("value values %v", values)
+	}
+
+	var series []string
+	for p = pages[2]; len(p) > 0; {
+		l := binary.LittleEndian.Uint32(p)
+		series = append(series, string(p[4:4+l]))
+		p = p[4+l:]
+	}
+	if !reflect.DeepEqual(series, []string{"cpu", "cpu", "mem"}) {
+		t.Errorf("series values %v", series)
+	}
+}
+
+func TestParquetRowGroups(t *testing.T) {
+	var buf bytes.Buffer
+	pw, err := newParquetWriter(&buf, []parquetColumn{{name: "v", typ: parquetInt64, converted: -1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := parquetRowGroupRows + 10
+	for i := 0; i < rows; i++ {
+		pw.add([]interface{}{int64(i)})
+	}
+	if err := pw.close(); err != nil {
+		t.Fatal(err)
+	}
+	meta := parquetFooter(t, buf.Bytes())
+	groups := meta[4].([]interface{})
+	if meta[3] != int64(rows) || len(groups) != 2 {
+		t.Fatalf("num_rows %v in %d row groups", meta[3], len(groups))
+	}
+	for i, want := range []int64{parquetRowGroupRows, 10} {
+		if n := groups[i].(map[int16]interface{})[3]; n != want {
+			t.Errorf("row group %d has %v rows, want %d", i, n, want)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package timeseriesui
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"path"
+	"time"
+)
+
+// ── Write / admin policy ────────────────────────────────────────────────────
+//
+// --disable-write, --disable-admin and --readonly hide features in the UI via
+// /api/mode. Every backend classifies its API calls (Backend.Classify) and the
+// proxy enforces the policy, so the flags hold even for clients that bypass
+// the UI. Embedders add their own checks and audit trail through
+// Options.Authorize and Options.Audit.
+
+// apiKind classifies a proxied API call.
+type apiKind int
+
+const (
+	apiRead apiKind = iota
+	apiWrite
+	apiAdmin
+)
+
+func (k apiKind) String() string {
+	switch k {
+	case apiWrite:
+		return "write"
+	case apiAdmin:
+		return "admin"
+	}
+	return "read"
+}
+
+// accessPolicy is the server-wide write/admin policy.
+type accessPolicy struct {
+	DisableWrite bool
+	DisableAdmin bool
+}
+
+// check returns a 403 *httpError when kind is disabled.
+func (p accessPolicy) check(kind apiKind) error {
+	switch {
+	case kind == apiWrite && p.DisableWrite:
+		return &httpError{http.StatusForbidden, "Writes are disabled on this server"}
+	case kind == apiAdmin && p.DisableAdmin:
+		return &httpError{http.StatusForbidden, "Admin operations are disabled on this server"}
+	}
+	return nil
+}
+
+// classifyPath is the API path Classify methods match: cleaned, so that
+// "//api/…" or "/api/./…" cannot pass for a different API.
+func classifyPath(p string) string {
+	if p == "" {
+		return ""
+	}
+	return path.Clean("/" + p)
+}
+
+// ── Authorization and audit hooks ───────────────────────────────────────────
+
+// Operation describes a proxied API call.
+type Operation struct {
+	Backend    string // connection type, e.g. "prometheus"
+	Connection string // server-side connection name, "" for browser-defined targets
+	Target     string // upstream base URL
+	Method     string // HTTP method
+	Path       string // backend API path, e.g. "/api/v1/query"
+	Kind       string // "read", "write" or "admin"
+}
+
+// AuditEvent reports a completed proxied API call.
+type AuditEvent struct {
+	Operation
+	Time     time.Time     // when the call started
+	Duration time.Duration // until the handler returned
+	Status   int           // status sent to the client
+}
+
+// hooks are the embedder's callbacks and limits, and the query history,
+// shared by all proxies.
+type hooks struct {
+	authorize          func(*http.Request, Operation) error
+	audit              func(*http.Request, AuditEvent)
+	user               func(*http.Request) string
+	history            *historyStore
+	maxRequestSize     int64
+	allowedOrigins     []string
+	upgradeIdleTimeout time.Duration
+}
+
+// authorize applies the write/admin policy and the Authorize hook to op, and
+// records op for the audit event.
+func (env *proxyEnv) authorize(r *http.Request, op Operation, kind apiKind) error {
+	op.Kind = kind.String()
+	if rec, ok := r.Context().Value(callKey{}).(*callRecorder); ok {
+		rec.op = &op
+	}
+	if err := env.policy.check(kind); err != nil {
+		return err
+	}
+	if env.hooks.authorize != nil {
+		if err := env.hooks.authorize(r, op); err != nil {
+			return &httpError{http.StatusForbidden, err.Error()}
+		}
+	}
+	return nil
+}
+
+// authorizeWrite checks a write to path of the server-side connection c
+// ahead of the proxied calls, so a request can be refused up front.
+func (env *proxyEnv) authorizeWrite(r *http.Request, c *CLIConnection, path string) error {
+	op := Operation{Backend: c.Type, Connection: c.Name, Target: c.URL, Method: http.MethodPost, Path: path}
+	return env.authorize(r, op, apiWrite)
+}
+
+// recordQuery attaches a query to the call for the query history.
+func (env *proxyEnv) recordQuery(r *http.Request, q historyQuery) {
+	if rec, ok := r.Context().Value(callKey{}).(*callRecorder); ok {
+		rec.query = &q
+	}
+}
+
+// callKey is the context key of the request's *callRecorder.
+type callKey struct{}
+
+// observe applies the request size limit to next and reports each call that
+// reached authorization to the Audit hook and, for queries, to the history.
+func (env *proxyEnv) observe(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if env.hooks.maxRequestSize > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, env.hooks.maxRequestSize)
+		}
+		if env.hooks.audit == nil && env.hooks.history == nil {
+			next(w, r)
+			return
+		}
+		rec := &callRecorder{ResponseWriter: w}
+		start := time.Now()
+		next(rec, r.WithContext(context.WithValue(r.Context(), callKey{}, rec)))
+		if rec.op == nil {
+			return
+		}
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		duration := time.Since(start)
+		if env.hooks.audit != nil {
+			env.hooks.audit(r, AuditEvent{
+				Operation: *rec.op,
+				Time:      start,
+				Duration:  duration,
+				Status:    rec.status,
+			})
+		}
+		if env.hooks.history != nil && rec.query != nil {
+			e := historyEntry{
+				Time:        start,
+				Connection:  rec.op.Connection,
+				Type:        rec.op.Backend,
+				Target:      rec.op.Target,
+				Language:    rec.query.Language,
+				Query:       rec.query.Query,
+				Database:    rec.query.Database,
+				Start:       rec.query.Start,
+				End:         rec.query.End,
+				DurationMs:  duration.Milliseconds(),
+				ResultBytes: rec.written,
+				Status:      rec.status,
+				Error:       historyErrorMessage(rec.status, rec.head),
+			}
+			if env.hooks.user != nil {
+				e.User = env.hooks.user(r)
+			}
+			env.hooks.history.add(e)
+		}
+	}
+}
+
+// maxRecordedHead is how much of a response body is kept to extract a
+// query error for the history.
+const maxRecordedHead = 4 << 10
+
+// callRecorder captures the authorized operation, the query if any, and the
+// response status, size and first bytes.
+type callRecorder struct {
+	http.ResponseWriter
+	status  int
+	op      *Operation
+	query   *historyQuery
+	written int64
+	head    []byte
+}
+
+func (rec *callRecorder) WriteHeader(code int) {
+	if rec.status == 0 {
+		rec.status = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *callRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	if n := maxRecordedHead - len(rec.head); n > 0 && rec.query != nil {
+		if n > len(b) {
+			n = len(b)
+		}
+		rec.head = append(rec.head, b[:n]...)
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.written += int64(n)
+	return n, err
+}
+
+// Hijack records the upgrade as 101 Switching Protocols.
+func (rec *callRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(rec.ResponseWriter).Hijack()
+	if err == nil {
+		rec.status = http.StatusSwitchingProtocols
+	}
+	return conn, brw, err
+}
+
+func (rec *callRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rec *callRecorder) Unwrap() http.ResponseWriter { return rec.ResponseWriter }
@@ -0,0 +1,220 @@
+package timeseriesui
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ── Prometheus flavors ──────────────────────────────────────────────────────
+//
+// Mimir, Cortex and Thanos Query all serve the Prometheus HTTP API, with a
+// few differences the proxy smooths over:
+//
+//	mimir, cortex  API under /prometheus, tenant in X-Scope-OrgID,
+//	               ring / limits / runtime-config pages at the root
+//	thanos         tenant in THANOS-TENANT, extra query parameters
+//	               (dedup, partial_response, max_source_resolution)
+//	               and /api/v1/stores
+//
+// The flavor comes from the connection's flavor field, or from the
+// X-Prometheus-Flavor header for browser-defined connections.
+
+func init() { registerBackend(&prometheusBackend{}) }
+
+type prometheusBackend struct {
+	baseBackend
+	flags  connFlags
+	flavor string
+	tenant string
+}
+
+func (*prometheusBackend) Type() string        { return "prometheus" }
+func (*prometheusBackend) DisplayName() string { return "Prometheus" }
+func (*prometheusBackend) HealthPath() string  { return "/api/v1/status/buildinfo" }
+
+func (b *prometheusBackend) RegisterFlags(fs *flag.FlagSet) {
+	b.flags.register(fs, "prometheus", "Prometheus")
+	b.flags.registerAuth(fs, "prometheus", "Prometheus", "basic-auth ")
+	fs.StringVar(&b.flavor, "prometheus-flavor", "", "Prometheus flavor: prometheus, mimir, cortex or thanos")
+	fs.StringVar(&b.tenant, "prometheus-tenant", "", "Tenant for Mimir/Cortex (X-Scope-OrgID) or Thanos (THANOS-TENANT)")
+}
+
+func (b *prometheusBackend) FlagConnections() []CLIConnection {
+	return b.flags.build(b, func(_ int, c *CLIConnection) {
+		c.Flavor = b.flavor
+		c.TenantID = b.tenant
+	})
+}
+
+func (*prometheusBackend) Validate(c *CLIConnection) error {
+	return validateFlavor(c.Flavor)
+}
+
+// Classify treats remote-write style ingestion as writes, and the TSDB admin
+// API, lifecycle endpoints, rule changes and ingester/ring actions as admin.
+// Paths are matched cleaned and, for Mimir and Cortex, with or without the
+// /prometheus prefix.
+func (*prometheusBackend) Classify(r *http.Request, t *proxyTarget) apiKind {
+	readOnly := r.Method == http.MethodGet || r.Method == http.MethodHead
+	p := classifyPath(t.Path)
+	if strings.HasPrefix(p, mimirPrometheusPrefix+"/") {
+		p = strings.TrimPrefix(p, mimirPrometheusPrefix)
+	}
+	switch {
+	case p == "/api/v1/write", p == "/api/v1/push",
+		strings.HasPrefix(p, "/api/v1/otlp/"), strings.HasPrefix(p, "/otlp/"):
+		return apiWrite
+	case strings.HasPrefix(p, "/api/v1/admin/"),
+		strings.HasPrefix(p, "/-/") && p != "/-/healthy" && p != "/-/ready",
+		strings.HasPrefix(p, "/ingester/") && !strings.HasSuffix(p, "/ring"),
+		strings.HasPrefix(p, "/config/v1/rules") && !readOnly,
+		strings.HasPrefix(p, "/api/v1/rules") && !readOnly,
+		strings.HasPrefix(p, "/ruler/") && !readOnly,
+		p == "/api/v1/alerts" && !readOnly,
+		strings.HasSuffix(p, "/ring") && !readOnly:
+		return apiAdmin
+	}
+	return apiRead
+}
+
+// ExtractQuery records instant and range queries in the query history.
+func (*prometheusBackend) ExtractQuery(r *http.Request, t *proxyTarget) (historyQuery, bool) {
+	return promQuery(r, t, "promql")
+}
+
+// promQuery extracts the query and time range of a Prometheus-API
+// /api/v1/query or /api/v1/query_range call.
+func promQuery(r *http.Request, t *proxyTarget, language string) (historyQuery, bool) {
+	if !strings.HasSuffix(t.Path, "/api/v1/query") && !strings.HasSuffix(t.Path, "/api/v1/query_range") {
+		return historyQuery{}, false
+	}
+	q := historyQuery{
+		Language: language,
+		Query:    requestParam(r, t.Params, "query"),
+		Start:    requestParam(r, t.Params, "start"),
+		End:      requestParam(r, t.Params, "end"),
+	}
+	if q.End == "" {
+		q.End = requestParam(r, t.Params, "time")
+	}
+	return q, q.Query != ""
+}
+
+const (
+	flavorPrometheus = "prometheus"
+	flavorMimir      = "mimir"
+	flavorCortex     = "cortex"
+	flavorThanos     = "thanos"
+)
+
+// headerPromFlavor selects the flavor for browser-defined connections.
+const headerPromFlavor = "X-Prometheus-Flavor"
+
+// mimirPrometheusPrefix is where Mimir and Cortex serve the Prometheus API.
+const mimirPrometheusPrefix = "/prometheus"
+
+// flavorStatusPaths lists the flavor-specific status endpoints served at the
+// root of the server rather than under the Prometheus API prefix.
+var flavorStatusPaths = map[string][]string{
+	flavorMimir: {
+		"/ingester/ring", "/distributor/ring", "/store-gateway/ring",
+		"/compactor/ring", "/ruler/ring", "/alertmanager/ring",
+		"/api/v1/user_limits", "/distributor/all_user_stats",
+		"/runtime_config", "/memberlist", "/services", "/config", "/ready",
+	},
+	flavorCortex: {
+		"/ingester/ring", "/distributor/ring", "/store-gateway/ring",
+		"/compactor/ring", "/ruler/ring", "/distributor/all_user_stats",
+		"/runtime_config", "/services", "/config", "/ready",
+	},
+	flavorThanos: {"/api/v1/stores"},
+}
+
+// thanosQueryPaths are the read APIs that accept Thanos query parameters.
+var thanosQueryPaths = map[string]bool{
+	"/api/v1/query":       true,
+	"/api/v1/query_range": true,
+	"/api/v1/series":      true,
+	"/api/v1/labels":      true,
+}
+
+// validateFlavor reports whether flavor is a known Prometheus flavor.
+func validateFlavor(flavor string) error {
+	switch flavor {
+	case "", flavorPrometheus, flavorMimir, flavorCortex, flavorThanos:
+		return nil
+	}
+	return fmt.Errorf("unknown flavor %q: must be prometheus, mimir, cortex or thanos", flavor)
+}
+
+// isFlavorStatusPath reports whether apiPath is a root-level status endpoint
+// of the flavor.
+func isFlavorStatusPath(flavor, apiPath string) bool {
+	for _, p := range flavorStatusPaths[flavor] {
+		if apiPath == p || strings.HasPrefix(apiPath, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Route injects the flavor's tenant header, Thanos query defaults, and the
+// Mimir/Cortex API prefix.
+func (*prometheusBackend) Route(r *http.Request, t *proxyTarget) error {
+	flavor := r.Header.Get(headerPromFlavor)
+	tenant := r.Header.Get("X-Scope-OrgID")
+	if t.Conn != nil {
+		flavor, tenant = t.Conn.Flavor, t.Conn.TenantID
+	}
+	if err := validateFlavor(flavor); err != nil {
+		return &httpError{http.StatusBadRequest, err.Error()}
+	}
+
+	switch flavor {
+	case flavorMimir, flavorCortex:
+		if tenant != "" {
+			t.Header.Set("X-Scope-OrgID", tenant)
+		}
+		// Accept the server root or its /prometheus prefix as the URL.
+		root := strings.TrimSuffix(t.Base, mimirPrometheusPrefix)
+		if isFlavorStatusPath(flavor, t.Path) {
+			t.Base = root
+			if t.Header.Get("Accept") == "" {
+				t.Header.Set("Accept", "application/json")
+			}
+		} else if strings.HasPrefix(t.Path, "/api/") {
+			t.Base = root + mimirPrometheusPrefix
+		}
+
+	case flavorThanos:
+		if tenant != "" {
+			t.Header.Set("THANOS-TENANT", tenant)
+		}
+		if t.Conn != nil && thanosQueryPaths[t.Path] {
+			setDefaultParam(t, "dedup", boolParam(t.Conn.Dedup))
+			setDefaultParam(t, "partial_response", boolParam(t.Conn.PartialResponse))
+			setDefaultParam(t, "max_source_resolution", t.Conn.MaxSourceResolution)
+		}
+	}
+	return nil
+}
+
+// setDefaultParam sets a query parameter the caller did not set.
+func setDefaultParam(t *proxyTarget, key, value string) {
+	if value != "" && t.Params.Get(key) == "" {
+		t.Params.Set(key, value)
+	}
+}
+
+// boolParam formats an optional boolean setting, "" when unset.
+func boolParam(b *bool) string {
+	if b == nil {
+		return ""
+	}
+	if *b {
+		return "true"
+	}
+	return "false"
+}
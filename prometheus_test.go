@@ -0,0 +1,81 @@
+package timeseriesui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestPrometheusClassify(t *testing.T) {
+	cases := []struct {
+		method, path string
+		want         apiKind
+	}{
+		{http.MethodGet, "/api/v1/query", apiRead},
+		{http.MethodPost, "/api/v1/write", apiWrite},
+		{http.MethodPost, "/prometheus/api/v1/push", apiWrite},
+		{http.MethodPost, "//api/v1/write", apiWrite},
+		{http.MethodPost, "/otlp/v1/metrics", apiWrite},
+		{http.MethodPost, "/api/v1/admin/tsdb/delete_series", apiAdmin},
+		{http.MethodPost, "/api/v1/./admin/tsdb/snapshot", apiAdmin},
+		{http.MethodPost, "/-/reload", apiAdmin},
+		{http.MethodGet, "/-/healthy", apiRead},
+		{http.MethodPost, "/ingester/shutdown", apiAdmin},
+		{http.MethodGet, "/ingester/ring", apiRead},
+		{http.MethodPost, "/ingester/ring", apiAdmin},
+		{http.MethodGet, "/prometheus/config/v1/rules", apiRead},
+		{http.MethodPost, "/prometheus/config/v1/rules/ns", apiAdmin},
+		{http.MethodDelete, "/api/v1/rules/ns", apiAdmin},
+		{http.MethodPost, "/api/v1/alerts", apiAdmin},
+	}
+	b := backends["prometheus"]
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, "/", nil)
+		if got := b.Classify(r, &proxyTarget{Path: c.path}); got != c.want {
+			t.Errorf("%s %s: %v, want %v", c.method, c.path, got, c.want)
+		}
+	}
+}
+
+func TestPrometheusFlavorRoute(t *testing.T) {
+	yes := true
+	mimir := &CLIConnection{Type: "prometheus", Flavor: flavorMimir, TenantID: "team-a"}
+	thanos := &CLIConnection{Type: "prometheus", Flavor: flavorThanos, TenantID: "t1", Dedup: &yes, MaxSourceResolution: "5m"}
+	cases := []struct {
+		conn       *CLIConnection
+		base, path string
+		params     string
+		wantURL    string
+		header     string // the tenant header and its value
+	}{
+		{mimir, "http://mimir", "/api/v1/query", "query=up", "http://mimir/prometheus/api/v1/query?query=up", "X-Scope-Orgid: team-a"},
+		{mimir, "http://mimir/prometheus", "/api/v1/labels", "", "http://mimir/prometheus/api/v1/labels", "X-Scope-Orgid: team-a"},
+		{mimir, "http://mimir/prometheus", "/ingester/ring", "", "http://mimir/ingester/ring", "X-Scope-Orgid: team-a"},
+		{thanos, "http://thanos", "/api/v1/query", "query=up&dedup=false", "http://thanos/api/v1/query?dedup=false&max_source_resolution=5m&query=up", "Thanos-Tenant: t1"},
+		{thanos, "http://thanos", "/api/v1/stores", "", "http://thanos/api/v1/stores", "Thanos-Tenant: t1"},
+	}
+	b := backends["prometheus"]
+	for _, c := range cases {
+		params, _ := url.ParseQuery(c.params)
+		tg := &proxyTarget{Conn: c.conn, Base: c.base, Path: c.path, Params: params, Header: http.Header{}}
+		if err := b.Route(httptest.NewRequest(http.MethodGet, "/", nil), tg); err != nil {
+			t.Fatal(err)
+		}
+		var header string
+		for k := range tg.Header {
+			if k != "Accept" {
+				header = k + ": " + tg.Header.Get(k)
+			}
+		}
+		if tg.URL() != c.wantURL || header != c.header {
+			t.Errorf("%s %s: %s with %q, want %s with %q", c.base, c.path, tg.URL(), header, c.wantURL, c.header)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(headerPromFlavor, "loki")
+	if err := b.Route(r, &proxyTarget{Path: "/api/v1/query", Params: url.Values{}, Header: http.Header{}}); httpErrorStatus(err, 0) != http.StatusBadRequest {
+		t.Errorf("unknown flavor: %v", err)
+	}
+}
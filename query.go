@@ -0,0 +1,536 @@
+package timeseriesui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ── Unified query API ───────────────────────────────────────────────────────
+//
+// /api/v1/query runs an InfluxQL, PromQL or MetricsQL query against a
+// connection and returns the result as frames, whatever the backend:
+//
+//	GET  /api/v1/query?connection=&type=&language=&query=&database=&start=&end=&step=
+//	POST /api/v1/query   (the same fields as a JSON object)
+//
+// The query is sent as a sub-request through the backend's proxy handler,
+// so credentials, routing, access policy, audit and history all apply.
+
+// queryRequest selects the connection, query and time range.
+type queryRequest struct {
+	Connection string `json:"connection"`         // name or URL of a server-side connection, or any URL with type
+	Type       string `json:"type,omitempty"`     // backend type; implied by server-side connections
+	Language   string `json:"language,omitempty"` // defaults to the backend's
+	Query      string `json:"query"`
+	Database   string `json:"database,omitempty"` // InfluxQL only
+	Start      string `json:"start,omitempty"`    // RFC 3339, Unix seconds, "now" or "now-<duration>"
+	End        string `json:"end,omitempty"`
+	Step       string `json:"step,omitempty"` // range step for PromQL and MetricsQL; automatic if unset
+}
+
+// queryFrame is one series: a time column and value columns sharing a set
+// of labels.
+type queryFrame struct {
+	Name   string            `json:"name,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Fields []frameField      `json:"fields"`
+}
+
+// frameField is a column of a frame. Times are Unix milliseconds; missing
+// numbers, NaN and infinities are null.
+type frameField struct {
+	Name   string        `json:"name"`
+	Type   string        `json:"type"` // "time", "number", "string" or "boolean"
+	Values []interface{} `json:"values"`
+}
+
+// queryResult is the response of /api/v1/query.
+type queryResult struct {
+	Frames []queryFrame `json:"frames"`
+	Meta   queryMeta    `json:"meta"`
+}
+
+type queryMeta struct {
+	Connection string   `json:"connection,omitempty"`
+	Type       string   `json:"type"`
+	Language   string   `json:"language"`
+	Query      string   `json:"executedQuery"`
+	ResultType string   `json:"resultType"` // "matrix", "vector", "scalar", "string" or "series"
+	Warnings   []string `json:"warnings,omitempty"`
+}
+
+// queryLanguages lists the languages of each backend type, default first.
+var queryLanguages = map[string][]string{
+	"influxdb":        {"influxql"},
+	"influxdb2":       {"influxql"},
+	"influxdb3":       {"influxql"},
+	"prometheus":      {"promql"},
+	"victoriametrics": {"metricsql", "promql"},
+}
+
+// queryRunner executes unified queries through the proxy handlers served by
+// mux under basePath.
+type queryRunner struct {
+	mux      http.Handler
+	basePath string
+	conns    *connectionStore
+}
+
+// handler serves /api/v1/query.
+func (qr *queryRunner) handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setCORS(w)
+		var req queryRequest
+		switch r.Method {
+		case http.MethodOptions:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case http.MethodGet:
+			req = queryRequestFromParams(r.URL.Query())
+		case http.MethodPost:
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				jsonError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+				return
+			}
+		default:
+			jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		res, err := qr.run(r, &req)
+		if err != nil {
+			jsonError(w, httpErrorStatus(err, http.StatusBadGateway), err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, res)
+	}
+}
+
+// queryRequestFromParams reads a queryRequest from query parameters.
+func queryRequestFromParams(p url.Values) queryRequest {
+	return queryRequest{
+		Connection: p.Get("connection"),
+		Type:       p.Get("type"),
+		Language:   p.Get("language"),
+		Query:      p.Get("query"),
+		Database:   p.Get("database"),
+		Start:      p.Get("start"),
+		End:        p.Get("end"),
+		Step:       p.Get("step"),
+	}
+}
+
+// run resolves req, sends it through the proxy and normalizes the result.
+// r supplies the context and the headers (user, X-Proxy-* credentials) of
+// the sub-request.
+func (qr *queryRunner) run(r *http.Request, req *queryRequest) (*queryResult, error) {
+	pq, err := qr.prepare(req)
+	if err != nil {
+		return nil, err
+	}
+	return qr.exec(r, pq, pq.start, pq.end, false)
+}
+
+// preparedQuery is a resolved and validated queryRequest.
+type preparedQuery struct {
+	meta       queryMeta
+	target     string
+	query      string
+	database   string
+	start, end time.Time     // start is zero for instant queries
+	step       time.Duration // PromQL and MetricsQL range step
+}
+
+// prepare resolves the connection and time range of req. Times are
+// truncated to milliseconds.
+func (qr *queryRunner) prepare(req *queryRequest) (*preparedQuery, error) {
+	if strings.TrimSpace(req.Query) == "" {
+		return nil, &httpError{http.StatusBadRequest, "query is required"}
+	}
+	target, meta, err := qr.resolve(req)
+	if err != nil {
+		return nil, err
+	}
+	pq := &preparedQuery{meta: *meta, target: target, query: req.Query, database: req.Database}
+
+	now := time.Now()
+	if req.Start != "" {
+		if pq.start, _, err = parseRelativeTime(req.Start, now); err != nil {
+			return nil, &httpError{http.StatusBadRequest, "Invalid start: " + err.Error()}
+		}
+	}
+	pq.end = now
+	if req.End != "" {
+		if pq.end, _, err = parseRelativeTime(req.End, now); err != nil {
+			return nil, &httpError{http.StatusBadRequest, "Invalid end: " + err.Error()}
+		}
+	}
+	if !pq.start.IsZero() {
+		pq.start = pq.start.Truncate(time.Millisecond)
+	}
+	pq.end = pq.end.Truncate(time.Millisecond)
+	if !pq.start.IsZero() && pq.start.After(pq.end) {
+		return nil, &httpError{http.StatusBadRequest, "start is after end"}
+	}
+
+	if pq.database == "" {
+		if c := qr.conns.snapshot().find(target, meta.Type); c != nil {
+			pq.database = c.DefaultDatabase
+		}
+	}
+	if req.Step != "" {
+		if pq.step, err = parseLongDuration(req.Step); err != nil {
+			secs, ferr := strconv.ParseFloat(req.Step, 64)
+			if ferr != nil || secs <= 0 {
+				return nil, &httpError{http.StatusBadRequest, "Invalid step: " + err.Error()}
+			}
+			pq.step = time.Duration(secs * float64(time.Second))
+		}
+		if pq.step < time.Millisecond {
+			return nil, &httpError{http.StatusBadRequest, "step must be at least 1ms"}
+		}
+	} else if !pq.start.IsZero() {
+		pq.step = autoStep(pq.end.Sub(pq.start))
+	}
+	return pq, nil
+}
+
+// exec runs pq over [start, end], or [start, end) with endExclusive, and
+// normalizes the result.
+func (qr *queryRunner) exec(r *http.Request, pq *preparedQuery, start, end time.Time, endExclusive bool) (*queryResult, error) {
+	res := &queryResult{Meta: pq.meta}
+	params := url.Values{"target": {pq.target}}
+	if pq.meta.Language == "influxql" {
+		res.Meta.Query = influxTimeFilter(pq.query, start, end, endExclusive)
+		params.Set("path", "/query")
+		params.Set("q", res.Meta.Query)
+		params.Set("epoch", "ms")
+		if pq.database != "" {
+			params.Set("db", pq.database)
+		}
+	} else {
+		res.Meta.Query = pq.query
+		params.Set("query", pq.query)
+		if start.IsZero() {
+			params.Set("path", "/api/v1/query")
+			params.Set("time", promTime(end))
+		} else {
+			params.Set("path", "/api/v1/query_range")
+			params.Set("start", promTime(start))
+			params.Set("end", promTime(end))
+			params.Set("step", strconv.FormatFloat(pq.step.Seconds(), 'f', -1, 64))
+		}
+	}
+
+	body, err := qr.proxy(r, pq.meta.Type, params)
+	if err != nil {
+		return nil, err
+	}
+	if pq.meta.Language == "influxql" {
+		err = res.parseInflux(body)
+	} else {
+		err = res.parseProm(body)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if res.Frames == nil {
+		res.Frames = []queryFrame{}
+	}
+	return res, nil
+}
+
+// resolve finds the target URL, type and language of req.
+func (qr *queryRunner) resolve(req *queryRequest) (string, *queryMeta, error) {
+	if req.Connection == "" {
+		return "", nil, &httpError{http.StatusBadRequest, "connection is required"}
+	}
+	meta := &queryMeta{Type: req.Type, Language: req.Language}
+	target := req.Connection
+	snap := qr.conns.snapshot()
+	if c := snap.find(req.Connection, meta.Type); c != nil {
+		target, meta.Type, meta.Connection = c.URL, c.Type, c.Name
+	} else if u, err := url.Parse(target); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		if c := snap.find(req.Connection, ""); c != nil {
+			return "", nil, &httpError{http.StatusBadRequest, fmt.Sprintf("connection %q is of type %s", c.Name, c.Type)}
+		}
+		return "", nil, &httpError{http.StatusNotFound, fmt.Sprintf("unknown connection %q", req.Connection)}
+	} else if meta.Type == "" {
+		return "", nil, &httpError{http.StatusBadRequest, "type is required for connections given by URL"}
+	}
+
+	langs, ok := queryLanguages[meta.Type]
+	if !ok {
+		return "", nil, &httpError{http.StatusBadRequest, fmt.Sprintf("the query API does not support %s connections", meta.Type)}
+	}
+	if meta.Language == "" {
+		meta.Language = langs[0]
+	}
+	for _, l := range langs {
+		if l == meta.Language {
+			return target, meta, nil
+		}
+	}
+	return "", nil, &httpError{http.StatusBadRequest, fmt.Sprintf("%s connections do not support %s", meta.Type, meta.Language)}
+}
+
+// find returns the connection named ref or with URL ref, of type typ
+// unless typ is empty.
+func (s *connSnapshot) find(ref, typ string) *CLIConnection {
+	for i, c := range s.conns {
+		if typ != "" && c.Type != typ {
+			continue
+		}
+		if c.Name == ref || strings.TrimRight(c.URL, "/") == strings.TrimRight(ref, "/") {
+			return &s.conns[i]
+		}
+	}
+	return nil
+}
+
+// proxy sends a GET for params to the proxy of typ and returns the body of a
+// successful response.
+func (qr *queryRunner) proxy(r *http.Request, typ string, params url.Values) ([]byte, error) {
+	return qr.send(r, http.MethodGet, typ, params, http.Header{"Accept": {"application/json"}}, nil)
+}
+
+// send makes a sub-request through the proxy of typ with the given headers
+// and body, and returns the body of a successful response.
+func (qr *queryRunner) send(r *http.Request, method, typ string, params url.Values, header http.Header, body []byte) ([]byte, error) {
+	sub := r.Clone(r.Context())
+	sub.Method = method
+	sub.URL = &url.URL{Path: qr.basePath + "/proxy/" + typ + "/", RawQuery: params.Encode()}
+	sub.RequestURI = ""
+	sub.Body, sub.ContentLength = http.NoBody, 0
+	if body != nil {
+		sub.Body, sub.ContentLength = io.NopCloser(bytes.NewReader(body)), int64(len(body))
+	}
+	for _, h := range []string{"Content-Type", "Content-Length", "Content-Encoding", "Accept"} {
+		sub.Header.Del(h)
+	}
+	for k, v := range header {
+		sub.Header[k] = v
+	}
+
+	rec := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+	qr.mux.ServeHTTP(rec, sub)
+	respBody := rec.body.Bytes()
+	if rec.status >= 400 {
+		return nil, &httpError{rec.status, historyErrorMessage(rec.status, respBody)}
+	}
+	return respBody, nil
+}
+
+// bufferedResponse collects a sub-request's response in memory.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header         { return b.header }
+func (b *bufferedResponse) Write(p []byte) (int, error) { return b.body.Write(p) }
+func (b *bufferedResponse) WriteHeader(status int)      { b.status = status }
+
+// influxTimeFilter replaces $timeFilter with the time range, if one is set.
+func influxTimeFilter(q string, start, end time.Time, endExclusive bool) string {
+	if start.IsZero() || !strings.Contains(q, "$timeFilter") {
+		return q
+	}
+	op := "<="
+	if endExclusive {
+		op = "<"
+	}
+	filter := fmt.Sprintf("time >= %dms AND time %s %dms", start.UnixMilli(), op, end.UnixMilli())
+	return strings.ReplaceAll(q, "$timeFilter", filter)
+}
+
+// promTime formats t as Unix seconds with millisecond precision.
+func promTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixMilli())/1000, 'f', -1, 64)
+}
+
+// autoStep aims for about 300 points per series, in whole seconds.
+func autoStep(rng time.Duration) time.Duration {
+	step := (rng / 300).Truncate(time.Second)
+	if step < time.Second {
+		step = time.Second
+	}
+	return step
+}
+
+// parseProm converts a Prometheus API query response.
+func (res *queryResult) parseProm(body []byte) error {
+	var resp struct {
+		Status    string   `json:"status"`
+		Error     string   `json:"error"`
+		ErrorType string   `json:"errorType"`
+		Warnings  []string `json:"warnings"`
+		Data      struct {
+			ResultType string          `json:"resultType"`
+			Result     json.RawMessage `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return &httpError{http.StatusBadGateway, "Invalid response from backend: " + err.Error()}
+	}
+	if resp.Status != "success" {
+		return &httpError{http.StatusBadRequest, strings.TrimPrefix(resp.ErrorType+": "+resp.Error, ": ")}
+	}
+	res.Meta.ResultType = resp.Data.ResultType
+	res.Meta.Warnings = resp.Warnings
+
+	switch resp.Data.ResultType {
+	case "matrix":
+		var series []struct {
+			Metric map[string]string `json:"metric"`
+			Values [][2]interface{}  `json:"values"`
+		}
+		if err := json.Unmarshal(resp.Data.Result, &series); err != nil {
+			return &httpError{http.StatusBadGateway, "Invalid matrix: " + err.Error()}
+		}
+		for _, s := range series {
+			res.Frames = append(res.Frames, promFrame(s.Metric, s.Values))
+		}
+	case "vector":
+		var series []struct {
+			Metric map[string]string `json:"metric"`
+			Value  [2]interface{}    `json:"value"`
+		}
+		if err := json.Unmarshal(resp.Data.Result, &series); err != nil {
+			return &httpError{http.StatusBadGateway, "Invalid vector: " + err.Error()}
+		}
+		for _, s := range series {
+			res.Frames = append(res.Frames, promFrame(s.Metric, [][2]interface{}{s.Value}))
+		}
+	case "scalar", "string":
+		var sample [2]interface{}
+		if err := json.Unmarshal(resp.Data.Result, &sample); err != nil {
+			return &httpError{http.StatusBadGateway, "Invalid " + resp.Data.ResultType + ": " + err.Error()}
+		}
+		f := promFrame(nil, [][2]interface{}{sample})
+		if resp.Data.ResultType == "string" {
+			f.Fields[1] = frameField{Name: "value", Type: "string", Values: []interface{}{fmt.Sprint(sample[1])}}
+		}
+		res.Frames = append(res.Frames, f)
+	default:
+		return &httpError{http.StatusBadGateway, fmt.Sprintf("Unsupported result type %q", resp.Data.ResultType)}
+	}
+	return nil
+}
+
+// promFrame converts Prometheus samples — [seconds, "value"] pairs — to a
+// frame named after the metric.
+func promFrame(metric map[string]string, samples [][2]interface{}) queryFrame {
+	f := queryFrame{
+		Name: metric["__name__"],
+		Fields: []frameField{
+			{Name: "time", Type: "time", Values: make([]interface{}, len(samples))},
+			{Name: "value", Type: "number", Values: make([]interface{}, len(samples))},
+		},
+	}
+	for k, v := range metric {
+		if k == "__name__" {
+			continue
+		}
+		if f.Labels == nil {
+			f.Labels = map[string]string{}
+		}
+		f.Labels[k] = v
+	}
+	for i, s := range samples {
+		if ts, ok := s[0].(float64); ok {
+			f.Fields[0].Values[i] = int64(math.Round(ts * 1000))
+		}
+		if str, ok := s[1].(string); ok {
+			if v, err := strconv.ParseFloat(str, 64); err == nil && !math.IsNaN(v) && !math.IsInf(v, 0) {
+				f.Fields[1].Values[i] = v
+			}
+		}
+	}
+	return f
+}
+
+// parseInflux converts an InfluxDB 1.x /query response made with epoch=ms.
+func (res *queryResult) parseInflux(body []byte) error {
+	var resp struct {
+		Error   string `json:"error"`
+		Results []struct {
+			Error    string `json:"error"`
+			Messages []struct {
+				Level string `json:"level"`
+				Text  string `json:"text"`
+			} `json:"messages"`
+			Series []struct {
+				Name    string            `json:"name"`
+				Tags    map[string]string `json:"tags"`
+				Columns []string          `json:"columns"`
+				Values  [][]interface{}   `json:"values"`
+			} `json:"series"`
+		} `json:"results"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&resp); err != nil {
+		return &httpError{http.StatusBadGateway, "Invalid response from backend: " + err.Error()}
+	}
+	if resp.Error != "" {
+		return &httpError{http.StatusBadRequest, resp.Error}
+	}
+	res.Meta.ResultType = "series"
+	for _, result := range resp.Results {
+		if result.Error != "" {
+			return &httpError{http.StatusBadRequest, result.Error}
+		}
+		for _, m := range result.Messages {
+			res.Meta.Warnings = append(res.Meta.Warnings, m.Level+": "+m.Text)
+		}
+		for _, s := range result.Series {
+			f := queryFrame{Name: s.Name, Labels: s.Tags}
+			for col, name := range s.Columns {
+				field := frameField{Name: name, Values: make([]interface{}, len(s.Values))}
+				for row, vals := range s.Values {
+					if col < len(vals) {
+						field.Values[row] = vals[col]
+					}
+				}
+				field.Type = influxColumnType(name, field.Values)
+				if field.Type == "time" {
+					for i, v := range field.Values {
+						if n, ok := v.(json.Number); ok {
+							field.Values[i], _ = n.Int64()
+						}
+					}
+				}
+				f.Fields = append(f.Fields, field)
+			}
+			res.Frames = append(res.Frames, f)
+		}
+	}
+	return nil
+}
+
+// influxColumnType infers a column's type from its first non-null value.
+func influxColumnType(name string, values []interface{}) string {
+	if name == "time" {
+		return "time"
+	}
+	for _, v := range values {
+		switch v.(type) {
+		case json.Number:
+			return "number"
+		case bool:
+			return "boolean"
+		case string:
+			return "string"
+		}
+	}
+	return "number"
+}
@@ -0,0 +1,21 @@
+package timeseriesui
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestQueryStep(t *testing.T) {
+	h := newTestHandler(t, Options{
+		Connections: []CLIConnection{{Name: "prom", Type: "prometheus", URL: "http://127.0.0.1:1"}},
+	})
+	for _, step := range []string{"0", "-1", "1e-10", "0.0001", "500us"} {
+		q := url.Values{"connection": {"prom"}, "query": {"up"}, "start": {"now-1h"}, "step": {step}}
+		rec := serve(h, http.MethodGet, "/api/v1/query?"+q.Encode(), nil, nil)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "step") {
+			t.Errorf("step %s: status %d: %s", step, rec.Code, rec.Body)
+		}
+	}
+}
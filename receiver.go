@@ -0,0 +1,463 @@
+package timeseriesui
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ── Remote write receiver ───────────────────────────────────────────────────
+//
+// POST <BasePath>/api/v1/write accepts Prometheus remote write requests and
+// fans them out to the configured targets, so Prometheus or vmagent can feed
+// several databases through one URL:
+//
+//	GET /api/v1/write/stats    per-target counters as JSON
+//	GET /api/v1/write/metrics  the same in the Prometheus text format
+//
+// Every target has its own queue and sender, so a slow or failing target does
+// not hold up the others. A request is accepted (204) once it is queued for
+// every target; when a queue is full the receiver answers 503 and the client
+// retries. Senders retry failed writes with backoff, except those rejected
+// with a 4xx status, and drop them after MaxRetries.
+//
+// Writes go through the proxy of the target's connection, so the write
+// policy, the Authorize hook and the Audit hook apply as for any other
+// write; the policy and Authorize hook are also checked for every target
+// before a request is accepted.
+
+const (
+	defaultRemoteWriteQueue   = 100
+	defaultRemoteWriteRetries = 5
+	maxRemoteWriteSize        = 64 << 20 // uncompressed WriteRequest
+	maxRemoteWriteBackoff     = 30 * time.Second
+)
+
+// RemoteWriteTarget is a destination of the remote write receiver.
+type RemoteWriteTarget struct {
+	Connection string `json:"connection"` // server-side connection name
+	// Protocol is "remote_write" (forwarded as received), "import"
+	// (VictoriaMetrics JSON lines) or "line" (InfluxDB line protocol). The
+	// default is line for InfluxDB connections and remote_write otherwise.
+	Protocol string `json:"protocol,omitempty"`
+	// Path overrides the API path, e.g. /api/v1/push for Mimir.
+	Path string `json:"path,omitempty"`
+	// Database receives line protocol writes (default: the connection's
+	// default database).
+	Database string `json:"database,omitempty"`
+	// Measurement and Field map a sample to line protocol. Both are templates
+	// over {name}, the metric name, and {prefix} and {suffix}, its parts
+	// before and after the first "_" (the name and "value" without one).
+	// The defaults are "{name}" and "value". The other labels, except
+	// DropLabels, become tags.
+	Measurement string   `json:"measurement,omitempty"`
+	Field       string   `json:"field,omitempty"`
+	DropLabels  []string `json:"dropLabels,omitempty"`
+	// QueueSize is how many requests are buffered (default 100).
+	QueueSize int `json:"queueSize,omitempty"`
+	// MaxRetries bounds the retries of a failed write (default 5, -1: none).
+	MaxRetries int `json:"maxRetries,omitempty"`
+}
+
+type RemoteWriteFile struct {
+	Targets []RemoteWriteTarget `json:"targets"`
+}
+
+// LoadRemoteWriteFile reads a JSON remote write targets file.
+func LoadRemoteWriteFile(path string) ([]RemoteWriteTarget, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading remote write file: %w", err)
+	}
+	var f RemoteWriteFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parsing remote write file: %w", err)
+	}
+	return f.Targets, nil
+}
+
+// remoteWriteReceiver decodes remote write requests and queues them for its
+// targets.
+type remoteWriteReceiver struct {
+	qr      *queryRunner
+	envs    map[string]*proxyEnv
+	targets []*remoteWriteSender
+	queueMu sync.Mutex // makes queueing for all targets atomic
+
+	mu              sync.Mutex
+	requests        int64
+	samples         int64
+	invalidRequests int64
+}
+
+// remoteWriteBatch is one received request.
+type remoteWriteBatch struct {
+	r       *http.Request // for the sub-requests, with a background context
+	body    []byte        // the snappy-compressed WriteRequest
+	series  []promSeries
+	samples int
+}
+
+// remoteWriteSender writes the batches queued for one target.
+type remoteWriteSender struct {
+	cfg     RemoteWriteTarget
+	typ     string
+	path    string
+	queue   chan *remoteWriteBatch
+	backoff time.Duration // first retry delay
+
+	mu    sync.Mutex
+	stats remoteWriteStats
+}
+
+// remoteWriteStats are a target's counters.
+type remoteWriteStats struct {
+	Connection    string     `json:"connection"`
+	Protocol      string     `json:"protocol"`
+	Path          string     `json:"path"`
+	Queued        int        `json:"queued"`
+	QueueSize     int        `json:"queueSize"`
+	Requests      int64      `json:"requests"` // written
+	Samples       int64      `json:"samples"`
+	Retries       int64      `json:"retries"`
+	Failed        int64      `json:"failed"` // requests dropped after errors
+	FailedSamples int64      `json:"failedSamples"`
+	Rejected      int64      `json:"rejected"` // requests refused with a full queue
+	LastError     string     `json:"lastError,omitempty"`
+	LastErrorAt   *time.Time `json:"lastErrorAt,omitempty"`
+	LastWriteAt   *time.Time `json:"lastWriteAt,omitempty"`
+}
+
+// newRemoteWriteReceiver checks targets against the server-side connections
+// and starts a sender for each.
+func newRemoteWriteReceiver(qr *queryRunner, envs map[string]*proxyEnv, targets []RemoteWriteTarget) (*remoteWriteReceiver, error) {
+	rw := &remoteWriteReceiver{qr: qr, envs: envs}
+	for i, t := range targets {
+		c := qr.conns.snapshot().find(t.Connection, "")
+		if c == nil {
+			return nil, fmt.Errorf("target %d: unknown connection %q", i+1, t.Connection)
+		}
+		t.Connection = c.Name
+		influx := strings.HasPrefix(c.Type, "influxdb")
+		if t.Protocol == "" {
+			t.Protocol = "remote_write"
+			if influx {
+				t.Protocol = "line"
+			}
+		}
+		switch {
+		case t.Protocol != "remote_write" && t.Protocol != "import" && t.Protocol != "line":
+			return nil, fmt.Errorf("target %d: protocol must be remote_write, import or line", i+1)
+		case t.Protocol == "remote_write" && c.Type != "prometheus" && c.Type != "victoriametrics":
+			return nil, fmt.Errorf("target %d: remote_write needs a prometheus or victoriametrics connection", i+1)
+		case t.Protocol == "import" && c.Type != "victoriametrics":
+			return nil, fmt.Errorf("target %d: import needs a victoriametrics connection", i+1)
+		case t.Protocol == "line" && !influx && c.Type != "victoriametrics":
+			return nil, fmt.Errorf("target %d: line needs an InfluxDB or victoriametrics connection", i+1)
+		}
+		if t.Database == "" {
+			t.Database = c.DefaultDatabase
+		}
+		if t.Protocol == "line" && influx && t.Database == "" {
+			return nil, fmt.Errorf("target %d: database is required", i+1)
+		}
+		if t.QueueSize <= 0 {
+			t.QueueSize = defaultRemoteWriteQueue
+		}
+		if t.MaxRetries == 0 {
+			t.MaxRetries = defaultRemoteWriteRetries
+		}
+		s := &remoteWriteSender{cfg: t, typ: c.Type, path: t.Path, queue: make(chan *remoteWriteBatch, t.QueueSize), backoff: time.Second}
+		if s.path == "" {
+			s.path = map[string]string{"remote_write": "/api/v1/write", "import": "/api/v1/import", "line": "/write"}[t.Protocol]
+		}
+		s.stats = remoteWriteStats{Connection: c.Name, Protocol: t.Protocol, Path: s.path, QueueSize: t.QueueSize}
+		rw.targets = append(rw.targets, s)
+	}
+	for _, s := range rw.targets {
+		go s.run(qr)
+	}
+	return rw, nil
+}
+
+// handler serves POST /api/v1/write. Bodies are bounded by maxRequestSize,
+// or by maxRemoteWriteSize without one.
+func (rw *remoteWriteReceiver) handler(maxRequestSize int64) http.HandlerFunc {
+	if maxRequestSize <= 0 {
+		maxRequestSize = maxRemoteWriteSize
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		if strings.Contains(r.Header.Get("Content-Type"), "io.prometheus.write.v2") {
+			jsonError(w, http.StatusUnsupportedMediaType, "Remote write 2.0 is not supported")
+			return
+		}
+		if enc := r.Header.Get("Content-Encoding"); enc != "" && enc != "snappy" {
+			jsonError(w, http.StatusUnsupportedMediaType, "Unsupported content encoding "+enc)
+			return
+		}
+		// Reject before reading the body when a target would refuse the write.
+		snap := rw.qr.conns.snapshot()
+		for _, s := range rw.targets {
+			c := snap.find(s.cfg.Connection, s.typ)
+			if c == nil {
+				c = &CLIConnection{Name: s.cfg.Connection, Type: s.typ}
+			}
+			if err := rw.envs[s.typ].authorizeWrite(r, c, s.path); err != nil {
+				jsonError(w, httpErrorStatus(err, http.StatusForbidden), err.Error())
+				return
+			}
+		}
+
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+		if err != nil {
+			jsonError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+			return
+		}
+		series, err := decodeWriteRequest(data, maxRemoteWriteSize)
+		if err != nil {
+			rw.mu.Lock()
+			rw.invalidRequests++
+			rw.mu.Unlock()
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		b := &remoteWriteBatch{body: data, series: series}
+		for _, s := range series {
+			b.samples += len(s.Values)
+		}
+		b.r = r.Clone(context.Background())
+		b.r.Body, b.r.ContentLength = http.NoBody, 0
+
+		rw.mu.Lock()
+		rw.requests++
+		rw.samples += int64(b.samples)
+		rw.mu.Unlock()
+		if !rw.enqueue(b) {
+			jsonError(w, http.StatusServiceUnavailable, "A remote write queue is full")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// statsHandler serves the counters as JSON, or with metrics set in the
+// Prometheus text exposition format.
+func (rw *remoteWriteReceiver) statsHandler(metrics bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setCORS(w)
+		if r.Method != http.MethodGet {
+			jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		rw.mu.Lock()
+		requests, samples, invalid := rw.requests, rw.samples, rw.invalidRequests
+		rw.mu.Unlock()
+		targets := make([]remoteWriteStats, len(rw.targets))
+		for i, s := range rw.targets {
+			s.mu.Lock()
+			targets[i] = s.stats
+			s.mu.Unlock()
+			targets[i].Queued = len(s.queue)
+		}
+		if !metrics {
+			writeJSON(w, http.StatusOK, map[string]interface{}{
+				"requests":        requests,
+				"samples":         samples,
+				"invalidRequests": invalid,
+				"targets":         targets,
+			})
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		const prefix = "timeseriesui_remote_write_"
+		fmt.Fprintf(w, "# TYPE %sreceived_requests_total counter\n%[1]sreceived_requests_total %d\n", prefix, requests)
+		fmt.Fprintf(w, "# TYPE %sreceived_samples_total counter\n%[1]sreceived_samples_total %d\n", prefix, samples)
+		fmt.Fprintf(w, "# TYPE %sinvalid_requests_total counter\n%[1]sinvalid_requests_total %d\n", prefix, invalid)
+		for _, m := range []struct {
+			name, typ string
+			value     func(*remoteWriteStats) int64
+		}{
+			{"sent_requests_total", "counter", func(s *remoteWriteStats) int64 { return s.Requests }},
+			{"sent_samples_total", "counter", func(s *remoteWriteStats) int64 { return s.Samples }},
+			{"retries_total", "counter", func(s *remoteWriteStats) int64 { return s.Retries }},
+			{"failed_requests_total", "counter", func(s *remoteWriteStats) int64 { return s.Failed }},
+			{"failed_samples_total", "counter", func(s *remoteWriteStats) int64 { return s.FailedSamples }},
+			{"rejected_requests_total", "counter", func(s *remoteWriteStats) int64 { return s.Rejected }},
+			{"queue_length", "gauge", func(s *remoteWriteStats) int64 { return int64(s.Queued) }},
+			{"queue_capacity", "gauge", func(s *remoteWriteStats) int64 { return int64(s.QueueSize) }},
+		} {
+			fmt.Fprintf(w, "# TYPE %s%s %s\n", prefix, m.name, m.typ)
+			for i := range targets {
+				fmt.Fprintf(w, "%s%s{connection=%q,protocol=%q,path=%q} %d\n", prefix, m.name, targets[i].Connection, targets[i].Protocol, targets[i].Path, m.value(&targets[i]))
+			}
+		}
+	}
+}
+
+// enqueue queues b for every target, or for none when a queue is full, so
+// the client's retry does not write it twice to the others. Senders only
+// take from the queues, so room found under queueMu is still there.
+func (rw *remoteWriteReceiver) enqueue(b *remoteWriteBatch) bool {
+	rw.queueMu.Lock()
+	defer rw.queueMu.Unlock()
+	full := false
+	for _, s := range rw.targets {
+		if len(s.queue) == cap(s.queue) {
+			s.mu.Lock()
+			s.stats.Rejected++
+			s.mu.Unlock()
+			full = true
+		}
+	}
+	if full {
+		return false
+	}
+	for _, s := range rw.targets {
+		s.queue <- b
+	}
+	return true
+}
+
+// run writes queued batches in order, retrying each before the next.
+func (s *remoteWriteSender) run(qr *queryRunner) {
+	for b := range s.queue {
+		backoff := s.backoff
+		for attempt := 0; ; attempt++ {
+			err := s.write(qr, b)
+			now := time.Now().UTC()
+			s.mu.Lock()
+			if err == nil {
+				s.stats.Requests++
+				s.stats.Samples += int64(b.samples)
+				s.stats.LastWriteAt = &now
+				s.mu.Unlock()
+				break
+			}
+			s.stats.LastError, s.stats.LastErrorAt = err.Error(), &now
+			if !retryable(err) || attempt >= s.cfg.MaxRetries {
+				s.stats.Failed++
+				s.stats.FailedSamples += int64(b.samples)
+				s.mu.Unlock()
+				break
+			}
+			s.stats.Retries++
+			s.mu.Unlock()
+			time.Sleep(backoff)
+			if backoff *= 2; backoff > maxRemoteWriteBackoff {
+				backoff = maxRemoteWriteBackoff
+			}
+		}
+	}
+}
+
+// retryable reports whether a failed write may succeed later: anything but
+// a 4xx response other than 429.
+func retryable(err error) bool {
+	status := httpErrorStatus(err, 0)
+	return status == 0 || status >= 500 || status == http.StatusTooManyRequests
+}
+
+// write sends b to the target in its protocol.
+func (s *remoteWriteSender) write(qr *queryRunner, b *remoteWriteBatch) error {
+	target, _, err := qr.resolve(&queryRequest{Connection: s.cfg.Connection, Type: s.typ})
+	if err != nil {
+		return err
+	}
+	params := url.Values{"target": {target}, "path": {s.path}}
+	header := http.Header{}
+	var body []byte
+	switch s.cfg.Protocol {
+	case "remote_write":
+		header.Set("Content-Type", remoteWriteContentType)
+		header.Set("Content-Encoding", "snappy")
+		header.Set("X-Prometheus-Remote-Write-Version", remoteWriteVersion)
+		body = b.body
+	case "import":
+		header.Set("Content-Type", "application/json")
+		body = importLines(b.series)
+	case "line":
+		header.Set("Content-Type", "text/plain; charset=utf-8")
+		if s.cfg.Database != "" {
+			params.Set("db", s.cfg.Database)
+		}
+		params.Set("precision", "ms")
+		body = s.lines(b.series)
+	}
+	if len(body) == 0 {
+		return nil
+	}
+	_, err = qr.send(b.r, http.MethodPost, s.typ, params, header, body)
+	return err
+}
+
+// importLines encodes series as VictoriaMetrics JSON lines. Samples that
+// JSON cannot represent (NaN, including staleness markers, and ±Inf) are
+// left out.
+func importLines(series []promSeries) []byte {
+	var out []byte
+	for _, ps := range series {
+		var values []float64
+		var ts []int64
+		for i, v := range ps.Values {
+			if !math.IsNaN(v) && !math.IsInf(v, 0) {
+				values = append(values, v)
+				ts = append(ts, ps.Timestamps[i])
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+		line, _ := json.Marshal(map[string]interface{}{"metric": ps.Labels, "values": values, "timestamps": ts})
+		out = append(append(out, line...), '\n')
+	}
+	return out
+}
+
+// lines encodes series as line protocol with millisecond timestamps.
+// Line protocol has no NaN or ±Inf, so those samples are left out.
+func (s *remoteWriteSender) lines(series []promSeries) []byte {
+	mTmpl, fTmpl := s.cfg.Measurement, s.cfg.Field
+	if mTmpl == "" {
+		mTmpl = "{name}"
+	}
+	if fTmpl == "" {
+		fTmpl = "value"
+	}
+	var out []byte
+	for _, ps := range series {
+		name := ps.Labels["__name__"]
+		prefix, suffix, ok := strings.Cut(name, "_")
+		if !ok {
+			prefix, suffix = name, "value"
+		}
+		rep := strings.NewReplacer("{name}", name, "{prefix}", prefix, "{suffix}", suffix)
+		measurement, field := rep.Replace(mTmpl), rep.Replace(fTmpl)
+		var keys []string
+		for k := range ps.Labels {
+			if k != "__name__" && !containsString(s.cfg.DropLabels, k) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for i, v := range ps.Values {
+			if !math.IsNaN(v) && !math.IsInf(v, 0) {
+				out = appendLine(out, measurement, keys, ps.Labels, field, v, ps.Timestamps[i])
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,313 @@
+package timeseriesui
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ── Prometheus remote write ─────────────────────────────────────────────────
+//
+// Remote write bodies are a protobuf WriteRequest compressed with the snappy
+// block format. Both are small enough to encode and decode by hand:
+//
+//	WriteRequest { repeated TimeSeries timeseries = 1; }
+//	TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	Label        { string name = 1; string value = 2; }
+//	Sample       { double value = 1; int64 timestamp = 2; }  // milliseconds
+
+// promSeries is one series of samples. Labels include __name__.
+type promSeries struct {
+	Labels     map[string]string
+	Values     []float64
+	Timestamps []int64 // Unix milliseconds
+}
+
+// Remote write request headers.
+const (
+	remoteWriteContentType = "application/x-protobuf"
+	remoteWriteVersion     = "0.1.0"
+)
+
+// encodeWriteRequest returns the snappy-compressed WriteRequest for series.
+// Labels are sorted by name, as receivers expect.
+func encodeWriteRequest(series []promSeries) []byte {
+	var req []byte
+	for _, s := range series {
+		names := make([]string, 0, len(s.Labels))
+		for k := range s.Labels {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		var ts []byte
+		for _, k := range names {
+			var l []byte
+			l = appendProtoString(l, 1, k)
+			l = appendProtoString(l, 2, s.Labels[k])
+			ts = appendProtoBytes(ts, 1, l)
+		}
+		for i, v := range s.Values {
+			var smp []byte
+			smp = appendProtoTag(smp, 1, 1)
+			smp = binary.LittleEndian.AppendUint64(smp, math.Float64bits(v))
+			smp = appendProtoTag(smp, 2, 0)
+			smp = binary.AppendUvarint(smp, uint64(s.Timestamps[i]))
+			ts = appendProtoBytes(ts, 2, smp)
+		}
+		req = appendProtoBytes(req, 1, ts)
+	}
+	return snappyEncode(req)
+}
+
+// decodeWriteRequest decodes a snappy-compressed WriteRequest of at most
+// maxSize uncompressed bytes. Fields other than labels and float samples
+// (metadata, exemplars, native histograms) are skipped.
+func decodeWriteRequest(body []byte, maxSize int) ([]promSeries, error) {
+	data, err := snappyDecode(body, maxSize)
+	if err != nil {
+		return nil, fmt.Errorf("invalid snappy data: %w", err)
+	}
+	var series []promSeries
+	err = walkProto(data, func(field int, v []byte, _ uint64) error {
+		if field != 1 || v == nil {
+			return nil
+		}
+		s := promSeries{Labels: map[string]string{}}
+		err := walkProto(v, func(field int, v []byte, _ uint64) error {
+			switch {
+			case field == 1 && v != nil:
+				var name, value string
+				err := walkProto(v, func(field int, v []byte, _ uint64) error {
+					switch field {
+					case 1:
+						name = string(v)
+					case 2:
+						value = string(v)
+					}
+					return nil
+				})
+				s.Labels[name] = value
+				return err
+			case field == 2 && v != nil:
+				var value float64
+				var ts int64
+				err := walkProto(v, func(field int, _ []byte, n uint64) error {
+					switch field {
+					case 1:
+						value = math.Float64frombits(n)
+					case 2:
+						ts = int64(n)
+					}
+					return nil
+				})
+				s.Values = append(s.Values, value)
+				s.Timestamps = append(s.Timestamps, ts)
+				return err
+			}
+			return nil
+		})
+		series = append(series, s)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid WriteRequest: %w", err)
+	}
+	return series, nil
+}
+
+var errProtoTruncated = errors.New("truncated message")
+
+// walkProto calls fn for each field of a protobuf message: with the bytes
+// of length-delimited fields, or else with the numeric value.
+func walkProto(b []byte, fn func(field int, v []byte, n uint64) error) error {
+	for len(b) > 0 {
+		tag, k := binary.Uvarint(b)
+		if k <= 0 {
+			return errProtoTruncated
+		}
+		b = b[k:]
+		field := int(tag >> 3)
+		var v []byte
+		var n uint64
+		switch tag & 7 {
+		case 0:
+			if n, k = binary.Uvarint(b); k <= 0 {
+				return errProtoTruncated
+			}
+			b = b[k:]
+		case 1:
+			if len(b) < 8 {
+				return errProtoTruncated
+			}
+			n, b = binary.LittleEndian.Uint64(b), b[8:]
+		case 2:
+			l, k := binary.Uvarint(b)
+			if k <= 0 || uint64(len(b)-k) < l {
+				return errProtoTruncated
+			}
+			v, b = b[k:k+int(l)], b[k+int(l):]
+			if v == nil {
+				v = []byte{}
+			}
+		case 5:
+			if len(b) < 4 {
+				return errProtoTruncated
+			}
+			n, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		default:
+			return fmt.Errorf("unsupported wire type %d", tag&7)
+		}
+		if err := fn(field, v, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendProtoTag(b []byte, field int, wireType int) []byte {
+	return binary.AppendUvarint(b, uint64(field<<3|wireType))
+}
+
+func appendProtoBytes(b []byte, field int, v []byte) []byte {
+	b = appendProtoTag(b, field, 2)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func appendProtoString(b []byte, field int, v string) []byte {
+	b = appendProtoTag(b, field, 2)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// ── Snappy block format ─────────────────────────────────────────────────────
+
+// snappyEncode compresses src as a single snappy block: the uncompressed
+// length, then literals and copies found through a hash table of 4-byte
+// sequences.
+func snappyEncode(src []byte) []byte {
+	dst := binary.AppendUvarint(nil, uint64(len(src)))
+	var table [1 << 14]int32 // position+1 of the last sequence with each hash
+	hash := func(u uint32) uint32 { return (u * 0x1e35a7bd) >> 18 }
+
+	lit := 0 // start of the pending literal
+	for i := 0; i+4 <= len(src); {
+		u := binary.LittleEndian.Uint32(src[i:])
+		h := hash(u)
+		cand := int(table[h]) - 1
+		table[h] = int32(i + 1)
+		if cand < 0 || i-cand > 0xffff || binary.LittleEndian.Uint32(src[cand:]) != u {
+			i++
+			continue
+		}
+		dst = snappyLiteral(dst, src[lit:i])
+		n := 4
+		for i+n < len(src) && src[cand+n] == src[i+n] {
+			n++
+		}
+		for off, rest := i-cand, n; rest > 0; {
+			m := rest
+			if m > 64 {
+				m = 64
+			}
+			dst = append(dst, byte(m-1)<<2|2, byte(off), byte(off>>8))
+			rest -= m
+		}
+		i += n
+		lit = i
+	}
+	return snappyLiteral(dst, src[lit:])
+}
+
+// snappyLiteral appends lit as a literal element.
+func snappyLiteral(dst, lit []byte) []byte {
+	n := len(lit) - 1
+	switch {
+	case n < 0:
+		return dst
+	case n < 60:
+		dst = append(dst, byte(n)<<2)
+	case n < 1<<8:
+		dst = append(dst, 60<<2, byte(n))
+	case n < 1<<16:
+		dst = append(dst, 61<<2, byte(n), byte(n>>8))
+	case n < 1<<24:
+		dst = append(dst, 62<<2, byte(n), byte(n>>8), byte(n>>16))
+	default:
+		dst = append(dst, 63<<2, byte(n), byte(n>>8), byte(n>>16), byte(n>>24))
+	}
+	return append(dst, lit...)
+}
+
+// snappyDecode decompresses a snappy block of at most maxSize bytes.
+func snappyDecode(src []byte, maxSize int) ([]byte, error) {
+	n, k := binary.Uvarint(src)
+	if k <= 0 {
+		return nil, errors.New("bad length")
+	}
+	if n > uint64(maxSize) {
+		return nil, fmt.Errorf("decoded size %d exceeds %d bytes", n, maxSize)
+	}
+	dst := make([]byte, 0, n)
+	src = src[k:]
+	for len(src) > 0 {
+		tag := src[0]
+		src = src[1:]
+		var length, offset int
+		switch tag & 3 {
+		case 0:
+			length = int(tag >> 2)
+			if length >= 60 {
+				nb := length - 59
+				if len(src) < nb {
+					return nil, errors.New("truncated literal")
+				}
+				length = 0
+				for i := nb - 1; i >= 0; i-- {
+					length = length<<8 | int(src[i])
+				}
+				src = src[nb:]
+			}
+			length++
+			if length > len(src) || len(dst)+length > int(n) {
+				return nil, errors.New("literal out of bounds")
+			}
+			dst = append(dst, src[:length]...)
+			src = src[length:]
+			continue
+		case 1:
+			if len(src) < 1 {
+				return nil, errors.New("truncated copy")
+			}
+			length = int(tag>>2&7) + 4
+			offset = int(tag>>5)<<8 | int(src[0])
+			src = src[1:]
+		case 2:
+			if len(src) < 2 {
+				return nil, errors.New("truncated copy")
+			}
+			length = int(tag>>2) + 1
+			offset = int(binary.LittleEndian.Uint16(src))
+			src = src[2:]
+		case 3:
+			if len(src) < 4 {
+				return nil, errors.New("truncated copy")
+			}
+			length = int(tag>>2) + 1
+			offset = int(binary.LittleEndian.Uint32(src))
+			src = src[4:]
+		}
+		if offset <= 0 || offset > len(dst) || len(dst)+length > int(n) {
+			return nil, errors.New("copy out of bounds")
+		}
+		for i := 0; i < length; i++ {
+			dst = append(dst, dst[len(dst)-offset])
+		}
+	}
+	if len(dst) != int(n) {
+		return nil, errors.New("length mismatch")
+	}
+	return dst, nil
+}
@@ -0,0 +1,120 @@
+package timeseriesui
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSnappyRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	random := make([]byte, 100<<10)
+	rnd.Read(random)
+	mixed := append(append([]byte{}, random[:65000]...), random[:65000]...) // copies at offsets near 0xffff
+
+	cases := map[string][]byte{
+		"empty":        {},
+		"short":        []byte("abc"),
+		"literal":      []byte("the quick brown fox jumps over the lazy dog"),
+		"long copy":    bytes.Repeat([]byte("abcd"), 100),
+		"run":          bytes.Repeat([]byte{'x'}, 1000),
+		"random":       random,
+		"mixed":        mixed,
+		"long literal": append([]byte(strings.Repeat("0123456789", 30)), random[:300]...),
+	}
+	for name, src := range cases {
+		enc := snappyEncode(src)
+		dec, err := snappyDecode(enc, len(src))
+		if err != nil {
+			t.Errorf("%s: decode: %v", name, err)
+			continue
+		}
+		if !bytes.Equal(dec, src) {
+			t.Errorf("%s: round trip changed the data", name)
+		}
+	}
+}
+
+func TestSnappyDecodeReference(t *testing.T) {
+	// "abcd" as a literal, then an 8-byte copy at offset 4, encoded with a
+	// 1-byte-offset copy and with a 2-byte-offset copy.
+	for _, src := range [][]byte{
+		{12, 3 << 2, 'a', 'b', 'c', 'd', 4<<2 | 1, 4},
+		{12, 3 << 2, 'a', 'b', 'c', 'd', 7<<2 | 2, 4, 0},
+	} {
+		dec, err := snappyDecode(src, 64)
+		if err != nil {
+			t.Fatalf("decode %v: %v", src, err)
+		}
+		if string(dec) != "abcdabcdabcd" {
+			t.Errorf("decode %v = %q", src, dec)
+		}
+	}
+}
+
+func TestSnappyDecodeErrors(t *testing.T) {
+	src := bytes.Repeat([]byte("timeseries "), 50)
+	enc := snappyEncode(src)
+	for i := 0; i < len(enc); i++ {
+		if _, err := snappyDecode(enc[:i], len(src)); err == nil {
+			t.Errorf("truncated to %d of %d bytes: no error", i, len(enc))
+		}
+	}
+	if _, err := snappyDecode(enc, len(src)-1); err == nil {
+		t.Error("decoded size over maxSize: no error")
+	}
+	if _, err := snappyDecode([]byte{8, 0, 'a', 7<<2 | 2, 2, 0}, 64); err == nil {
+		t.Error("copy before the start: no error")
+	}
+}
+
+func TestWriteRequestRoundTrip(t *testing.T) {
+	series := []promSeries{
+		{
+			Labels:     map[string]string{"__name__": "up", "job": "node", "instance": "localhost:9100"},
+			Values:     []float64{1, 0, 0.5, math.Inf(1)},
+			Timestamps: []int64{1700000000000, 1700000015000, 1700000030000, 1700000045000},
+		},
+		{
+			Labels:     map[string]string{"__name__": "empty_label", "le": ""},
+			Values:     []float64{-2.5},
+			Timestamps: []int64{-1000},
+		},
+		{
+			Labels: map[string]string{"__name__": "no_samples"},
+		},
+	}
+	got, err := decodeWriteRequest(encodeWriteRequest(series), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, series) {
+		t.Errorf("round trip:\n got %+v\nwant %+v", got, series)
+	}
+}
+
+func TestWriteRequestDecodeErrors(t *testing.T) {
+	body := encodeWriteRequest([]promSeries{{
+		Labels:     map[string]string{"__name__": "up"},
+		Values:     []float64{1},
+		Timestamps: []int64{1700000000000},
+	}})
+	data, err := snappyDecode(body, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(data); i++ {
+		if _, err := decodeWriteRequest(snappyEncode(data[:i]), 1<<20); err == nil {
+			t.Errorf("message truncated to %d of %d bytes: no error", i, len(data))
+		}
+	}
+	if _, err := decodeWriteRequest([]byte("not snappy"), 1<<20); err == nil {
+		t.Error("invalid snappy data: no error")
+	}
+	if _, err := decodeWriteRequest(body, 4); err == nil {
+		t.Error("message over maxSize: no error")
+	}
+}
@@ -0,0 +1,263 @@
+package timeseriesui
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ── Saved-query library ─────────────────────────────────────────────────────
+//
+// Saved queries are shared by every user of the instance and persisted to
+// saved-queries.json in the data directory:
+//
+//	GET    /api/v1/saved-queries?q=&tag=&team=&type=&connection=
+//	POST   /api/v1/saved-queries
+//	GET    /api/v1/saved-queries/<id>
+//	PUT    /api/v1/saved-queries/<id>    (If-Match: "<version>" or "version")
+//	DELETE /api/v1/saved-queries/<id>
+
+// savedQuery is one entry of the library. It targets either a connection
+// (by name or URL) or any connection of a backend type.
+type savedQuery struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	Team        string    `json:"team,omitempty"`
+	Connection  string    `json:"connection,omitempty"`
+	Type        string    `json:"type,omitempty"`
+	Language    string    `json:"language,omitempty"`
+	Query       string    `json:"query"`
+	Version     int       `json:"version"`
+	CreatedBy   string    `json:"createdBy,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedBy   string    `json:"updatedBy,omitempty"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// validate checks the fields a client may set.
+func (q *savedQuery) validate() error {
+	q.Name = strings.TrimSpace(q.Name)
+	switch {
+	case q.Name == "":
+		return fmt.Errorf("name is required")
+	case strings.TrimSpace(q.Query) == "":
+		return fmt.Errorf("query is required")
+	case q.Connection == "" && q.Type == "":
+		return fmt.Errorf("connection or type is required")
+	}
+	if q.Type != "" {
+		if _, ok := lookupBackend(q.Type); !ok {
+			return fmt.Errorf("unknown type %q", q.Type)
+		}
+	}
+	tags := q.Tags[:0]
+	for _, t := range q.Tags {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	q.Tags = tags
+	return nil
+}
+
+// matches reports whether q passes the list filters. search is lower-case
+// and matched against the name, description, query and tags.
+func (q *savedQuery) matches(search, tag, team, typ, connection string) bool {
+	switch {
+	case tag != "" && !containsFold(q.Tags, tag),
+		team != "" && !strings.EqualFold(q.Team, team),
+		typ != "" && q.Type != typ,
+		connection != "" && q.Connection != connection:
+		return false
+	}
+	if search == "" {
+		return true
+	}
+	text := strings.ToLower(q.Name + "\n" + q.Description + "\n" + q.Query + "\n" + strings.Join(q.Tags, "\n"))
+	return strings.Contains(text, search)
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// savedQueryStore holds the library in memory and, with a path, on disk.
+type savedQueryStore struct {
+	mu      sync.Mutex
+	queries map[string]*savedQuery
+	path    string
+}
+
+func newSavedQueryStore(dir string) (*savedQueryStore, error) {
+	s := &savedQueryStore{queries: map[string]*savedQuery{}}
+	if dir == "" {
+		return s, nil
+	}
+	s.path = filepath.Join(dir, "saved-queries.json")
+	var list []*savedQuery
+	if err := loadJSONFile(s.path, &list); err != nil {
+		return nil, err
+	}
+	for _, q := range list {
+		s.queries[q.ID] = q
+	}
+	return s, nil
+}
+
+// save persists the library; the caller holds mu.
+func (s *savedQueryStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	return saveJSONFile(s.path, s.sorted())
+}
+
+// sorted returns the queries by name; the caller holds mu.
+func (s *savedQueryStore) sorted() []*savedQuery {
+	list := make([]*savedQuery, 0, len(s.queries))
+	for _, q := range s.queries {
+		list = append(list, q)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !strings.EqualFold(list[i].Name, list[j].Name) {
+			return strings.ToLower(list[i].Name) < strings.ToLower(list[j].Name)
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// handler serves the saved-query API. user may be nil.
+func (s *savedQueryStore) handler(prefix string, user func(*http.Request) string) http.HandlerFunc {
+	userOf := func(r *http.Request) string {
+		if user == nil {
+			return ""
+		}
+		return user(r)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		setCORS(w)
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		id := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+		u := userOf(r)
+		serveLocked(&s.mu, w, r, func(w http.ResponseWriter, r *http.Request) {
+			s.serve(w, r, id, u)
+		})
+	}
+}
+
+// serve handles a saved-query API request; the caller holds mu.
+func (s *savedQueryStore) serve(w http.ResponseWriter, r *http.Request, id, user string) {
+	if id == "" {
+		switch r.Method {
+		case http.MethodGet:
+			p := r.URL.Query()
+			search := strings.ToLower(p.Get("q"))
+			out := []*savedQuery{}
+			for _, q := range s.sorted() {
+				if q.matches(search, p.Get("tag"), p.Get("team"), p.Get("type"), p.Get("connection")) {
+					out = append(out, q)
+				}
+			}
+			writeJSON(w, http.StatusOK, out)
+		case http.MethodPost:
+			var q savedQuery
+			if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+				jsonError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+				return
+			}
+			if err := q.validate(); err != nil {
+				jsonError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			now := time.Now().UTC()
+			q.ID, q.Version = newID(), 1
+			q.CreatedBy, q.CreatedAt = user, now
+			q.UpdatedBy, q.UpdatedAt = q.CreatedBy, now
+			s.queries[q.ID] = &q
+			if err := s.save(); err != nil {
+				delete(s.queries, q.ID)
+				jsonError(w, http.StatusInternalServerError, "Failed to save: "+err.Error())
+				return
+			}
+			w.Header().Set("ETag", versionETag(q.Version))
+			writeJSON(w, http.StatusCreated, &q)
+		default:
+			jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+		return
+	}
+
+	cur, ok := s.queries[id]
+	if !ok {
+		jsonError(w, http.StatusNotFound, "Saved query not found")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("ETag", versionETag(cur.Version))
+		writeJSON(w, http.StatusOK, cur)
+	case http.MethodPut:
+		var q savedQuery
+		if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+			jsonError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+		switch v := expectedVersion(r, q.Version); {
+		case v == 0:
+			jsonError(w, http.StatusPreconditionRequired, "Send the version being updated as If-Match or in the body")
+			return
+		case v != cur.Version:
+			w.Header().Set("ETag", versionETag(cur.Version))
+			writeJSON(w, http.StatusConflict, map[string]interface{}{
+				"error":   fmt.Sprintf("Saved query was modified (version %d, expected %d)", cur.Version, v),
+				"current": cur,
+			})
+			return
+		}
+		if err := q.validate(); err != nil {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		q.ID, q.Version = cur.ID, cur.Version+1
+		q.CreatedBy, q.CreatedAt = cur.CreatedBy, cur.CreatedAt
+		q.UpdatedBy, q.UpdatedAt = user, time.Now().UTC()
+		s.queries[id] = &q
+		if err := s.save(); err != nil {
+			s.queries[id] = cur
+			jsonError(w, http.StatusInternalServerError, "Failed to save: "+err.Error())
+			return
+		}
+		w.Header().Set("ETag", versionETag(q.Version))
+		writeJSON(w, http.StatusOK, &q)
+	case http.MethodDelete:
+		if v := expectedVersion(r, 0); v != 0 && v != cur.Version {
+			jsonError(w, http.StatusConflict, fmt.Sprintf("Saved query was modified (version %d, expected %d)", cur.Version, v))
+			return
+		}
+		delete(s.queries, id)
+		if err := s.save(); err != nil {
+			s.queries[id] = cur
+			jsonError(w, http.StatusInternalServerError, "Failed to save: "+err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
@@ -0,0 +1,41 @@
+package timeseriesui
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSavedQueryVersions(t *testing.T) {
+	h := newTestHandler(t, Options{})
+	rec := serve(h, http.MethodPost, "/api/v1/saved-queries", strings.NewReader(`{"name": "up", "type": "prometheus", "query": "up"}`), nil)
+	var q struct {
+		ID      string
+		Version int
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &q); err != nil || rec.Code != http.StatusCreated {
+		t.Fatalf("create: status %d: %s", rec.Code, rec.Body)
+	}
+	path := "/api/v1/saved-queries/" + q.ID
+	update := `{"name": "up", "type": "prometheus", "query": "up == 1"}`
+	if rec := serve(h, http.MethodPut, path, strings.NewReader(update), map[string]string{"If-Match": versionETag(q.Version)}); rec.Code != http.StatusOK {
+		t.Fatalf("update: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(h, http.MethodPut, path, strings.NewReader(update), map[string]string{"If-Match": versionETag(q.Version)}); rec.Code != http.StatusConflict {
+		t.Errorf("stale update: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(h, http.MethodGet, path, nil, nil); rec.Header().Get("ETag") != versionETag(q.Version+1) {
+		t.Errorf("ETag %q after one update of version %d", rec.Header().Get("ETag"), q.Version)
+	}
+}
+
+func TestStoreRequestSize(t *testing.T) {
+	h := newTestHandler(t, Options{})
+	body := `{"name": "big", "type": "prometheus", "query": "` + strings.Repeat("x", maxStoreRequestSize) + `"}`
+	for _, target := range []string{"/api/v1/saved-queries", "/api/v1/dashboards"} {
+		if rec := serve(h, http.MethodPost, target, strings.NewReader(body), nil); rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s: status %d: %s", target, rec.Code, rec.Body)
+		}
+	}
+}
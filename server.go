@@ -0,0 +1,903 @@
+// Package timeseriesui serves the TimeseriesUI web interface and proxies API
+// requests to InfluxDB, Prometheus, VictoriaMetrics, Loki, Graphite, OpenTSDB
+// and Alertmanager backends.
+//
+// New returns an http.Handler that can be mounted in any Go server:
+//
+//	h, err := timeseriesui.New(timeseriesui.Options{
+//		BasePath:    "/tsui",
+//		Connections: []timeseriesui.CLIConnection{{Name: "prod", Type: "prometheus", URL: "http://prom:9090"}},
+//	})
+//	mux.Handle("/tsui/", authMiddleware(h))
+//
+// The timeseriesui command in cmd/timeseriesui is a thin wrapper around it.
+package timeseriesui
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed ui/dist
+var uiDist embed.FS
+
+// ── CLI flag types ──────────────────────────────────────────────────────────
+
+// stringSlice lets a flag be repeated: --flag a --flag b
+type stringSlice []string
+
+func (s *stringSlice) String() string { return strings.Join(*s, ", ") }
+func (s *stringSlice) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// ── Connection model ────────────────────────────────────────────────────────
+
+// CLIConnection is a server-side connection. Its credentials stay on the
+// server; the browser only sees the connection through /api/v1/connections.
+type CLIConnection struct {
+	ID                   string `json:"id,omitempty"`
+	Name                 string `json:"name"`
+	Type                 string `json:"type"` // "influxdb", "influxdb2", "influxdb3", "prometheus", "victoriametrics", "loki", "graphite", or "opentsdb"
+	URL                  string `json:"url"`
+	Username             string `json:"username,omitempty"`
+	Password             string `json:"password,omitempty"`
+	DefaultDatabase      string `json:"defaultDatabase,omitempty"`
+	Org                  string `json:"org,omitempty"`
+	Token                string `json:"token,omitempty"`
+	AlertmanagerURL      string `json:"alertmanagerUrl,omitempty"`
+	AlertmanagerUsername string `json:"alertmanagerUsername,omitempty"`
+	AlertmanagerPassword string `json:"alertmanagerPassword,omitempty"`
+	AlertmanagerCACert   string `json:"alertmanagerCaCert,omitempty"`
+	AlertmanagerInsecure bool   `json:"alertmanagerInsecureSkipVerify,omitempty"`
+	Flavor               string `json:"flavor,omitempty"` // Prometheus only: "prometheus", "mimir", "cortex" or "thanos"
+	Dedup                *bool  `json:"dedup,omitempty"`
+	PartialResponse      *bool  `json:"partialResponse,omitempty"`
+	MaxSourceResolution  string `json:"maxSourceResolution,omitempty"`
+	ProxyURL             string `json:"proxyUrl,omitempty"`
+	ClusterMode          bool   `json:"clusterMode,omitempty"`
+	TenantID             string `json:"tenantId,omitempty"`
+	VminsertURL          string `json:"vminsertUrl,omitempty"`
+	VmstorageURL         string `json:"vmstorageUrl,omitempty"`
+	Source               string `json:"source,omitempty"`  // always "cli" (server-side) in API responses
+	Managed              bool   `json:"managed,omitempty"` // editable through the connections API
+}
+
+type ConnectionsFile struct {
+	Connections []CLIConnection `json:"connections"`
+}
+
+// ── Options ─────────────────────────────────────────────────────────────────
+
+// Options configures the handler returned by New.
+type Options struct {
+	// Connections are fixed server-side connections, e.g. from CLI flags.
+	// They cannot be changed through the connections API.
+	Connections []CLIConnection
+	// ConnectionsFile holds managed connections, editable by admins through
+	// the connections API; changes are written back to it atomically. When
+	// empty, managed connections live in DataDir/connections.json.
+	ConnectionsFile string
+	// BasePath is the URL prefix the handler is mounted at, e.g. "/tsui".
+	BasePath string
+
+	// DisableWrite and DisableAdmin hide the features in the UI and reject
+	// write and admin API calls in the proxy.
+	DisableWrite bool
+	DisableAdmin bool
+
+	// ProxyTimeout bounds each proxied request (default 30s).
+	ProxyTimeout time.Duration
+	// MaxRequestSize bounds request bodies forwarded upstream (0: no limit).
+	MaxRequestSize int64
+	// MaxResponseSize bounds response bodies read from upstreams (0: no limit).
+	MaxResponseSize int64
+	// MaxUploadSize bounds files sent to the upload and CSV import APIs
+	// (0: MaxRequestSize, or 1 GiB without one).
+	MaxUploadSize int64
+	// AllowedOrigins are the browser origins, besides the server's own host,
+	// that may open WebSocket/upgrade connections through the proxy. "*"
+	// allows any origin.
+	AllowedOrigins []string
+	// UpgradeIdleTimeout closes upgraded connections after this long without
+	// traffic in either direction (default 5m).
+	UpgradeIdleTimeout time.Duration
+	// Transport sends upstream requests (default http.DefaultTransport).
+	// Connections with custom TLS settings use a clone of it when it is an
+	// *http.Transport.
+	Transport http.RoundTripper
+
+	// Authorize is called for every proxied API call after the write/admin
+	// policy has allowed it. A non-nil error rejects the call with 403.
+	Authorize func(r *http.Request, op Operation) error
+	// Audit is called after every proxied API call, allowed or not.
+	Audit func(r *http.Request, ev AuditEvent)
+
+	// DataDir is where server-side state (query history, saved queries, …)
+	// is persisted. Without it the state is kept in memory only.
+	DataDir string
+	// User identifies the user of a request for the query history and saved
+	// queries, e.g. from a header set by an authenticating proxy.
+	User func(r *http.Request) string
+	// DisableHistory turns off the server-side query history.
+	DisableHistory bool
+	// HistoryMaxEntries and HistoryRetention bound the query history
+	// (default 10000 entries and 30 days).
+	HistoryMaxEntries int
+	HistoryRetention  time.Duration
+	// JobWorkers is how many background jobs run at once (default 2).
+	JobWorkers int
+	// RemoteWriteTargets enables the remote write receiver at
+	// /api/v1/write, fanning out to these server-side connections.
+	RemoteWriteTargets []RemoteWriteTarget
+
+	// Version is reported by /api/v1/health.
+	Version string
+}
+
+// ── Flag-defined connections ────────────────────────────────────────────────
+
+// RegisterFlags adds every backend's connection flags (--prometheus-url,
+// --vm-tenant, …) to fs.
+func RegisterFlags(fs *flag.FlagSet) {
+	for _, b := range sortedBackends() {
+		b.RegisterFlags(fs)
+	}
+}
+
+// FlagConnections returns the connections described by the flags added with
+// RegisterFlags, once they have been parsed.
+func FlagConnections() []CLIConnection {
+	// Build connections in the order influxdb, prometheus, victoriametrics,
+	// then the other types alphabetically.
+	ordered := sortedBackends()
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return flagOrder(ordered[i].Type()) < flagOrder(ordered[j].Type())
+	})
+	var conns []CLIConnection
+	for _, b := range ordered {
+		conns = append(conns, b.FlagConnections()...)
+	}
+	for _, b := range ordered {
+		if f, ok := b.(connectionFinalizer); ok {
+			conns = f.FinalizeConnections(conns)
+		}
+	}
+	return conns
+}
+
+// flagOrder keeps the original three backends first when listing
+// flag-defined connections.
+func flagOrder(typ string) int {
+	switch typ {
+	case "influxdb":
+		return 0
+	case "prometheus":
+		return 1
+	case "victoriametrics":
+		return 2
+	}
+	return 3
+}
+
+func nameFromURL(rawURL, backendType string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return backendType
+	}
+	host := u.Hostname()
+	if host == "localhost" || host == "127.0.0.1" {
+		return backendType + " (local)"
+	}
+	return backendType + " (" + host + ")"
+}
+
+// ── Handler ─────────────────────────────────────────────────────────────────
+
+// New validates opts and returns the TimeseriesUI handler: the SPA under
+// <BasePath>/ui/, the playground, the /api/ endpoints and the backend
+// proxies.
+func New(opts Options) (http.Handler, error) {
+	timeout := opts.ProxyTimeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	uiFS, err := fs.Sub(uiDist, "ui/dist")
+	if err != nil {
+		return nil, fmt.Errorf("accessing embedded UI assets: %w", err)
+	}
+
+	basePath := strings.TrimRight(opts.BasePath, "/")
+
+	mux := http.NewServeMux()
+
+	// ── API: mode (standalone detection) ────────────────────────────────
+	mux.HandleFunc(basePath+"/api/mode", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		resp := map[string]interface{}{
+			"mode":         "standalone",
+			"disableWrite": opts.DisableWrite,
+			"disableAdmin": opts.DisableAdmin,
+			"history":      !opts.DisableHistory,
+		}
+		json.NewEncoder(w).Encode(resp)
+	})
+
+	// ── API: health check ──────────────────────────────────────────────
+	version := opts.Version
+	if version == "" {
+		version = "dev"
+	}
+	mux.HandleFunc(basePath+"/api/v1/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"status":  "ok",
+			"version": version,
+		})
+	})
+
+	// ── Backend proxies: /proxy/<type>/ ─────────────────────────────────
+	policy := accessPolicy{
+		DisableWrite: opts.DisableWrite,
+		DisableAdmin: opts.DisableAdmin,
+	}
+	cc := clientConfig{timeout: timeout, transport: opts.Transport, maxResponseSize: opts.MaxResponseSize}
+	if opts.DataDir != "" {
+		if err := os.MkdirAll(opts.DataDir, 0o700); err != nil {
+			return nil, fmt.Errorf("creating data directory: %w", err)
+		}
+	}
+	conns, err := newConnectionStore(opts.Connections, opts.ConnectionsFile, opts.DataDir, cc)
+	if err != nil {
+		return nil, err
+	}
+
+	// ── API: query history ──────────────────────────────────────────────
+	var history *historyStore
+	if !opts.DisableHistory {
+		history, err = newHistoryStore(opts.DataDir, opts.HistoryMaxEntries, opts.HistoryRetention)
+		if err != nil {
+			return nil, fmt.Errorf("loading query history: %w", err)
+		}
+		historyPath := basePath + "/api/v1/history"
+		mux.HandleFunc(historyPath, history.handler(historyPath, opts.User))
+		mux.HandleFunc(historyPath+"/", history.handler(historyPath, opts.User))
+	}
+
+	// ── API: saved-query library ────────────────────────────────────────
+	savedQueries, err := newSavedQueryStore(opts.DataDir)
+	if err != nil {
+		return nil, fmt.Errorf("loading saved queries: %w", err)
+	}
+	savedPath := basePath + "/api/v1/saved-queries"
+	mux.HandleFunc(savedPath, savedQueries.handler(savedPath, opts.User))
+	mux.HandleFunc(savedPath+"/", savedQueries.handler(savedPath, opts.User))
+
+	// ── API: dashboards ─────────────────────────────────────────────────
+	dashboards, err := newDashboardStore(opts.DataDir)
+	if err != nil {
+		return nil, fmt.Errorf("loading dashboards: %w", err)
+	}
+	dashboardsPath := basePath + "/api/v1/dashboards"
+	mux.HandleFunc(dashboardsPath, dashboards.handler(dashboardsPath, opts.User))
+	mux.HandleFunc(dashboardsPath+"/", dashboards.handler(dashboardsPath, opts.User))
+
+	// ── API: short links ────────────────────────────────────────────────
+	links, err := newLinkStore(opts.DataDir)
+	if err != nil {
+		return nil, fmt.Errorf("loading links: %w", err)
+	}
+	linksPath := basePath + "/api/v1/links"
+	mux.HandleFunc(linksPath, links.handler(linksPath, basePath, opts.User))
+	mux.HandleFunc(linksPath+"/", links.handler(linksPath, basePath, opts.User))
+	mux.HandleFunc(basePath+"/s/", links.redirectHandler(basePath+"/s/", basePath))
+
+	h := hooks{
+		authorize:          opts.Authorize,
+		audit:              opts.Audit,
+		user:               opts.User,
+		history:            history,
+		maxRequestSize:     opts.MaxRequestSize,
+		allowedOrigins:     opts.AllowedOrigins,
+		upgradeIdleTimeout: opts.UpgradeIdleTimeout,
+	}
+	envs := map[string]*proxyEnv{}
+	for _, b := range sortedBackends() {
+		env, err := newProxyEnv(b, conns, cc, policy, h)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s configuration: %w", b.DisplayName(), err)
+		}
+		envs[b.Type()] = env
+		mux.HandleFunc(basePath+"/proxy/"+b.Type()+"/", env.handler())
+	}
+
+	// ── API: unified query and export ───────────────────────────────────
+	queries := &queryRunner{mux: mux, basePath: basePath, conns: conns}
+	mux.HandleFunc(basePath+"/api/v1/query", queries.handler())
+	mux.HandleFunc(basePath+"/api/v1/export", queries.exportHandler())
+
+	// ── API: InfluxDB migrations ────────────────────────────────────────
+	migrations, err := newMigrationStore(opts.DataDir)
+	if err != nil {
+		return nil, fmt.Errorf("loading migrations: %w", err)
+	}
+	migrationsPath := basePath + "/api/v1/migrations"
+	mux.HandleFunc(migrationsPath, migrations.handler(migrationsPath, queries, opts.User))
+	mux.HandleFunc(migrationsPath+"/", migrations.handler(migrationsPath, queries, opts.User))
+
+	// ── API: background jobs ────────────────────────────────────────────
+	jobs, err := newJobRunner(opts.DataDir, opts.JobWorkers, opts.User)
+	if err != nil {
+		return nil, fmt.Errorf("loading jobs: %w", err)
+	}
+	jobsPath := basePath + "/api/v1/jobs"
+	jobs.register("export", exportJob(queries, jobsPath))
+	jobs.register("migration", migrationJob(queries, migrations, opts.User))
+	jobs.register("upload", uploadJob(queries))
+	mux.HandleFunc(jobsPath, jobs.handler(jobsPath))
+	mux.HandleFunc(jobsPath+"/", jobs.handler(jobsPath))
+	maxUploadSize := opts.MaxUploadSize
+	if maxUploadSize == 0 {
+		maxUploadSize = opts.MaxRequestSize
+	}
+	if maxUploadSize == 0 {
+		maxUploadSize = defaultMaxUploadSize
+	}
+	mux.HandleFunc(basePath+"/api/v1/uploads", uploadHandler(jobs, queries, envs, jobsPath, maxUploadSize))
+	mux.HandleFunc(basePath+"/api/v1/csv/preview", csvPreviewHandler(queries))
+	mux.HandleFunc(basePath+"/api/v1/csv/import", csvImportHandler(jobs, queries, envs, jobsPath, maxUploadSize))
+
+	// ── API: remote write receiver ──────────────────────────────────────
+	if len(opts.RemoteWriteTargets) > 0 {
+		receiver, err := newRemoteWriteReceiver(queries, envs, opts.RemoteWriteTargets)
+		if err != nil {
+			return nil, fmt.Errorf("invalid remote write configuration: %w", err)
+		}
+		mux.HandleFunc(basePath+"/api/v1/write", receiver.handler(opts.MaxRequestSize))
+		mux.HandleFunc(basePath+"/api/v1/write/stats", receiver.statsHandler(false))
+		mux.HandleFunc(basePath+"/api/v1/write/metrics", receiver.statsHandler(true))
+	}
+
+	// ── API: server-side connections ────────────────────────────────────
+	// Changes are admin operations, checked like proxied ones.
+	connPath := basePath + "/api/v1/connections"
+	mgmt := &proxyEnv{policy: policy, hooks: h}
+	mux.HandleFunc(connPath, conns.handler(connPath, mgmt))
+	mux.HandleFunc(connPath+"/", conns.handler(connPath, mgmt))
+
+	// ── API: health of server-side connections ──────────────────────────
+	mux.HandleFunc(basePath+"/api/v1/connections/health", func(w http.ResponseWriter, r *http.Request) {
+		setCORS(w)
+		snap := conns.snapshot()
+		results := make([]connectionHealth, len(snap.conns))
+		var wg sync.WaitGroup
+		for i := range snap.conns {
+			c := &snap.conns[i]
+			env, ok := envs[c.Type]
+			if !ok {
+				results[i] = connectionHealth{Name: c.Name, Type: c.Type, Status: "down", Error: "unknown connection type"}
+				continue
+			}
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i] = probeHealth(r.Context(), env, c)
+			}(i)
+		}
+		wg.Wait()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(results)
+	})
+
+	// ── Legacy InfluxDB proxy (backward compatibility) ──────────────────
+	legacy := makeLegacyInfluxProxy(envs["influxdb"], envs["influxdb2"], basePath)
+	for _, p := range []string{"/query", "/write", "/ping", "/debug/"} {
+		mux.HandleFunc(basePath+p, legacy)
+	}
+
+	// ── Serve the embedded SPA ──────────────────────────────────────────
+	processedIndex := processIndexHTML(uiFS, basePath)
+	uiPrefix := basePath + "/ui/"
+	mux.HandleFunc(uiPrefix, func(w http.ResponseWriter, r *http.Request) {
+		serveUI(w, r, uiFS, uiPrefix, processedIndex)
+	})
+	mux.HandleFunc(basePath+"/ui", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, uiPrefix, http.StatusMovedPermanently)
+	})
+
+	// ── Playground: serve the same SPA at /playground/* ─────────────────
+	// The SPA detects /playground in its route and activates playground mode
+	// with mock data entirely on the client side — no backend DB needed.
+	mux.HandleFunc(basePath+"/playground/", func(w http.ResponseWriter, r *http.Request) {
+		// Serve index.html for all playground paths — React Router handles routing.
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write(processedIndex)
+	})
+	mux.HandleFunc(basePath+"/playground", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, basePath+"/playground/", http.StatusMovedPermanently)
+	})
+
+	mux.HandleFunc(basePath+"/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == basePath+"/" || r.URL.Path == basePath {
+			http.Redirect(w, r, uiPrefix, http.StatusFound)
+			return
+		}
+		http.NotFound(w, r)
+	})
+
+	return mux, nil
+}
+
+// ── Generic Proxy Handler ───────────────────────────────────────────────────
+
+// proxyTarget is the upstream request assembled by the generic proxy. A
+// backend's Route may rewrite any part of it before the request is sent.
+type proxyTarget struct {
+	Conn   *CLIConnection // CLI connection owning the target, or nil
+	Base   string         // upstream base URL without a trailing slash
+	Path   string         // API path, always starting with "/" or empty
+	Params url.Values     // query parameters forwarded upstream
+	Header http.Header    // request headers forwarded upstream
+}
+
+// URL returns the full upstream URL.
+func (t *proxyTarget) URL() string {
+	u := t.Base + t.Path
+	if encoded := t.Params.Encode(); encoded != "" {
+		u += "?" + encoded
+	}
+	return u
+}
+
+// makeGenericProxy forwards ?target=…&path=… requests to the target backend.
+// When the target belongs to a CLI connection, the server-side credentials
+// and HTTP client of that connection are used instead of the X-Proxy-*
+// headers sent by the browser. Upgrade requests are handed to proxyUpgrade.
+func makeGenericProxy(env *proxyEnv) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setCORS(w)
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if isUpgradeRequest(r) {
+			proxyUpgrade(w, r, env)
+			return
+		}
+
+		proxyReq, client, ok := buildProxyRequest(w, r, env, nil)
+		if !ok {
+			return
+		}
+
+		resp, err := client.Do(proxyReq)
+		if err != nil {
+			jsonError(w, http.StatusBadGateway, fmt.Sprintf("Connection failed: %s", err))
+			return
+		}
+		defer resp.Body.Close()
+
+		copyResponse(w, resp)
+	}
+}
+
+// buildProxyRequest validates the ?target=…&path=… parameters of r, injects
+// credentials, enforces the write/admin policy, applies the backend's route
+// and returns the upstream request with the client to send it with. A nil
+// body forwards r.Body. On failure the error has already been written to w
+// and ok is false.
+func buildProxyRequest(w http.ResponseWriter, r *http.Request, env *proxyEnv, body io.Reader) (*http.Request, *http.Client, bool) {
+	target := r.URL.Query().Get("target")
+	apiPath := r.URL.Query().Get("path")
+
+	if target == "" {
+		jsonError(w, http.StatusBadRequest, "Missing 'target' query parameter")
+		return nil, nil, false
+	}
+
+	parsedTarget, err := url.Parse(target)
+	if err != nil || (parsedTarget.Scheme != "http" && parsedTarget.Scheme != "https") {
+		jsonError(w, http.StatusBadRequest, "Invalid target URL: must use http:// or https://")
+		return nil, nil, false
+	}
+
+	if apiPath != "" && !strings.HasPrefix(apiPath, "/") {
+		apiPath = "/" + apiPath
+	}
+
+	t := &proxyTarget{
+		Base:   strings.TrimRight(target, "/"),
+		Path:   apiPath,
+		Params: make(url.Values),
+		Header: make(http.Header),
+	}
+	for k, vs := range r.URL.Query() {
+		if k == "target" || k == "path" {
+			continue
+		}
+		for _, v := range vs {
+			t.Params.Add(k, v)
+		}
+	}
+	for _, h := range []string{"Content-Type", "Accept", "Content-Encoding", "Authorization", "X-Prometheus-Remote-Write-Version"} {
+		if v := r.Header.Get(h); v != "" {
+			t.Header.Set(h, v)
+		}
+	}
+
+	client := env.client
+	cred := credentials{
+		Username: r.Header.Get("X-Proxy-Username"),
+		Password: r.Header.Get("X-Proxy-Password"),
+		Token:    r.Header.Get("X-Proxy-Token"),
+	}
+	if up, ok := env.lookup(parsedTarget); ok {
+		t.Conn = up.conn
+		client = up.client
+		cred = up.creds
+		if cred != (credentials{}) {
+			t.Header.Del("Authorization")
+		}
+	}
+	env.backend.InjectAuth(t.Header, cred)
+
+	op := Operation{Backend: env.backend.Type(), Target: t.Base, Method: r.Method, Path: t.Path}
+	if t.Conn != nil {
+		op.Connection = t.Conn.Name
+	}
+	err = env.authorize(r, op, env.backend.Classify(r, t))
+	if qe, ok := env.backend.(queryExtractor); ok && err == nil {
+		if q, ok := qe.ExtractQuery(r, t); ok {
+			env.recordQuery(r, q)
+		}
+	}
+	if err == nil {
+		err = env.backend.Route(r, t)
+	}
+	if err != nil {
+		jsonError(w, httpErrorStatus(err, http.StatusBadGateway), err.Error())
+		return nil, nil, false
+	}
+
+	forwardBody := body == nil
+	if forwardBody {
+		body = r.Body
+	}
+	proxyReq, err := http.NewRequestWithContext(r.Context(), r.Method, t.URL(), body)
+	if err != nil {
+		jsonError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to create request: %s", err))
+		return nil, nil, false
+	}
+	if forwardBody && r.ContentLength > 0 {
+		proxyReq.ContentLength = r.ContentLength
+	}
+	proxyReq.Header = t.Header
+	return proxyReq, client, true
+}
+
+// convertProxy sends a request built by buildProxyRequest and, when the
+// upstream answers 200, lets convert write a reshaped response. Any other
+// status is relayed unchanged. prepare may adjust the upstream request.
+func convertProxy(w http.ResponseWriter, r *http.Request, env *proxyEnv, body io.Reader,
+	prepare func(*http.Request), convert func(http.ResponseWriter, io.Reader) error) {
+	proxyReq, client, ok := buildProxyRequest(w, r, env, body)
+	if !ok {
+		return
+	}
+	if prepare != nil {
+		prepare(proxyReq)
+	}
+
+	resp, err := client.Do(proxyReq)
+	if err != nil {
+		jsonError(w, http.StatusBadGateway, fmt.Sprintf("Connection failed: %s", err))
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		copyResponse(w, resp)
+		return
+	}
+	if err := convert(w, resp.Body); err != nil {
+		jsonError(w, http.StatusBadGateway, fmt.Sprintf("Invalid upstream response: %s", err))
+	}
+}
+
+// ── Legacy InfluxDB Proxy (backward compat) ─────────────────────────────────
+
+// makeLegacyInfluxProxy forwards InfluxDB 1.x API paths to the instance named
+// by X-Influxdb-Url. InfluxDB 2.x targets are reached through their
+// v1-compatibility API with token auth, so the InfluxQL explorer works for
+// both; the credentials of the influxdb CLI connections in v1 and the tokens
+// of the influxdb2 ones in env are injected server-side. Without the header,
+// the first influxdb connection is used. Writes and InfluxQL admin
+// statements are subject to policy.
+func makeLegacyInfluxProxy(v1, env *proxyEnv, basePath string) http.HandlerFunc {
+	apiPath := func(r *http.Request) string { return strings.TrimPrefix(r.URL.Path, basePath) }
+	return env.observe(lineProtocolCheck("influxdb", apiPath, func(w http.ResponseWriter, r *http.Request) {
+		setCORS(w)
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		targetURL := r.Header.Get("X-Influxdb-Url")
+		if targetURL == "" {
+			targetURL = env.conns.snapshot().firstURL("influxdb")
+		}
+		if targetURL == "" {
+			jsonError(w, http.StatusBadGateway, "No InfluxDB connection configured. Add a connection in the UI.")
+			return
+		}
+
+		target, err := url.Parse(targetURL)
+		if err != nil {
+			jsonError(w, http.StatusBadRequest, fmt.Sprintf("Invalid target URL: %s", err))
+			return
+		}
+
+		// Strip the base-path prefix so we forward only the InfluxDB path
+		// (e.g. /timeseries-ui/query → /query).
+		influxPath := strings.TrimPrefix(r.URL.Path, basePath)
+
+		op := Operation{Backend: "influxdb", Target: targetURL, Method: r.Method, Path: influxPath}
+		if err := env.authorize(r, op, classifyInfluxV1(r, influxPath)); err != nil {
+			jsonError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		if q, ok := influxQLQuery(r, r.URL.Query(), influxPath); ok {
+			env.recordQuery(r, q)
+		}
+
+		upstream := *target
+		upstream.Path = strings.TrimRight(upstream.Path, "/") + influxPath
+		upstream.RawQuery = r.URL.RawQuery
+
+		client := env.client
+		token := r.Header.Get("X-Influxdb-Token")
+		username := r.Header.Get("X-Influxdb-Username")
+		password := r.Header.Get("X-Influxdb-Password")
+		var cred *credentials
+		if up, ok := env.lookup(target); ok {
+			client, token = up.client, up.creds.Token
+		} else if up, ok := v1.lookup(target); ok {
+			// The connections API does not reveal these credentials, so
+			// they replace any the request carries.
+			client, cred = up.client, &up.creds
+			q := upstream.Query()
+			q.Del("u")
+			q.Del("p")
+			upstream.RawQuery = q.Encode()
+			username, password = "", ""
+		}
+		if token == "" && (username != "" || password != "") {
+			q := upstream.Query()
+			if q.Get("u") == "" && username != "" {
+				q.Set("u", username)
+			}
+			if q.Get("p") == "" && password != "" {
+				q.Set("p", password)
+			}
+			upstream.RawQuery = q.Encode()
+		}
+
+		proxyReq, err := http.NewRequestWithContext(r.Context(), r.Method, upstream.String(), r.Body)
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to create request: %s", err))
+			return
+		}
+		if r.ContentLength > 0 {
+			proxyReq.ContentLength = r.ContentLength
+		}
+		for _, h := range []string{"Content-Type", "Accept", "Content-Encoding"} {
+			if v := r.Header.Get(h); v != "" {
+				proxyReq.Header.Set(h, v)
+			}
+		}
+		if token != "" {
+			proxyReq.Header.Set("Authorization", "Token "+token)
+		}
+		if cred != nil {
+			v1.backend.InjectAuth(proxyReq.Header, *cred)
+		}
+
+		resp, err := client.Do(proxyReq)
+		if err != nil {
+			jsonError(w, http.StatusBadGateway, fmt.Sprintf("Connection failed: %s", err))
+			return
+		}
+		defer resp.Body.Close()
+
+		copyResponse(w, resp)
+	}))
+}
+
+// ── SPA Serving ─────────────────────────────────────────────────────────────
+
+// processIndexHTML reads the embedded index.html and injects the runtime base
+// path. When --base-path is set, asset URLs ("/ui/…") are rewritten to include
+// the prefix so that the SPA works behind a reverse proxy at a sub-path.
+func processIndexHTML(uiFS fs.FS, basePath string) []byte {
+	f, err := uiFS.Open("index.html")
+	if err != nil {
+		// During development the embedded FS may be empty; return a placeholder.
+		return []byte("<!-- index.html not available -->")
+	}
+	defer f.Close()
+	raw, err := io.ReadAll(f)
+	if err != nil {
+		return []byte("<!-- failed to read index.html -->")
+	}
+	html := string(raw)
+
+	// Inject the base path so the SPA can prefix API calls and router basename.
+	html = strings.Replace(html,
+		`window.__TSUI_BASE__=""`,
+		fmt.Sprintf(`window.__TSUI_BASE__="%s"`, basePath), 1)
+
+	// Rewrite Vite-generated asset paths when a base path is configured.
+	if basePath != "" {
+		html = strings.ReplaceAll(html, `="/ui/`, `="`+basePath+`/ui/`)
+		html = strings.ReplaceAll(html, `'/ui/`, `'`+basePath+`/ui/`)
+	}
+
+	return []byte(html)
+}
+
+func serveUI(w http.ResponseWriter, r *http.Request, uiFS fs.FS, prefix string, processedIndex []byte) {
+	path := strings.TrimPrefix(r.URL.Path, prefix)
+	if path == "" || path == "index.html" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write(processedIndex)
+		return
+	}
+
+	f, err := uiFS.Open(path)
+	if err == nil {
+		f.Close()
+		http.StripPrefix(prefix, http.FileServer(http.FS(uiFS))).ServeHTTP(w, r)
+		return
+	}
+
+	// SPA fallback — serve processed index.html for client-side routing.
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(processedIndex)
+}
+
+// ── Helpers ─────────────────────────────────────────────────────────────────
+
+func setCORS(w http.ResponseWriter) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Influxdb-Url, X-Influxdb-Username, X-Influxdb-Password, X-Proxy-Username, X-Proxy-Password, X-Proxy-Token, X-Proxy-Org, X-Proxy-Database, X-Scope-OrgID, X-Prometheus-Flavor, X-Influxdb-Token, X-Vm-Tenant-Id, X-Vm-Insert-Url, X-Vm-Storage-Url, If-Match")
+	w.Header().Set("Access-Control-Expose-Headers", "X-Influxdb-Version, X-Tidedb-Version, ETag")
+}
+
+// copyResponse relays an upstream response's headers, status and body.
+// Streamed bodies (no Content-Length, or a streaming content type) are
+// flushed after every chunk so that chunked queries, exports and event
+// streams reach the browser as they arrive. A failed write means the client
+// has gone; returning lets the request context cancel the upstream call.
+func copyResponse(w http.ResponseWriter, resp *http.Response) {
+	for k, vs := range resp.Header {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	streaming := resp.ContentLength < 0 || isStreamingContentType(resp.Header.Get("Content-Type"))
+	if streaming {
+		w.Header().Set("X-Accel-Buffering", "no")
+	}
+	setCORS(w)
+	w.WriteHeader(resp.StatusCode)
+	if !streaming {
+		io.Copy(w, resp.Body)
+		return
+	}
+	rc := http.NewResponseController(w)
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return
+			}
+			if ferr := rc.Flush(); ferr != nil && !errors.Is(ferr, http.ErrNotSupported) {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// isStreamingContentType reports whether a response is meant to be consumed
+// incrementally.
+func isStreamingContentType(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/event-stream", "application/x-ndjson", "application/stream+json", "application/jsonl":
+		return true
+	}
+	return false
+}
+
+// maxFormBodySize bounds the body buffered by requestParam and peekBody.
+const maxFormBodySize = 1 << 20
+
+// peekBody buffers up to maxFormBodySize bytes of r's body and restores it
+// for forwarding. complete is false when the body is longer.
+func peekBody(r *http.Request) (raw []byte, complete bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true
+	}
+	raw, _ = io.ReadAll(io.LimitReader(r.Body, maxFormBodySize+1))
+	r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(raw), r.Body))
+	if len(raw) > maxFormBodySize {
+		return raw[:maxFormBodySize], false
+	}
+	return raw, true
+}
+
+// requestParam returns key from params, or else from a form-encoded POST
+// body. The body is buffered and restored so it can still be forwarded.
+func requestParam(r *http.Request, params url.Values, key string) string {
+	if v := params.Get(key); v != "" {
+		return v
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if r.Method != http.MethodPost || mediaType != "application/x-www-form-urlencoded" {
+		return ""
+	}
+	raw, _ := peekBody(r)
+	form, err := url.ParseQuery(string(raw))
+	if err != nil {
+		return ""
+	}
+	return form.Get(key)
+}
+
+func jsonError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+// httpError is an error that carries the HTTP status to report to the client.
+type httpError struct {
+	status int
+	msg    string
+}
+
+func (e *httpError) Error() string { return e.msg }
+
+// httpErrorStatus returns the status of err if it is an httpError, or else
+// fallback.
+func httpErrorStatus(err error, fallback int) int {
+	var he *httpError
+	if errors.As(err, &he) {
+		return he.status
+	}
+	return fallback
+}
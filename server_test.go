@@ -0,0 +1,156 @@
+package timeseriesui
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestHandler returns the handler for opts, failing the test on error.
+func newTestHandler(t *testing.T, opts Options) http.Handler {
+	t.Helper()
+	h, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+// proxyPath returns the proxy URL of path on target for a backend type.
+func proxyPath(typ, target, path string) string {
+	return "/proxy/" + typ + "/?" + url.Values{"target": {target}, "path": {path}}.Encode()
+}
+
+// serve sends a request to h and returns the recorded response.
+func serve(h http.Handler, method, target string, body io.Reader, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, body)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestLegacyInfluxProxyCredentials(t *testing.T) {
+	var user, pass, params string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ = r.BasicAuth()
+		params = r.URL.Query().Get("u") + ":" + r.URL.Query().Get("p")
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"results":[]}`)
+	}))
+	defer upstream.Close()
+	h := newTestHandler(t, Options{
+		Connections: []CLIConnection{{Name: "v1", Type: "influxdb", URL: upstream.URL, Username: "admin", Password: "secret"}},
+	})
+
+	// The UI sends the redacted connection's username with an empty password.
+	for _, header := range []map[string]string{
+		{"X-Influxdb-Url": upstream.URL, "X-Influxdb-Username": "admin", "X-Influxdb-Password": ""},
+		{},
+	} {
+		user, pass, params = "", "", ""
+		rec := serve(h, http.MethodGet, "/query?q=SHOW+DATABASES&u=admin", nil, header)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", rec.Code, rec.Body)
+		}
+		if user != "admin" || pass != "secret" || params != ":" {
+			t.Errorf("headers %v: upstream got basic auth %s:%s and u:p %s", header, user, pass, params)
+		}
+	}
+}
+
+func TestEmbeddedHandler(t *testing.T) {
+	var user string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _, _ = r.BasicAuth()
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"status":"success","data":{"resultType":"vector","result":[]}}`)
+	}))
+	defer upstream.Close()
+	var events []AuditEvent
+	h := newTestHandler(t, Options{
+		BasePath:    "/tsui/",
+		Version:     "1.2.3",
+		Connections: []CLIConnection{{Name: "prom", Type: "prometheus", URL: upstream.URL, Username: "reader", Password: "secret"}},
+		Authorize: func(r *http.Request, op Operation) error {
+			if r.Header.Get("X-Role") != "viewer" {
+				return errors.New("viewers only")
+			}
+			return nil
+		},
+		Audit: func(r *http.Request, ev AuditEvent) { events = append(events, ev) },
+	})
+
+	if rec := serve(h, http.MethodGet, "/tsui/api/v1/health", nil, nil); !strings.Contains(rec.Body.String(), `"version":"1.2.3"`) {
+		t.Errorf("health: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(h, http.MethodGet, "/api/v1/health", nil, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("health outside the base path: status %d", rec.Code)
+	}
+
+	target := "/tsui" + proxyPath("prometheus", upstream.URL, "/api/v1/query") + "&query=up"
+	rec := serve(h, http.MethodGet, target, nil, map[string]string{"X-Role": "viewer"})
+	if rec.Code != http.StatusOK || user != "reader" {
+		t.Errorf("allowed query: status %d, upstream user %q: %s", rec.Code, user, rec.Body)
+	}
+	user = ""
+	rec = serve(h, http.MethodGet, target, nil, nil)
+	if rec.Code != http.StatusForbidden || user != "" || !strings.Contains(rec.Body.String(), "viewers only") {
+		t.Errorf("denied query: status %d, upstream user %q: %s", rec.Code, user, rec.Body)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("%d audit events", len(events))
+	}
+	for i, status := range []int{http.StatusOK, http.StatusForbidden} {
+		ev := events[i]
+		if ev.Backend != "prometheus" || ev.Connection != "prom" || ev.Path != "/api/v1/query" || ev.Kind != "read" || ev.Status != status {
+			t.Errorf("audit event %d: %+v", i, ev)
+		}
+	}
+}
+
+func TestStreamingResponse(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		io.WriteString(w, `{"chunk":1}`+"\n")
+		w.(http.Flusher).Flush()
+		<-release
+		io.WriteString(w, `{"chunk":2}`+"\n")
+	}))
+	defer upstream.Close()
+	defer close(release)
+	srv := httptest.NewServer(newTestHandler(t, Options{}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + proxyPath("influxdb2", upstream.URL, "/api/v2/query"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("X-Accel-Buffering") != "no" {
+		t.Errorf("X-Accel-Buffering %q", resp.Header.Get("X-Accel-Buffering"))
+	}
+	first := make(chan string, 1)
+	go func() {
+		line, _ := bufio.NewReader(resp.Body).ReadString('\n')
+		first <- line
+	}()
+	select {
+	case line := <-first:
+		if line != `{"chunk":1}`+"\n" {
+			t.Errorf("first chunk %q", line)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the first chunk was not flushed before the upstream finished")
+	}
+}
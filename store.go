@@ -0,0 +1,128 @@
+package timeseriesui
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ── Server-side state ───────────────────────────────────────────────────────
+//
+// Features that keep state (query history, …) hold it in memory and, when
+// Options.DataDir is set, persist it to files in that directory. Without a
+// data directory the server stays stateless across restarts.
+
+// newID returns a random 16-character hex identifier.
+func newID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// writeFileAtomic replaces path with data via a temporary file and rename,
+// so readers never see a partial file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// loadJSONFile decodes path into v. A missing file leaves v unchanged.
+func loadJSONFile(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// saveJSONFile writes v to path atomically.
+func saveJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'))
+}
+
+// writeJSON sends v with the given status.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// maxStoreRequestSize bounds the request bodies of the stored-document APIs.
+const maxStoreRequestSize = 8 << 20
+
+// serveLocked runs serve under mu with the request body already read and the
+// response buffered, so a slow client never holds mu.
+func serveLocked(mu *sync.Mutex, w http.ResponseWriter, r *http.Request, serve http.HandlerFunc) {
+	if r.Body != nil && r.Body != http.NoBody {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxStoreRequestSize))
+		if err != nil {
+			var mbe *http.MaxBytesError
+			if errors.As(err, &mbe) {
+				jsonError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+			} else {
+				jsonError(w, http.StatusBadRequest, "Failed to read the request body")
+			}
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	buf := &bufferedResponse{header: w.Header()}
+	mu.Lock()
+	serve(buf, r)
+	mu.Unlock()
+	if buf.status == 0 {
+		buf.status = http.StatusOK
+	}
+	w.WriteHeader(buf.status)
+	w.Write(buf.body.Bytes())
+}
+
+// ── Optimistic concurrency ──────────────────────────────────────────────────
+//
+// Stored documents carry a version that is bumped on every update. Clients
+// send the version they last read, as If-Match: "<version>" or in the
+// body, and an update against a newer version fails with 409 Conflict.
+
+// versionETag formats a document version as an ETag.
+func versionETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// expectedVersion returns the version the client based its update on, from
+// If-Match or else from the body's version field; 0 when neither is set.
+func expectedVersion(r *http.Request, bodyVersion int) int {
+	if m := strings.Trim(strings.TrimPrefix(r.Header.Get("If-Match"), "W/"), `"`); m != "" {
+		if v, err := strconv.Atoi(m); err == nil {
+			return v
+		}
+	}
+	return bodyVersion
+}
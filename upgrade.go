@@ -0,0 +1,159 @@
+package timeseriesui
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ── Upgrade proxying ────────────────────────────────────────────────────────
+//
+// Requests with Connection: Upgrade (WebSocket, e.g. Loki tail) are proxied
+// by hijacking the client connection once the upstream has answered 101, and
+// piping bytes in both directions. They go through the same auth injection,
+// routing and write/admin/Authorize policy as plain requests. Browsers do
+// not apply CORS to WebSockets, so the Origin must be the server's own host
+// or one of Options.AllowedOrigins.
+
+// defaultUpgradeIdleTimeout closes upgraded connections with no traffic.
+const defaultUpgradeIdleTimeout = 5 * time.Minute
+
+// upgradeHeaders are the handshake headers forwarded upstream.
+var upgradeHeaders = []string{
+	"Connection", "Upgrade",
+	"Sec-WebSocket-Key", "Sec-WebSocket-Version",
+	"Sec-WebSocket-Protocol", "Sec-WebSocket-Extensions",
+}
+
+// isUpgradeRequest reports whether r asks for a protocol upgrade.
+func isUpgradeRequest(r *http.Request) bool {
+	if r.Header.Get("Upgrade") == "" {
+		return false
+	}
+	for _, v := range r.Header.Values("Connection") {
+		for _, tok := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(tok), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkOrigin accepts requests without an Origin (non-browser clients),
+// same-origin requests and the configured allowed origins.
+func (env *proxyEnv) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, o := range env.hooks.allowedOrigins {
+		if o == "*" || strings.EqualFold(strings.TrimRight(o, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyUpgrade forwards an upgrade handshake and, on 101, pipes the hijacked
+// client connection to the upstream one until either side closes or the
+// connection is idle for longer than the idle timeout.
+func proxyUpgrade(w http.ResponseWriter, r *http.Request, env *proxyEnv) {
+	if !env.checkOrigin(r) {
+		jsonError(w, http.StatusForbidden, "Origin not allowed: "+r.Header.Get("Origin"))
+		return
+	}
+	proxyReq, client, ok := buildProxyRequest(w, r, env, nil)
+	if !ok {
+		return
+	}
+	for _, h := range upgradeHeaders {
+		if vs := r.Header.Values(h); len(vs) > 0 {
+			proxyReq.Header[h] = vs
+		}
+	}
+
+	resp, err := client.Do(proxyReq)
+	if err != nil {
+		jsonError(w, http.StatusBadGateway, fmt.Sprintf("Connection failed: %s", err))
+		return
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		defer resp.Body.Close()
+		copyResponse(w, resp)
+		return
+	}
+	upConn, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		resp.Body.Close()
+		jsonError(w, http.StatusBadGateway, "Upstream connection cannot be upgraded")
+		return
+	}
+	defer upConn.Close()
+
+	conn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		jsonError(w, http.StatusInternalServerError, "Upgrades are not supported by this server")
+		return
+	}
+	defer conn.Close()
+
+	fmt.Fprintf(brw, "HTTP/1.1 %s\r\n", resp.Status)
+	resp.Header.Write(brw)
+	brw.WriteString("\r\n")
+	if err := brw.Flush(); err != nil {
+		return
+	}
+
+	idle := env.hooks.upgradeIdleTimeout
+	if idle <= 0 {
+		idle = defaultUpgradeIdleTimeout
+	}
+	pipeUpgraded(conn, brw.Reader, upConn, idle)
+}
+
+// pipeUpgraded copies client→upstream and upstream→client. Both connections
+// are closed when either direction ends or no data flows for idle.
+func pipeUpgraded(conn net.Conn, clientReader *bufio.Reader, upConn io.ReadWriteCloser, idle time.Duration) {
+	closeBoth := func() {
+		conn.Close()
+		upConn.Close()
+	}
+	timer := time.AfterFunc(idle, closeBoth)
+	defer timer.Stop()
+
+	done := make(chan struct{}, 2)
+	copyIdle := func(dst io.Writer, src io.Reader) {
+		buf := make([]byte, 32<<10)
+		for {
+			n, err := src.Read(buf)
+			if n > 0 {
+				timer.Reset(idle)
+				if _, werr := dst.Write(buf[:n]); werr != nil {
+					break
+				}
+			}
+			if err != nil {
+				break
+			}
+		}
+		done <- struct{}{}
+	}
+	go copyIdle(upConn, clientReader)
+	go copyIdle(conn, upConn)
+	<-done
+	closeBoth()
+	<-done
+}
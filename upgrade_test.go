@@ -0,0 +1,89 @@
+package timeseriesui
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoUpgradeServer answers upgrades with 101 and echoes the bytes it gets,
+// recording the basic-auth user of the handshake.
+func echoUpgradeServer(t *testing.T, user *string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*user, _, _ = r.BasicAuth()
+		if !isUpgradeRequest(r) {
+			http.Error(w, "upgrade required", http.StatusUpgradeRequired)
+			return
+		}
+		conn, brw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+		brw.Flush()
+		io.Copy(conn, brw)
+	}))
+}
+
+// upgrade sends an upgrade handshake for target to addr and returns the
+// connection and the response.
+func upgrade(t *testing.T, addr, target, origin string) (net.Conn, *bufio.Reader, *http.Response) {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	req, _ := http.NewRequest(http.MethodGet, "http://"+addr+target, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "echo")
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn, br, resp
+}
+
+func TestProxyUpgrade(t *testing.T) {
+	var user string
+	upstream := echoUpgradeServer(t, &user)
+	defer upstream.Close()
+	h := newTestHandler(t, Options{
+		Connections:    []CLIConnection{{Name: "prom", Type: "prometheus", URL: upstream.URL, Username: "reader", Password: "secret"}},
+		AllowedOrigins: []string{"https://ui.example"},
+	})
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	addr := strings.TrimPrefix(srv.URL, "http://")
+	target := proxyPath("prometheus", upstream.URL, "/stream")
+
+	conn, br, resp := upgrade(t, addr, target, "https://ui.example")
+	defer conn.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols || user != "reader" {
+		t.Fatalf("status %d, upstream user %q", resp.StatusCode, user)
+	}
+	io.WriteString(conn, "ping\n")
+	if line, err := br.ReadString('\n'); err != nil || line != "ping\n" {
+		t.Errorf("echo %q, %v", line, err)
+	}
+
+	conn2, _, resp := upgrade(t, addr, target, "https://evil.example")
+	defer conn2.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("foreign origin: status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,519 @@
+package timeseriesui
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ── Line protocol uploads ───────────────────────────────────────────────────
+//
+// POST <BasePath>/api/v1/uploads?connection=…&database=… takes a line
+// protocol file as the request body, plain or gzipped, and writes it to the
+// connection in batches as an "upload" job:
+//
+//	connection   an InfluxDB or VictoriaMetrics server-side connection
+//	database     database or bucket (default: the connection's)
+//	precision    timestamp precision of the file (default ns)
+//	path         write API path (default /write)
+//	batchLines   lines per batch (default 5000 unless batchBytes is set)
+//	batchBytes   bytes per batch, cut at line ends
+//	parallelism  batches written at once (default 1)
+//	name         file name, for display
+//	format       line (default), or csv for a VictoriaMetrics CSV file
+//	csvFormat    the column spec of a csv file, as /api/v1/import/csv takes
+//
+// CSV files go to a VictoriaMetrics connection's /api/v1/import/csv, and
+// database and precision do not apply.
+//
+// The file is stored with the job, so the write outlives the upload request
+// and the job API reports its progress (/api/v1/jobs/<id>/events) and can
+// cancel it. The job's result records how many batches were acknowledged,
+// counting in order from the first; resuming the job skips those batches.
+// Options.MaxUploadSize bounds the file, and falls back to MaxRequestSize
+// and then to defaultMaxUploadSize.
+
+const (
+	defaultMaxUploadSize    = 1 << 30
+	defaultUploadBatchLines = 5000
+	maxUploadBatchBytes     = 64 << 20
+	maxUploadParallelism    = 16
+	uploadRetries           = 3
+)
+
+// uploadParams are the parameters of an upload job.
+type uploadParams struct {
+	Connection  string `json:"connection"`
+	Database    string `json:"database,omitempty"`
+	Precision   string `json:"precision,omitempty"`
+	Path        string `json:"path,omitempty"`
+	BatchLines  int    `json:"batchLines,omitempty"`
+	BatchBytes  int    `json:"batchBytes,omitempty"`
+	Parallelism int    `json:"parallelism,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Format      string `json:"format,omitempty"`
+	CSVFormat   string `json:"csvFormat,omitempty"`
+}
+
+// uploadJobResult is the checkpoint of an upload job.
+type uploadJobResult struct {
+	Size    int64 `json:"size"`    // bytes uploaded
+	Batches int   `json:"batches"` // acknowledged, in order from the first
+	Lines   int   `json:"lines"`   // in the acknowledged batches
+	Bytes   int64 `json:"bytes"`   // uncompressed, in the acknowledged batches
+}
+
+// uploadBatch is a run of whole lines of the file.
+type uploadBatch struct {
+	n         int
+	firstLine int
+	lines     int
+	body      []byte
+}
+
+// uploadParamsFromQuery reads uploadParams from query parameters.
+func uploadParamsFromQuery(q url.Values) (*uploadParams, error) {
+	p := &uploadParams{
+		Connection: q.Get("connection"),
+		Database:   q.Get("database"),
+		Precision:  q.Get("precision"),
+		Path:       q.Get("path"),
+		Name:       q.Get("name"),
+		Format:     q.Get("format"),
+		CSVFormat:  q.Get("csvFormat"),
+	}
+	for name, v := range map[string]*int{"batchLines": &p.BatchLines, "batchBytes": &p.BatchBytes, "parallelism": &p.Parallelism} {
+		if s := q.Get(name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				return nil, &httpError{http.StatusBadRequest, name + " must be a non-negative integer"}
+			}
+			*v = n
+		}
+	}
+	return p, nil
+}
+
+// check validates p and fills in the defaults, returning the connection.
+func (p *uploadParams) check(qr *queryRunner) (*CLIConnection, error) {
+	if p.Connection == "" {
+		return nil, &httpError{http.StatusBadRequest, "connection is required"}
+	}
+	c := qr.conns.snapshot().find(p.Connection, "")
+	if c == nil {
+		return nil, &httpError{http.StatusNotFound, fmt.Sprintf("unknown connection %q", p.Connection)}
+	}
+	p.Connection = c.Name
+	switch p.Format {
+	case "", "line":
+		p.Format = ""
+	case "csv":
+		return c, p.checkCSV(c)
+	default:
+		return nil, &httpError{http.StatusBadRequest, fmt.Sprintf("invalid format %q", p.Format)}
+	}
+	if lineProtocolPaths[c.Type] == nil {
+		return nil, &httpError{http.StatusBadRequest, fmt.Sprintf("%s connections do not accept line protocol", c.Type)}
+	}
+	if p.Path == "" {
+		p.Path = "/write"
+	}
+	if !containsString(lineProtocolPaths[c.Type], p.Path) {
+		return nil, &httpError{http.StatusBadRequest, "path must be one of " + strings.Join(lineProtocolPaths[c.Type], ", ")}
+	}
+	if p.Database == "" {
+		p.Database = c.DefaultDatabase
+	}
+	if p.Database == "" && c.Type != "victoriametrics" {
+		return nil, &httpError{http.StatusBadRequest, "database is required"}
+	}
+	if _, ok := linePrecisions[p.Precision]; p.Precision != "" && !ok {
+		return nil, &httpError{http.StatusBadRequest, fmt.Sprintf("invalid precision %q", p.Precision)}
+	}
+	return c, p.checkBatches()
+}
+
+// checkCSV validates the parameters of a VictoriaMetrics CSV upload.
+func (p *uploadParams) checkCSV(c *CLIConnection) error {
+	if c.Type != "victoriametrics" {
+		return &httpError{http.StatusBadRequest, "csv uploads need a victoriametrics connection"}
+	}
+	if p.Path == "" {
+		p.Path = "/api/v1/import/csv"
+	}
+	switch {
+	case p.Path != "/api/v1/import/csv":
+		return &httpError{http.StatusBadRequest, "path must be /api/v1/import/csv"}
+	case p.CSVFormat == "":
+		return &httpError{http.StatusBadRequest, "csvFormat is required"}
+	case p.Database != "" || p.Precision != "":
+		return &httpError{http.StatusBadRequest, "database and precision do not apply to csv uploads"}
+	}
+	return p.checkBatches()
+}
+
+// checkBatches validates the batching parameters and fills in the defaults.
+func (p *uploadParams) checkBatches() error {
+	switch {
+	case p.BatchBytes > maxUploadBatchBytes:
+		return &httpError{http.StatusBadRequest, fmt.Sprintf("batchBytes must be at most %d", maxUploadBatchBytes)}
+	case p.Parallelism > maxUploadParallelism:
+		return &httpError{http.StatusBadRequest, fmt.Sprintf("parallelism must be at most %d", maxUploadParallelism)}
+	}
+	if p.BatchLines == 0 && p.BatchBytes == 0 {
+		p.BatchLines = defaultUploadBatchLines
+	}
+	if p.Parallelism == 0 {
+		p.Parallelism = 1
+	}
+	return nil
+}
+
+// uploadHandler serves POST /api/v1/uploads: it checks the parameters and
+// the write policy, stores the body and queues the upload job.
+func uploadHandler(jr *jobRunner, qr *queryRunner, envs map[string]*proxyEnv, jobsPath string, maxSize int64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setCORS(w)
+		switch r.Method {
+		case http.MethodOptions:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case http.MethodPost:
+		default:
+			jsonError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		p, err := uploadParamsFromQuery(r.URL.Query())
+		var c *CLIConnection
+		if err == nil {
+			c, err = p.check(qr)
+		}
+		if err == nil {
+			// Refuse before reading what may be a very large body.
+			err = envs[c.Type].authorizeWrite(r, c, p.Path)
+		}
+		if err != nil {
+			jsonError(w, httpErrorStatus(err, http.StatusBadRequest), err.Error())
+			return
+		}
+		body := limitUpload(r, maxSize)
+		params, _ := json.Marshal(p)
+		jr.start(w, r, jobsPath, "upload", params, func(path string) error {
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			n, err := io.Copy(f, r.Body)
+			if err != nil {
+				return body.err(&httpError{http.StatusBadRequest, "Upload failed: " + err.Error()})
+			}
+			if n == 0 {
+				return &httpError{http.StatusBadRequest, "The file is empty"}
+			}
+			return f.Close()
+		})
+	}
+}
+
+// uploadJob writes an uploaded file in batches.
+func uploadJob(qr *queryRunner) jobKind {
+	return jobKind{
+		upload: true,
+		validate: func(params json.RawMessage) error {
+			var p uploadParams
+			if err := json.Unmarshal(params, &p); err != nil {
+				return &httpError{http.StatusBadRequest, "Invalid params: " + err.Error()}
+			}
+			_, err := p.check(qr)
+			return err
+		},
+		run: func(jc *jobContext) error {
+			var p uploadParams
+			if err := json.Unmarshal(jc.job.Params, &p); err != nil {
+				return err
+			}
+			c, err := p.check(qr)
+			if err != nil {
+				return err
+			}
+			f, err := os.Open(jc.artifact())
+			if err != nil {
+				return errors.New("the uploaded file is gone")
+			}
+			defer f.Close()
+			info, err := f.Stat()
+			if err != nil {
+				return err
+			}
+			u := &uploader{qr: qr, jc: jc, p: &p, typ: c.Type, target: c.URL, size: info.Size()}
+			u.res.Size = info.Size()
+			if jc.result(&u.res) && u.res.Batches > 0 {
+				jc.logf("resuming after batch %d (%d lines)", u.res.Batches, u.res.Lines)
+			} else {
+				jc.logf("writing %s (%d bytes) to %s", uploadName(&p), u.size, c.Name)
+			}
+			return u.run(f)
+		},
+	}
+}
+
+func uploadName(p *uploadParams) string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return "the file"
+}
+
+// uploader writes the batches of one upload job.
+type uploader struct {
+	qr          *queryRunner
+	jc          *jobContext
+	p           *uploadParams
+	typ, target string
+	size        int64
+
+	mu    sync.Mutex
+	res   uploadJobResult
+	acked map[int]*uploadBatch // acknowledged after a missing one
+}
+
+// run reads f and writes its batches with p.Parallelism writers, skipping
+// the batches acknowledged by an earlier attempt.
+func (u *uploader) run(f *os.File) error {
+	counter := &countingReader{r: f}
+	br := bufio.NewReaderSize(counter, 64<<10)
+	var src io.Reader = br
+	if head, _ := br.Peek(2); len(head) == 2 && head[0] == 0x1f && head[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("invalid gzip file: %w", err)
+		}
+		src = zr
+	}
+
+	ctx, cancel := context.WithCancel(u.jc.r.Context())
+	defer cancel()
+	r := u.jc.r.WithContext(ctx)
+	skip := u.res.Batches
+	u.acked = map[int]*uploadBatch{}
+	var failed error
+	batches := make(chan *uploadBatch)
+	var wg sync.WaitGroup
+	for i := 0; i < u.p.Parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range batches {
+				err := u.write(r, b)
+				u.mu.Lock()
+				if err != nil && failed == nil && ctx.Err() == nil {
+					failed = fmt.Errorf("batch %d (lines %d–%d): %w", b.n+1, b.firstLine, b.firstLine+b.lines-1, err)
+					cancel()
+				}
+				if err == nil {
+					u.ack(b)
+				}
+				u.mu.Unlock()
+			}
+		}()
+	}
+
+	err := readBatches(src, u.p.BatchLines, u.p.BatchBytes, func(b *uploadBatch) bool {
+		if b.n < skip {
+			return true
+		}
+		u.jc.progress(int(counter.n), int(u.size), fmt.Sprintf("batch %d: lines %d–%d", b.n+1, b.firstLine, b.firstLine+b.lines-1))
+		select {
+		case batches <- b:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	})
+	close(batches)
+	wg.Wait()
+	switch {
+	case failed != nil:
+		return failed
+	case err != nil:
+		return fmt.Errorf("reading the file: %w", err)
+	case ctx.Err() != nil:
+		return ctx.Err()
+	}
+	u.jc.progress(int(u.size), int(u.size), "")
+	u.jc.logf("wrote %d lines in %d batches", u.res.Lines, u.res.Batches)
+	return nil
+}
+
+// ack records b and advances the checkpoint over the batches acknowledged
+// in order; the caller holds mu.
+func (u *uploader) ack(b *uploadBatch) {
+	u.acked[b.n] = b
+	advanced := false
+	for {
+		next, ok := u.acked[u.res.Batches]
+		if !ok {
+			break
+		}
+		delete(u.acked, next.n)
+		u.res.Batches++
+		u.res.Lines += next.lines
+		u.res.Bytes += int64(len(next.body))
+		advanced = true
+	}
+	if advanced {
+		u.jc.setResult(u.res)
+	}
+}
+
+// write sends b, retrying errors other than 4xx responses.
+func (u *uploader) write(r *http.Request, b *uploadBatch) error {
+	params := url.Values{"target": {u.target}, "path": {u.p.Path}}
+	if u.p.Database != "" {
+		params.Set("db", u.p.Database)
+		if u.p.Path == "/api/v2/write" {
+			params.Set("bucket", u.p.Database)
+		}
+	}
+	if u.p.Precision != "" {
+		params.Set("precision", u.p.Precision)
+	}
+	header := http.Header{"Content-Type": {"text/plain; charset=utf-8"}}
+	if u.p.Format == "csv" {
+		params.Set("format", u.p.CSVFormat)
+		header.Set("Content-Type", "text/csv")
+	}
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		_, err := u.qr.send(r, http.MethodPost, u.typ, params, header, b.body)
+		if err == nil || !retryable(err) || attempt >= uploadRetries || r.Context().Err() != nil {
+			return err
+		}
+		u.jc.logf("batch %d failed, retrying in %s: %s", b.n+1, backoff, err)
+		if err := sleepContext(r.Context(), backoff); err != nil {
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// readBatches splits src into batches of at most maxLines lines or about
+// maxBytes bytes (0: no limit), cut at line ends, and calls fn with each
+// until it returns false. Blank lines are dropped.
+func readBatches(src io.Reader, maxLines, maxBytes int, fn func(*uploadBatch) bool) error {
+	br := bufio.NewReaderSize(src, 64<<10)
+	b := &uploadBatch{firstLine: 1}
+	line := 0
+	for {
+		s, err := br.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			// A line longer than the buffer: read the rest of it.
+			head := append([]byte(nil), s...)
+			var rest []byte
+			rest, err = br.ReadBytes('\n')
+			s = append(head, rest...)
+		}
+		if len(s) > 0 {
+			line++
+			if len(bytes.TrimSpace(s)) > 0 {
+				if b.lines == 0 {
+					b.firstLine = line
+				}
+				if maxBytes > 0 && b.lines > 0 && len(b.body)+len(s) > maxBytes {
+					if !fn(b) {
+						return nil
+					}
+					b = &uploadBatch{n: b.n + 1, firstLine: line}
+				}
+				b.body = append(b.body, s...)
+				if s[len(s)-1] != '\n' {
+					b.body = append(b.body, '\n')
+				}
+				b.lines++
+				if maxLines > 0 && b.lines >= maxLines {
+					if !fn(b) {
+						return nil
+					}
+					b = &uploadBatch{n: b.n + 1}
+				}
+			}
+		}
+		if err == io.EOF {
+			if b.lines > 0 {
+				fn(b)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// uploadBody bounds an upload's request body and records whether a read went
+// past the bound.
+type uploadBody struct {
+	io.ReadCloser
+	max, left int64
+	exceeded  bool
+}
+
+// limitUpload bounds r's body to max bytes (0: no limit).
+func limitUpload(r *http.Request, max int64) *uploadBody {
+	b := &uploadBody{ReadCloser: r.Body, max: max, left: max}
+	if max > 0 {
+		r.Body = b
+	}
+	return b
+}
+
+func (b *uploadBody) Read(p []byte) (int, error) {
+	if int64(len(p)) > b.left+1 {
+		p = p[:b.left+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) <= b.left {
+		b.left -= int64(n)
+		return n, err
+	}
+	n, b.left, b.exceeded = int(b.left), 0, true
+	return n, &httpError{http.StatusRequestEntityTooLarge, b.tooLarge()}
+}
+
+func (b *uploadBody) tooLarge() string {
+	return fmt.Sprintf("The file is larger than the upload limit of %d bytes", b.max)
+}
+
+// err returns the error for a failed read of the body: err, or 413 when
+// the body went past the bound.
+func (b *uploadBody) err(err error) error {
+	if b.exceeded {
+		return &httpError{http.StatusRequestEntityTooLarge, b.tooLarge()}
+	}
+	return err
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,25 @@
+package timeseriesui
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRequestSizeLimits(t *testing.T) {
+	h := newTestHandler(t, Options{
+		DataDir:        t.TempDir(),
+		MaxRequestSize: 1 << 10,
+		Connections: []CLIConnection{
+			{Name: "influx", Type: "influxdb", URL: "http://127.0.0.1:1"},
+			{Name: "vm", Type: "victoriametrics", URL: "http://127.0.0.1:2"},
+		},
+		RemoteWriteTargets: []RemoteWriteTarget{{Connection: "vm"}},
+	})
+	body := strings.Repeat("cpu value=1\n", 200)
+	for _, target := range []string{"/api/v1/write", "/api/v1/uploads?connection=influx&database=db"} {
+		if rec := serve(h, http.MethodPost, target, strings.NewReader(body), nil); rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s: status %d: %s", target, rec.Code, rec.Body)
+		}
+	}
+}
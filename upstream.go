@@ -0,0 +1,252 @@
+package timeseriesui
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// ── Server-side upstream resolution ─────────────────────────────────────────
+
+// upstream holds the server-side credentials and HTTP client used when a
+// proxied target URL belongs to a CLI-defined connection.
+type upstream struct {
+	conn   *CLIConnection
+	creds  credentials
+	client *http.Client
+}
+
+// upstreamSet maps normalized base URLs to their upstream settings. A nil set
+// resolves nothing, so the proxy falls back to the browser-supplied headers.
+type upstreamSet map[string]*upstream
+
+// lookup returns the upstream whose base URL matches target.
+func (s upstreamSet) lookup(target *url.URL) (*upstream, bool) {
+	if s == nil {
+		return nil, false
+	}
+	u, ok := s[normalizeBaseURL(target)]
+	return u, ok
+}
+
+// buildAlertmanagerUpstreams resolves the Alertmanager credentials and TLS
+// settings of every CLI connection that has an Alertmanager URL. They are kept
+// separate from the connection's own (Prometheus/VM) credentials.
+func buildAlertmanagerUpstreams(conns []CLIConnection, cc clientConfig) (upstreamSet, error) {
+	set := make(upstreamSet)
+	for i := range conns {
+		c := &conns[i]
+		if c.AlertmanagerURL == "" {
+			continue
+		}
+		u, err := url.Parse(c.AlertmanagerURL)
+		if err != nil {
+			return nil, fmt.Errorf("connection %q: invalid alertmanagerUrl: %w", c.Name, err)
+		}
+		client, err := cc.newClient(c.AlertmanagerCACert, c.AlertmanagerInsecure)
+		if err != nil {
+			return nil, fmt.Errorf("connection %q: %w", c.Name, err)
+		}
+		set[normalizeBaseURL(u)] = &upstream{
+			conn:   c,
+			creds:  credentials{Username: c.AlertmanagerUsername, Password: c.AlertmanagerPassword},
+			client: client,
+		}
+	}
+	return set, nil
+}
+
+// buildConnectionUpstreams resolves the credentials of every CLI connection of
+// the given type, keyed by the connection URL.
+func buildConnectionUpstreams(conns []CLIConnection, connType string, cc clientConfig) (upstreamSet, error) {
+	client, err := cc.newClient("", false)
+	if err != nil {
+		return nil, err
+	}
+	set := make(upstreamSet)
+	for i := range conns {
+		c := &conns[i]
+		if c.Type != connType {
+			continue
+		}
+		u, err := url.Parse(c.URL)
+		if err != nil {
+			return nil, fmt.Errorf("connection %q: invalid url: %w", c.Name, err)
+		}
+		set[normalizeBaseURL(u)] = &upstream{
+			conn:   c,
+			creds:  credentials{Username: c.Username, Password: c.Password, Token: c.Token},
+			client: client,
+		}
+	}
+	return set, nil
+}
+
+// clientConfig builds the HTTP clients used for upstream requests.
+type clientConfig struct {
+	timeout         time.Duration
+	transport       http.RoundTripper
+	maxResponseSize int64
+}
+
+// newClient returns an HTTP client with optional custom TLS settings.
+func (cc clientConfig) newClient(caCertFile string, insecure bool) (*http.Client, error) {
+	rt := cc.transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	if caCertFile != "" || insecure {
+		tlsCfg := &tls.Config{InsecureSkipVerify: insecure}
+		if caCertFile != "" {
+			pem, err := os.ReadFile(caCertFile)
+			if err != nil {
+				return nil, fmt.Errorf("reading CA certificate: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no valid certificates in %s", caCertFile)
+			}
+			tlsCfg.RootCAs = pool
+		}
+		base, ok := rt.(*http.Transport)
+		if !ok {
+			base = http.DefaultTransport.(*http.Transport)
+		}
+		transport := base.Clone()
+		transport.TLSClientConfig = tlsCfg
+		rt = transport
+	}
+	if cc.maxResponseSize > 0 {
+		rt = &limitedTransport{base: rt, max: cc.maxResponseSize}
+	}
+	if cc.timeout > 0 {
+		rt = &idleTimeoutTransport{base: rt, timeout: cc.timeout}
+	}
+	return &http.Client{Transport: rt}, nil
+}
+
+// idleTimeoutTransport cancels a request when the upstream takes longer than
+// timeout to send response headers, or to send the next part of the body.
+// Unlike http.Client.Timeout it does not cut off long streams and exports
+// that keep making progress.
+type idleTimeoutTransport struct {
+	base    http.RoundTripper
+	timeout time.Duration
+}
+
+func (t *idleTimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	timer := time.AfterFunc(t.timeout, cancel)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		timedOut := !timer.Stop() && req.Context().Err() == nil
+		cancel()
+		if timedOut {
+			return nil, fmt.Errorf("no response within %s", t.timeout)
+		}
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		timer.Stop() // upgraded connections have their own idle timeout
+		if conn, ok := resp.Body.(io.ReadWriteCloser); ok {
+			resp.Body = &upgradedBody{ReadWriteCloser: conn, cancel: cancel}
+		}
+		return resp, nil
+	}
+	resp.Body = &idleTimeoutBody{ReadCloser: resp.Body, timer: timer, timeout: t.timeout, cancel: cancel}
+	return resp, nil
+}
+
+// idleTimeoutBody restarts the idle timer on every read.
+type idleTimeoutBody struct {
+	io.ReadCloser
+	timer   *time.Timer
+	timeout time.Duration
+	cancel  context.CancelFunc
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.timer.Reset(b.timeout)
+	}
+	return n, err
+}
+
+func (b *idleTimeoutBody) Close() error {
+	b.timer.Stop()
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// upgradedBody is an upgraded connection that releases its request's
+// context when closed.
+type upgradedBody struct {
+	io.ReadWriteCloser
+	cancel context.CancelFunc
+}
+
+func (b *upgradedBody) Close() error {
+	err := b.ReadWriteCloser.Close()
+	b.cancel()
+	return err
+}
+
+// errResponseTooLarge is returned while reading an upstream response that
+// exceeds Options.MaxResponseSize.
+var errResponseTooLarge = errors.New("upstream response exceeds the size limit")
+
+// limitedTransport fails responses whose body is longer than max bytes.
+type limitedTransport struct {
+	base http.RoundTripper
+	max  int64
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		return resp, nil // the body is the upgraded connection
+	}
+	if resp.ContentLength > t.max {
+		resp.Body.Close()
+		return nil, errResponseTooLarge
+	}
+	resp.Body = &limitedBody{ReadCloser: resp.Body, remaining: t.max}
+	return resp, nil
+}
+
+// limitedBody reads at most remaining bytes, then fails.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n - 1, errResponseTooLarge
+	}
+	return n, err
+}
+
+// normalizeBaseURL reduces a URL to scheme://host/path without a trailing
+// slash, so that "http://AM:9093/" and "http://am:9093" compare equal.
+func normalizeBaseURL(u *url.URL) string {
+	return strings.ToLower(u.Scheme) + "://" + strings.ToLower(u.Host) + strings.TrimRight(u.Path, "/")
+}
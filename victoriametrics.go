@@ -0,0 +1,296 @@
+package timeseriesui
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ── VictoriaMetrics cluster routing ─────────────────────────────────────────
+//
+// A VictoriaMetrics cluster splits its HTTP API across three components:
+//
+//	vmselect   /select/<tenant>/prometheus/…   reads
+//	           /delete/<tenant>/prometheus/…   series deletion
+//	vminsert   /insert/<tenant>/…              writes and imports
+//	vmstorage  /snapshot/…, /internal/…        storage maintenance
+//
+// The browser always sends single-node style paths (/api/v1/query,
+// /api/v1/import, …); the proxy rewrites them to the right component.
+
+func init() { registerBackend(&victoriaMetricsBackend{}) }
+
+type victoriaMetricsBackend struct {
+	baseBackend
+	flags      connFlags
+	tenant     string
+	insertURL  string
+	storageURL string
+}
+
+func (*victoriaMetricsBackend) Type() string        { return "victoriametrics" }
+func (*victoriaMetricsBackend) DisplayName() string { return "VictoriaMetrics" }
+func (*victoriaMetricsBackend) HealthPath() string  { return "/health" }
+
+func (b *victoriaMetricsBackend) RegisterFlags(fs *flag.FlagSet) {
+	b.flags.register(fs, "vm", "VictoriaMetrics")
+	b.flags.registerAuth(fs, "vm", "VictoriaMetrics", "basic-auth ")
+	fs.StringVar(&b.tenant, "vm-tenant", "", "Tenant ID for VictoriaMetrics cluster mode (e.g. 0, 0:0 or multitenant)")
+	fs.StringVar(&b.insertURL, "vm-insert-url", "", "vminsert URL for VictoriaMetrics cluster writes")
+	fs.StringVar(&b.storageURL, "vm-storage-url", "", "vmstorage URL for VictoriaMetrics cluster snapshots and force merge")
+}
+
+func (b *victoriaMetricsBackend) FlagConnections() []CLIConnection {
+	return b.flags.build(b, func(_ int, c *CLIConnection) {
+		if b.tenant != "" || b.insertURL != "" || b.storageURL != "" {
+			c.ClusterMode = true
+			c.TenantID = b.tenant
+			c.VminsertURL = b.insertURL
+			c.VmstorageURL = b.storageURL
+		}
+	})
+}
+
+func (*victoriaMetricsBackend) Validate(c *CLIConnection) error {
+	if c.ClusterMode && c.TenantID != "" {
+		return validateVMTenant(c.TenantID)
+	}
+	return nil
+}
+
+// ExtractQuery records MetricsQL queries in the query history.
+func (*victoriaMetricsBackend) ExtractQuery(r *http.Request, t *proxyTarget) (historyQuery, bool) {
+	return promQuery(r, t, "metricsql")
+}
+
+// Classify follows the cluster component split: anything vminsert serves is
+// a write; deletion, snapshots, force merge and cache resets are admin.
+func (*victoriaMetricsBackend) Classify(r *http.Request, t *proxyTarget) apiKind {
+	p := classifyPath(t.Path)
+	switch {
+	case strings.HasPrefix(p, "/insert/"):
+		return apiWrite
+	case strings.HasPrefix(p, "/delete/"),
+		strings.HasPrefix(p, "/api/v1/admin/"),
+		strings.HasPrefix(p, "/internal/"):
+		return apiAdmin
+	case strings.HasPrefix(p, "/select/"):
+		return apiRead
+	}
+	switch comp, _ := classifyVMPath(p); comp {
+	case vmInsert:
+		return apiWrite
+	case vmDelete, vmStorage:
+		return apiAdmin
+	}
+	return apiRead
+}
+
+// vmTenantMultitenant is the special read-only tenant that queries across
+// all tenants at once.
+const vmTenantMultitenant = "multitenant"
+
+// Headers used by browser-defined cluster connections. CLI connections are
+// resolved server-side and ignore these.
+const (
+	headerVMTenant     = "X-Vm-Tenant-Id"
+	headerVMInsertURL  = "X-Vm-Insert-Url"
+	headerVMStorageURL = "X-Vm-Storage-Url"
+)
+
+// vmComponent is the cluster component that serves a given API path.
+type vmComponent int
+
+const (
+	vmSelect     vmComponent = iota // /select/<tenant>/prometheus
+	vmSelectRoot                    // vmselect without a tenant prefix
+	vmDelete                        // /delete/<tenant>/prometheus
+	vmInsert                        // /insert/<tenant>/…
+	vmStorage                       // vmstorage without a tenant prefix
+)
+
+// vmCluster describes where the components of a cluster live.
+type vmCluster struct {
+	selectURL  string
+	insertURL  string
+	storageURL string
+	tenant     string
+}
+
+// validateVMTenant checks the accountID[:projectID] format used by
+// VictoriaMetrics cluster, where both parts are 32-bit unsigned integers.
+func validateVMTenant(tenant string) error {
+	if tenant == vmTenantMultitenant {
+		return nil
+	}
+	account, project, hasProject := strings.Cut(tenant, ":")
+	if _, err := strconv.ParseUint(account, 10, 32); err != nil {
+		return fmt.Errorf("invalid tenant %q: accountID must be a 32-bit unsigned integer", tenant)
+	}
+	if hasProject {
+		if _, err := strconv.ParseUint(project, 10, 32); err != nil {
+			return fmt.Errorf("invalid tenant %q: projectID must be a 32-bit unsigned integer", tenant)
+		}
+	}
+	return nil
+}
+
+// sameVMTenant reports whether two tenants name the same one; a missing
+// projectID is 0.
+func sameVMTenant(a, b string) bool {
+	if !strings.Contains(a, ":") {
+		a += ":0"
+	}
+	if !strings.Contains(b, ":") {
+		b += ":0"
+	}
+	return a == b
+}
+
+// classifyVMPath returns the cluster component that serves apiPath and the
+// path to use under that component's tenant prefix.
+func classifyVMPath(apiPath string) (vmComponent, string) {
+	switch {
+	case strings.HasPrefix(apiPath, "/api/v1/import"),
+		apiPath == "/api/v1/write":
+		return vmInsert, "/prometheus" + apiPath
+	case apiPath == "/write", apiPath == "/influx/write",
+		apiPath == "/influx/api/v2/write":
+		return vmInsert, "/influx" + strings.TrimPrefix(apiPath, "/influx")
+	case apiPath == "/api/put":
+		return vmInsert, "/opentsdb" + apiPath
+	case apiPath == "/api/v1/admin/tsdb/delete_series":
+		return vmDelete, "/prometheus" + apiPath
+	case strings.HasPrefix(apiPath, "/snapshot/"),
+		strings.HasPrefix(apiPath, "/internal/force_merge"):
+		return vmStorage, apiPath
+	case strings.HasPrefix(apiPath, "/internal/"),
+		apiPath == "/health", apiPath == "/metrics":
+		return vmSelectRoot, apiPath
+	default:
+		return vmSelect, "/prometheus" + apiPath
+	}
+}
+
+var errVMMultitenantReadOnly = &httpError{http.StatusBadRequest,
+	"The multitenant tenant is read-only; choose an accountID[:projectID] tenant for writes and admin operations"}
+
+// route maps a single-node API path to the base URL and path of the cluster
+// component that serves it.
+func (c vmCluster) route(apiPath string) (string, string, error) {
+	comp, suffix := classifyVMPath(apiPath)
+	// Paths the caller already addressed to a component pass through, cleaned
+	// and only for the connection's own tenant (any tenant for reads through
+	// a multitenant connection).
+	passThrough := false
+	for _, p := range []struct {
+		prefix string
+		comp   vmComponent
+	}{{"/select/", vmSelect}, {"/insert/", vmInsert}, {"/delete/", vmDelete}} {
+		clean := classifyPath(apiPath)
+		if !strings.HasPrefix(clean, p.prefix) {
+			continue
+		}
+		if strings.HasSuffix(apiPath, "/") {
+			clean += "/"
+		}
+		comp, passThrough, apiPath = p.comp, true, clean
+		tenant, _, _ := strings.Cut(strings.TrimPrefix(apiPath, p.prefix), "/")
+		if err := validateVMTenant(tenant); err != nil {
+			return "", "", &httpError{http.StatusBadRequest, err.Error()}
+		}
+		if tenant == vmTenantMultitenant && comp != vmSelect {
+			return "", "", errVMMultitenantReadOnly
+		}
+		if !sameVMTenant(tenant, c.tenant) && !(c.tenant == vmTenantMultitenant && comp == vmSelect) {
+			return "", "", &httpError{http.StatusForbidden,
+				fmt.Sprintf("Tenant %s is not the connection's tenant %s", tenant, c.tenant)}
+		}
+	}
+	if c.tenant == vmTenantMultitenant && comp != vmSelect && comp != vmSelectRoot {
+		return "", "", errVMMultitenantReadOnly
+	}
+
+	switch {
+	case comp == vmInsert:
+		if c.insertURL == "" {
+			return "", "", &httpError{http.StatusBadRequest,
+				"Writes to a VictoriaMetrics cluster require a vminsert URL (vminsertUrl) on the connection"}
+		}
+		if passThrough {
+			return c.insertURL, apiPath, nil
+		}
+		return c.insertURL, "/insert/" + c.tenant + suffix, nil
+	case passThrough:
+		return c.selectURL, apiPath, nil
+	case comp == vmDelete:
+		return c.selectURL, "/delete/" + c.tenant + suffix, nil
+	case comp == vmStorage:
+		if c.storageURL == "" {
+			return "", "", &httpError{http.StatusBadRequest,
+				"Snapshots and force merge on a VictoriaMetrics cluster require a vmstorage URL (vmstorageUrl) on the connection"}
+		}
+		return c.storageURL, suffix, nil
+	case comp == vmSelectRoot:
+		return c.selectURL, suffix, nil
+	default:
+		return c.selectURL, "/select/" + c.tenant + suffix, nil
+	}
+}
+
+// Route sends cluster requests to the right component. Cluster settings
+// come from the CLI connection when the target is one, otherwise from the
+// X-Vm-* headers sent by the browser. Single-node connections are forwarded
+// unchanged.
+func (*victoriaMetricsBackend) Route(r *http.Request, t *proxyTarget) error {
+	var c vmCluster
+	if conn := t.Conn; conn != nil {
+		if !conn.ClusterMode {
+			return nil
+		}
+		c = vmCluster{
+			selectURL:  strings.TrimRight(conn.URL, "/"),
+			insertURL:  strings.TrimRight(conn.VminsertURL, "/"),
+			storageURL: strings.TrimRight(conn.VmstorageURL, "/"),
+			tenant:     conn.TenantID,
+		}
+	} else {
+		c = vmCluster{
+			selectURL: t.Base,
+			tenant:    r.Header.Get(headerVMTenant),
+		}
+		if c.tenant == "" {
+			return nil
+		}
+		for _, hu := range []struct {
+			header string
+			dst    *string
+		}{{headerVMInsertURL, &c.insertURL}, {headerVMStorageURL, &c.storageURL}} {
+			v := r.Header.Get(hu.header)
+			if v == "" {
+				continue
+			}
+			u, err := url.Parse(v)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				return &httpError{http.StatusBadRequest,
+					fmt.Sprintf("Invalid %s: must use http:// or https://", hu.header)}
+			}
+			*hu.dst = strings.TrimRight(v, "/")
+		}
+	}
+	if c.tenant == "" {
+		c.tenant = "0"
+	}
+	if err := validateVMTenant(c.tenant); err != nil {
+		return &httpError{http.StatusBadRequest, err.Error()}
+	}
+	base, path, err := c.route(t.Path)
+	if err != nil {
+		return err
+	}
+	t.Base, t.Path = base, path
+	return nil
+}
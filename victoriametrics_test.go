@@ -0,0 +1,79 @@
+package timeseriesui
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestVMClusterRoute(t *testing.T) {
+	tenant := vmCluster{selectURL: "http://select", insertURL: "http://insert", tenant: "1"}
+	multi := vmCluster{selectURL: "http://select", insertURL: "http://insert", tenant: vmTenantMultitenant}
+	noInsert := vmCluster{selectURL: "http://select", tenant: "1"}
+	cases := []struct {
+		c          vmCluster
+		path       string
+		base, want string
+		status     int
+	}{
+		{tenant, "/api/v1/query", "http://select", "/select/1/prometheus/api/v1/query", 0},
+		{tenant, "/api/v1/import/csv", "http://insert", "/insert/1/prometheus/api/v1/import/csv", 0},
+		{tenant, "/api/v1/write", "http://insert", "/insert/1/prometheus/api/v1/write", 0},
+		{tenant, "/influx/write", "http://insert", "/insert/1/influx/write", 0},
+		{tenant, "/api/put", "http://insert", "/insert/1/opentsdb/api/put", 0},
+		{tenant, "/api/v1/admin/tsdb/delete_series", "http://select", "/delete/1/prometheus/api/v1/admin/tsdb/delete_series", 0},
+		{tenant, "/health", "http://select", "/health", 0},
+		{tenant, "/snapshot/create", "", "", http.StatusBadRequest},
+		{noInsert, "/api/v1/write", "", "", http.StatusBadRequest},
+
+		// Pass-through paths are kept to the connection's tenant.
+		{tenant, "/select/1/prometheus/api/v1/query", "http://select", "/select/1/prometheus/api/v1/query", 0},
+		{tenant, "/select/1:0/vmui/", "http://select", "/select/1:0/vmui/", 0},
+		{tenant, "/insert/1/influx/write", "http://insert", "/insert/1/influx/write", 0},
+		{tenant, "/delete/1/prometheus/api/v1/admin/tsdb/delete_series", "http://select", "/delete/1/prometheus/api/v1/admin/tsdb/delete_series", 0},
+		{tenant, "/select/2/prometheus/api/v1/query", "", "", http.StatusForbidden},
+		{tenant, "/select/1:2/prometheus/api/v1/query", "", "", http.StatusForbidden},
+		{tenant, "/insert/2/influx/write", "", "", http.StatusForbidden},
+		{tenant, "/delete/2/prometheus/api/v1/admin/tsdb/delete_series", "", "", http.StatusForbidden},
+		{tenant, "/select/1/../2/prometheus/api/v1/query", "", "", http.StatusForbidden},
+		{tenant, "//insert/2/influx/write", "", "", http.StatusForbidden},
+		{tenant, "/select/x/prometheus/api/v1/query", "", "", http.StatusBadRequest},
+		{noInsert, "/insert/1/influx/write", "", "", http.StatusBadRequest},
+
+		// A multitenant connection reads any tenant and writes none.
+		{multi, "/api/v1/query", "http://select", "/select/multitenant/prometheus/api/v1/query", 0},
+		{multi, "/select/2/prometheus/api/v1/query", "http://select", "/select/2/prometheus/api/v1/query", 0},
+		{multi, "/api/v1/write", "", "", http.StatusBadRequest},
+		{multi, "/insert/2/influx/write", "", "", http.StatusForbidden},
+		{multi, "/insert/multitenant/influx/write", "", "", http.StatusBadRequest},
+		{multi, "/delete/multitenant/prometheus/api/v1/admin/tsdb/delete_series", "", "", http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		base, path, err := c.c.route(c.path)
+		if c.status != 0 {
+			if err == nil || httpErrorStatus(err, 0) != c.status {
+				t.Errorf("tenant %s, %s: %s%s, %v; want status %d", c.c.tenant, c.path, base, path, err, c.status)
+			}
+			continue
+		}
+		if err != nil || base != c.base || path != c.want {
+			t.Errorf("tenant %s, %s: %s%s, %v; want %s%s", c.c.tenant, c.path, base, path, err, c.base, c.want)
+		}
+	}
+}
+
+func TestSameVMTenant(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		want bool
+	}{
+		{"0", "0", true},
+		{"0", "0:0", true},
+		{"1:2", "1:2", true},
+		{"1", "1:2", false},
+		{"1", "2", false},
+	} {
+		if got := sameVMTenant(c.a, c.b); got != c.want {
+			t.Errorf("sameVMTenant(%q, %q) = %v", c.a, c.b, got)
+		}
+	}
+}